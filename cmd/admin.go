@@ -0,0 +1,375 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/Aergiaaa/rollet/internal/database"
+	"github.com/Aergiaaa/rollet/internal/rbac"
+	"github.com/gin-gonic/gin"
+)
+
+const defaultUserPageSize = 50
+
+type userSearchQuery struct {
+	Q        string `form:"q" json:"q" binding:"max=255"`
+	Role     string `form:"role" json:"role" binding:"omitempty,oneof=user support admin"`
+	Disabled *bool  `form:"disabled" json:"disabled"`
+	Limit    int    `form:"limit" json:"limit" binding:"omitempty,min=1,max=200"`
+	Offset   int    `form:"offset" json:"offset" binding:"min=0"`
+}
+
+type userListResponse struct {
+	Users  []*database.User `json:"users"`
+	Total  int              `json:"total"`
+	Limit  int              `json:"limit"`
+	Offset int              `json:"offset"`
+}
+
+type userRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=user support admin"`
+}
+
+type passwordResetResponse struct {
+	// TemporaryPassword must be passed on to the user, who has to replace
+	// it on first login.
+	TemporaryPassword string `json:"temporary_password"`
+}
+
+type statsResponse struct {
+	database.Stats
+	LiveSessions int `json:"live_sessions"`
+}
+
+// listUsers godoc
+// @Summary      List and search users
+// @Description  Returns a page of users by id. q matches part of the email or name. Needs the users:read permission.
+// @Tags         admin
+// @Produce      json
+// @Param        q         query     string  false  "Part of the email or name"
+// @Param        role      query     string  false  "Role"  Enums(user, support, admin)
+// @Param        disabled  query     bool    false  "Only disabled, or only enabled, users"
+// @Param        limit     query     int     false  "Page size, 1 to 200"  default(50)
+// @Param        offset    query     int     false  "Users to skip"  default(0)
+// @Success      200       {object}  userListResponse
+// @Failure      400       {object}  problemDetails
+// @Failure      401       {object}  problemDetails
+// @Failure      403       {object}  problemDetails
+// @Failure      422       {object}  problemDetails
+// @Failure      500       {object}  problemDetails
+// @Router       /v1/admin/users [get]
+func (app *app) listUsers(c *gin.Context) {
+	var q userSearchQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		bindQueryProblem(c, err)
+		return
+	}
+	if q.Limit == 0 {
+		q.Limit = defaultUserPageSize
+	}
+
+	users, total, err := app.models.Users.Search(c.Request.Context(), database.UserFilter{
+		Query:    q.Q,
+		Role:     q.Role,
+		Disabled: q.Disabled,
+		Limit:    q.Limit,
+		Offset:   q.Offset,
+	})
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to search users", "error", err)
+		problem(c, http.StatusInternalServerError, codeInternal, "Failed to retrieve data")
+		return
+	}
+
+	c.JSON(http.StatusOK, userListResponse{Users: users, Total: total, Limit: q.Limit, Offset: q.Offset})
+}
+
+// getUser godoc
+// @Summary      Get a user
+// @Description  Needs the users:read permission.
+// @Tags         admin
+// @Produce      json
+// @Param        id   path      int  true  "User ID"
+// @Success      200  {object}  database.User
+// @Failure      401  {object}  problemDetails
+// @Failure      403  {object}  problemDetails
+// @Failure      404  {object}  problemDetails
+// @Failure      500  {object}  problemDetails
+// @Router       /v1/admin/users/{id} [get]
+func (app *app) getUser(c *gin.Context) {
+	target, ok := app.targetUser(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, target)
+}
+
+// disableUser godoc
+// @Summary      Disable a user
+// @Description  The user can no longer log in, and tokens already issued are refused. Needs the users:write permission and a role above the user's.
+// @Tags         admin
+// @Produce      json
+// @Param        id   path      int  true  "User ID"
+// @Success      200  {object}  database.User
+// @Failure      401  {object}  problemDetails
+// @Failure      403  {object}  problemDetails
+// @Failure      404  {object}  problemDetails
+// @Failure      500  {object}  problemDetails
+// @Router       /v1/admin/users/{id}/disable [post]
+func (app *app) disableUser(c *gin.Context) {
+	app.setUserDisabled(c, true)
+}
+
+// enableUser godoc
+// @Summary      Enable a user
+// @Description  Lets a disabled user log in again. Needs the users:write permission and a role above the user's.
+// @Tags         admin
+// @Produce      json
+// @Param        id   path      int  true  "User ID"
+// @Success      200  {object}  database.User
+// @Failure      401  {object}  problemDetails
+// @Failure      403  {object}  problemDetails
+// @Failure      404  {object}  problemDetails
+// @Failure      500  {object}  problemDetails
+// @Router       /v1/admin/users/{id}/enable [post]
+func (app *app) enableUser(c *gin.Context) {
+	app.setUserDisabled(c, false)
+}
+
+func (app *app) setUserDisabled(c *gin.Context, disabled bool) {
+	target, ok := app.managedUser(c)
+	if !ok {
+		return
+	}
+
+	err := app.models.Users.SetDisabled(c.Request.Context(), target.Id, disabled)
+	if errors.Is(err, sql.ErrNoRows) {
+		problem(c, http.StatusNotFound, codeNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to update user", "error", err)
+		problem(c, http.StatusInternalServerError, codeInternal, "Failed to update user")
+		return
+	}
+
+	action := auditUserEnabled
+	if disabled {
+		action = auditUserDisabled
+	}
+	app.audit(c, auditUser(action, target, nil))
+	app.respondUser(c, target.Id)
+}
+
+// setUserRole godoc
+// @Summary      Change a user's role
+// @Description  Sets the system-wide role: user, support or admin. Needs the roles:write permission. Admins cannot change their own role, so one always remains.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        id    path      int              true  "User ID"
+// @Param        body  body      userRoleRequest  true  "Role"
+// @Success      200   {object}  database.User
+// @Failure      400   {object}  problemDetails
+// @Failure      401   {object}  problemDetails
+// @Failure      403   {object}  problemDetails
+// @Failure      404   {object}  problemDetails
+// @Failure      422   {object}  problemDetails
+// @Failure      500   {object}  problemDetails
+// @Router       /v1/admin/users/{id}/role [put]
+func (app *app) setUserRole(c *gin.Context) {
+	target, ok := app.managedUser(c)
+	if !ok {
+		return
+	}
+
+	var req userRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindProblem(c, err)
+		return
+	}
+
+	err := app.models.Users.SetRole(c.Request.Context(), target.Id, req.Role)
+	if errors.Is(err, sql.ErrNoRows) {
+		problem(c, http.StatusNotFound, codeNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to update user role", "error", err)
+		problem(c, http.StatusInternalServerError, codeInternal, "Failed to update user")
+		return
+	}
+
+	app.audit(c, auditUser(auditRoleChanged, target, map[string]any{"from": target.Role, "to": req.Role}))
+	app.respondUser(c, target.Id)
+}
+
+// resetUserPassword godoc
+// @Summary      Force a password reset
+// @Description  Replaces the password with a temporary one, returned once. On login with it the user must set a new password before anything else, and tokens already issued are refused. Needs the passwords:reset permission.
+// @Tags         admin
+// @Produce      json
+// @Param        id   path      int  true  "User ID"
+// @Success      200  {object}  passwordResetResponse
+// @Failure      401  {object}  problemDetails
+// @Failure      403  {object}  problemDetails
+// @Failure      404  {object}  problemDetails
+// @Failure      500  {object}  problemDetails
+// @Router       /v1/admin/users/{id}/reset-password [post]
+func (app *app) resetUserPassword(c *gin.Context) {
+	target, ok := app.managedUser(c)
+	if !ok {
+		return
+	}
+
+	plain, hash, err := preparePassword("")
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to generate password", "error", err)
+		problem(c, http.StatusInternalServerError, codeInternal, "Failed to reset password")
+		return
+	}
+
+	err = app.models.Users.ForcePasswordReset(c.Request.Context(), target.Id, hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		problem(c, http.StatusNotFound, codeNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to reset password", "error", err)
+		problem(c, http.StatusInternalServerError, codeInternal, "Failed to reset password")
+		return
+	}
+
+	app.audit(c, auditUser(auditPasswordReset, target, nil))
+	c.JSON(http.StatusOK, passwordResetResponse{TemporaryPassword: plain})
+}
+
+// deleteUser godoc
+// @Summary      Delete a user
+// @Description  Deletes the user with their personal draws, links and keys. Draws they saved to an organisation stay with it. A user who is the last owner of an organisation cannot be deleted until it has another owner or is deleted. Needs the users:delete permission.
+// @Tags         admin
+// @Param        id   path  int  true  "User ID"
+// @Success      204
+// @Failure      401  {object}  problemDetails
+// @Failure      403  {object}  problemDetails
+// @Failure      404  {object}  problemDetails
+// @Failure      409  {object}  problemDetails
+// @Failure      500  {object}  problemDetails
+// @Router       /v1/admin/users/{id} [delete]
+func (app *app) deleteUser(c *gin.Context) {
+	target, ok := app.managedUser(c)
+	if !ok {
+		return
+	}
+
+	err := app.models.Users.Delete(c.Request.Context(), target.Id)
+	var lastOwner *database.LastOwnerError
+	if errors.As(err, &lastOwner) {
+		problem(c, http.StatusConflict, codeLastOwner,
+			fmt.Sprintf("The user is the last owner of %q; it needs another owner or must be deleted first", lastOwner.OrgName))
+		return
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		problem(c, http.StatusNotFound, codeNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to delete user", "error", err)
+		problem(c, http.StatusInternalServerError, codeInternal, "Failed to delete user")
+		return
+	}
+
+	// The email keeps the entry meaningful once the account is gone
+	app.audit(c, auditUser(auditUserDeleted, target, map[string]any{"email": target.Email}))
+
+	c.Status(http.StatusNoContent)
+}
+
+// getStats godoc
+// @Summary      System statistics
+// @Description  Counts of users, draws and other stored resources, and of running live sessions. Needs the stats:read permission.
+// @Tags         admin
+// @Produce      json
+// @Success      200  {object}  statsResponse
+// @Failure      401  {object}  problemDetails
+// @Failure      403  {object}  problemDetails
+// @Failure      500  {object}  problemDetails
+// @Router       /v1/admin/stats [get]
+func (app *app) getStats(c *gin.Context) {
+	stats, err := app.models.Stats.Get(c.Request.Context())
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to retrieve stats", "error", err)
+		problem(c, http.StatusInternalServerError, codeInternal, "Failed to retrieve data")
+		return
+	}
+
+	c.JSON(http.StatusOK, statsResponse{Stats: *stats, LiveSessions: app.live.Len()})
+}
+
+// targetUser loads the user named by the :id parameter, responding 404 if
+// there is none.
+func (app *app) targetUser(c *gin.Context) (*database.User, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		problem(c, http.StatusNotFound, codeNotFound, "User not found")
+		return nil, false
+	}
+
+	target, err := app.models.Users.Get(c.Request.Context(), id)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to retrieve user", "error", err)
+		problem(c, http.StatusInternalServerError, codeInternal, "Failed to retrieve user")
+		return nil, false
+	}
+	if target == nil {
+		problem(c, http.StatusNotFound, codeNotFound, "User not found")
+		return nil, false
+	}
+
+	return target, true
+}
+
+// managedUser is targetUser for changes: the caller may not change their
+// own account this way, nor a user whose role is not below theirs unless
+// they are an admin.
+func (app *app) managedUser(c *gin.Context) (*database.User, bool) {
+	user, exists := c.Get("user")
+	if !exists || user == nil {
+		problem(c, http.StatusUnauthorized, codeUnauthorized, "Authentication is required")
+		return nil, false
+	}
+	actor := user.(*database.User)
+
+	target, ok := app.targetUser(c)
+	if !ok {
+		return nil, false
+	}
+
+	if target.Id == actor.Id {
+		problem(c, http.StatusForbidden, codeForbidden, "Operators cannot change their own account here")
+		return nil, false
+	}
+	if !rbac.CanManage(actor.Role, target.Role) {
+		problem(c, http.StatusForbidden, codeForbidden, "This user's role is not below yours")
+		return nil, false
+	}
+
+	return target, true
+}
+
+// respondUser responds with the user as stored now.
+func (app *app) respondUser(c *gin.Context, id int) {
+	u, err := app.models.Users.Get(c.Request.Context(), id)
+	if err != nil || u == nil {
+		slog.ErrorContext(c.Request.Context(), "failed to retrieve user", "error", err)
+		problem(c, http.StatusInternalServerError, codeInternal, "Failed to retrieve user")
+		return
+	}
+
+	c.JSON(http.StatusOK, u)
+}
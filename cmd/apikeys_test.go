@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Aergiaaa/rollet/internal/apikey"
+	"github.com/Aergiaaa/rollet/internal/database"
+	"github.com/gin-gonic/gin"
+)
+
+func TestCheckAPIKeyRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	org := 7
+	userKey := &database.APIKey{Scopes: []string{apikey.DrawsRead}}
+	orgKey := &database.APIKey{OrgId: &org, Scopes: []string{apikey.DrawsWrite}}
+
+	tests := []struct {
+		name   string
+		key    *database.APIKey
+		method string
+		route  string
+		path   string
+		want   int
+	}{
+		{"scoped route", userKey, http.MethodGet, "/v1/user/history", "/v1/user/history", http.StatusNoContent},
+		{"unscoped route", userKey, http.MethodGet, "/v1/user/api-keys", "/v1/user/api-keys", http.StatusForbidden},
+		{"missing scope", userKey, http.MethodPost, "/v1/user/random/custom", "/v1/user/random/custom", http.StatusForbidden},
+		{"own organisation", orgKey, http.MethodGet, "/v1/orgs/:org/history", "/v1/orgs/7/history", http.StatusNoContent},
+		{"other organisation", orgKey, http.MethodGet, "/v1/orgs/:org/history", "/v1/orgs/8/history", http.StatusForbidden},
+		{"org key on user route", orgKey, http.MethodGet, "/v1/user/history", "/v1/user/history", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.Handle(tt.method, tt.route, func(c *gin.Context) {
+				if checkAPIKeyRoute(c, tt.key) {
+					c.Status(http.StatusNoContent)
+				}
+			})
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+			if w.Code != tt.want {
+				t.Errorf("status = %d; want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
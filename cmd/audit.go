@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Aergiaaa/rollet/internal/database"
+	"github.com/Aergiaaa/rollet/internal/logging"
+	"github.com/gin-gonic/gin"
+)
+
+// Audit actions. They are part of the API: reviewers filter on them, so
+// existing actions must not change meaning.
+const (
+	auditLogin           = "auth.login"
+	auditRegister        = "auth.register"
+	auditPasswordChanged = "auth.password_changed"
+	auditDrawSaved       = "draw.saved"
+	auditDrawDeleted     = "draw.deleted"
+	auditUserCreated     = "admin.user_created"
+	auditUserDisabled    = "admin.user_disabled"
+	auditUserEnabled     = "admin.user_enabled"
+	auditRoleChanged     = "admin.role_changed"
+	auditPasswordReset   = "admin.password_reset"
+	auditUserDeleted     = "admin.user_deleted"
+	auditMemberAdded     = "org.member_added"
+	auditMemberRemoved   = "org.member_removed"
+	auditMemberRole      = "org.role_changed"
+	auditOrgDeleted      = "org.deleted"
+	auditRosterCreated   = "roster.created"
+	auditRosterUpdated   = "roster.updated"
+	auditRosterDeleted   = "roster.deleted"
+	auditAPIKeyCreated   = "apikey.created"
+	auditAPIKeyRevoked   = "apikey.revoked"
+)
+
+// auditCLIActor stands in for the actor of entries written by the CLI,
+// which runs with direct database access rather than as a user.
+const auditCLIActor = "cli"
+
+const (
+	defaultAuditPageSize = 50
+	// auditTimeout bounds writing an entry once the request is gone.
+	auditTimeout = 3 * time.Second
+	// maxAuditUserAgentLength matches the column width.
+	maxAuditUserAgentLength = 512
+)
+
+type auditQuery struct {
+	Action  string     `form:"action" json:"action" binding:"max=64"`
+	Outcome string     `form:"outcome" json:"outcome" binding:"omitempty,oneof=success failure"`
+	ActorID int        `form:"actor_id" json:"actor_id" binding:"min=0"`
+	OrgID   int        `form:"org_id" json:"org_id" binding:"min=0"`
+	Since   *time.Time `form:"since" json:"since" time_format:"2006-01-02T15:04:05Z07:00"`
+	Until   *time.Time `form:"until" json:"until" time_format:"2006-01-02T15:04:05Z07:00"`
+	Limit   int        `form:"limit" json:"limit" binding:"omitempty,min=1,max=200"`
+	Offset  int        `form:"offset" json:"offset" binding:"min=0"`
+}
+
+type auditListResponse struct {
+	Entries []*database.AuditEntry `json:"entries"`
+	Total   int                    `json:"total"`
+	Limit   int                    `json:"limit"`
+	Offset  int                    `json:"offset"`
+}
+
+// listAudit godoc
+// @Summary      Audit log
+// @Description  Returns a page of the audit log, newest first: logins, registrations, password changes, saved and deleted draws, organisation membership changes, API keys created and revoked, and admin actions, each with the actor, IP, user agent and request ID. Needs the audit:read permission.
+// @Tags         admin
+// @Produce      json
+// @Param        action    query     string  false  "Action, e.g. auth.login"
+// @Param        outcome   query     string  false  "Outcome"  Enums(success, failure)
+// @Param        actor_id  query     int     false  "User who acted"
+// @Param        org_id    query     int     false  "Organisation"
+// @Param        since     query     string  false  "Earliest time, RFC 3339"
+// @Param        until     query     string  false  "Time before which entries are returned, RFC 3339"
+// @Param        limit     query     int     false  "Page size, 1 to 200"  default(50)
+// @Param        offset    query     int     false  "Entries to skip"  default(0)
+// @Success      200       {object}  auditListResponse
+// @Failure      400       {object}  problemDetails
+// @Failure      401       {object}  problemDetails
+// @Failure      403       {object}  problemDetails
+// @Failure      422       {object}  problemDetails
+// @Failure      500       {object}  problemDetails
+// @Router       /v1/admin/audit [get]
+func (app *app) listAudit(c *gin.Context) {
+	var q auditQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		bindQueryProblem(c, err)
+		return
+	}
+
+	app.respondAudit(c, q)
+}
+
+// listOrgAudit godoc
+// @Summary      Organisation audit log
+// @Description  Returns a page of the organisation's audit entries, such as saved and deleted draws, membership changes and API keys, newest first. Needs the owner role.
+// @Tags         orgs
+// @Produce      json
+// @Param        org       path      int     true   "Organisation ID"
+// @Param        action    query     string  false  "Action, e.g. draw.saved"
+// @Param        outcome   query     string  false  "Outcome"  Enums(success, failure)
+// @Param        actor_id  query     int     false  "User who acted"
+// @Param        since     query     string  false  "Earliest time, RFC 3339"
+// @Param        until     query     string  false  "Time before which entries are returned, RFC 3339"
+// @Param        limit     query     int     false  "Page size, 1 to 200"  default(50)
+// @Param        offset    query     int     false  "Entries to skip"  default(0)
+// @Success      200       {object}  auditListResponse
+// @Failure      400       {object}  problemDetails
+// @Failure      401       {object}  problemDetails
+// @Failure      403       {object}  problemDetails
+// @Failure      404       {object}  problemDetails
+// @Failure      422       {object}  problemDetails
+// @Failure      500       {object}  problemDetails
+// @Router       /v1/orgs/{org}/audit [get]
+func (app *app) listOrgAudit(c *gin.Context) {
+	membership := c.MustGet("membership").(*database.Membership)
+
+	var q auditQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		bindQueryProblem(c, err)
+		return
+	}
+	q.OrgID = membership.OrgId
+
+	app.respondAudit(c, q)
+}
+
+func (app *app) respondAudit(c *gin.Context, q auditQuery) {
+	if q.Limit == 0 {
+		q.Limit = defaultAuditPageSize
+	}
+
+	entries, total, err := app.models.Audit.List(c.Request.Context(), database.AuditFilter{
+		Action:  q.Action,
+		Outcome: q.Outcome,
+		ActorId: q.ActorID,
+		OrgId:   q.OrgID,
+		Since:   q.Since,
+		Until:   q.Until,
+		Limit:   q.Limit,
+		Offset:  q.Offset,
+	})
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to list audit entries", "error", err)
+		problem(c, http.StatusInternalServerError, codeInternal, "Failed to retrieve data")
+		return
+	}
+
+	c.JSON(http.StatusOK, auditListResponse{Entries: entries, Total: total, Limit: q.Limit, Offset: q.Offset})
+}
+
+// audit appends e to the audit log, filling in the request's IP, user
+// agent and request ID and, unless e names one, the authenticated actor. A
+// failure is logged but does not fail the request, which has already
+// taken effect; for the same reason a client hanging up does not cancel
+// the write.
+func (app *app) audit(c *gin.Context, e database.AuditEntry) {
+	if e.Outcome == "" {
+		e.Outcome = database.OutcomeSuccess
+	}
+	if e.ActorId == nil {
+		if user, ok := c.Get("user"); ok {
+			u := user.(*database.User)
+			e.ActorId = &u.Id
+			e.ActorEmail = u.Email
+		}
+	}
+	if k, ok := c.Get("apiKey"); ok && k.(*database.APIKey) != nil {
+		e.APIKeyId = &k.(*database.APIKey).Id
+	}
+	e.IP = c.ClientIP()
+	e.UserAgent = truncateUTF8(c.Request.UserAgent(), maxAuditUserAgentLength)
+	e.RequestId = logging.RequestID(c.Request.Context())
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(c.Request.Context()), auditTimeout)
+	defer cancel()
+	if err := app.models.Audit.Insert(ctx, &e); err != nil {
+		slog.ErrorContext(ctx, "failed to write audit entry", "action", e.Action, "error", err)
+	}
+}
+
+// auditLogin records a login attempt of user, nil if the email is unknown.
+// An empty reason means the login succeeded.
+func (app *app) auditLogin(c *gin.Context, user *database.User, email, method, reason string) {
+	e := database.AuditEntry{
+		Action:     auditLogin,
+		ActorEmail: email,
+		Details:    auditDetails(map[string]any{"method": method}),
+	}
+	if user != nil {
+		e.ActorId = &user.Id
+	}
+	if reason != "" {
+		e.Outcome = database.OutcomeFailure
+		e.Details = auditDetails(map[string]any{"method": method, "reason": reason})
+	}
+	app.audit(c, e)
+}
+
+// auditUser is an audit entry about the user, such as an admin action on
+// them.
+func auditUser(action string, u *database.User, details map[string]any) database.AuditEntry {
+	return database.AuditEntry{
+		Action:     action,
+		TargetType: "user",
+		TargetId:   strconv.Itoa(u.Id),
+		Details:    auditDetails(details),
+	}
+}
+
+// auditMember is an audit entry about a member of the organisation.
+func auditMember(action string, orgId, userId int, details map[string]any) database.AuditEntry {
+	return database.AuditEntry{
+		Action:     action,
+		OrgId:      &orgId,
+		TargetType: "user",
+		TargetId:   strconv.Itoa(userId),
+		Details:    auditDetails(details),
+	}
+}
+
+// auditDetails encodes details for an audit entry, or returns nil when
+// there are none.
+func auditDetails(details map[string]any) json.RawMessage {
+	if len(details) == 0 {
+		return nil
+	}
+	b, err := json.Marshal(details)
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+// truncateUTF8 cuts s to at most n bytes without splitting a character, and
+// drops invalid bytes, which Postgres would refuse.
+func truncateUTF8(s string, n int) string {
+	if len(s) > n {
+		s = s[:n]
+	}
+	return strings.ToValidUTF8(s, "")
+}
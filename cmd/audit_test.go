@@ -0,0 +1,109 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestTruncateUTF8(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		n    int
+		want string
+	}{
+		{name: "shorter than the limit", in: "curl/8.0", n: 512, want: "curl/8.0"},
+		{name: "exactly the limit", in: "abcd", n: 4, want: "abcd"},
+		{name: "cut ASCII", in: "abcdef", n: 3, want: "abc"},
+		{name: "cut before a whole character", in: "ab日本", n: 5, want: "ab日"},
+		{name: "cut inside a character drops it", in: "ab日本", n: 6, want: "ab日"},
+		{name: "invalid bytes are dropped", in: "a\xffb\xfe", n: 512, want: "ab"},
+		{name: "empty", in: "", n: 10, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := truncateUTF8(tt.in, tt.n); got != tt.want {
+				t.Errorf("truncateUTF8(%q, %d) = %q; want %q", tt.in, tt.n, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuditDetails(t *testing.T) {
+	tests := []struct {
+		name    string
+		details map[string]any
+		want    string
+	}{
+		{name: "nil", details: nil, want: ""},
+		{name: "empty", details: map[string]any{}, want: ""},
+		{name: "sorted keys", details: map[string]any{"to": "admin", "from": "user"}, want: `{"from":"user","to":"admin"}`},
+		{name: "nested values", details: map[string]any{"scopes": []string{"draws:read"}, "left": true}, want: `{"left":true,"scopes":["draws:read"]}`},
+		{name: "unencodable", details: map[string]any{"ch": make(chan int)}, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(auditDetails(tt.details)); got != tt.want {
+				t.Errorf("auditDetails(%v) = %q; want %q", tt.details, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuditQueryBinding(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	since := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		query   string
+		want    auditQuery
+		wantErr bool
+	}{
+		{name: "no filters", query: "", want: auditQuery{}},
+		{
+			name:  "all filters",
+			query: "action=auth.login&outcome=failure&actor_id=3&org_id=9&since=2026-01-02T03:04:05Z&limit=10&offset=20",
+			want:  auditQuery{Action: "auth.login", Outcome: "failure", ActorID: 3, OrgID: 9, Since: &since, Limit: 10, Offset: 20},
+		},
+		{name: "unknown outcome", query: "outcome=maybe", wantErr: true},
+		{name: "limit too large", query: "limit=201", wantErr: true},
+		{name: "negative offset", query: "offset=-1", wantErr: true},
+		{name: "negative actor", query: "actor_id=-3", wantErr: true},
+		{name: "action too long", query: "action=" + strings.Repeat("a", 65), wantErr: true},
+		{name: "time without zone", query: "since=2026-01-02T03:04:05", wantErr: true},
+		{name: "not a number", query: "limit=ten", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("GET", "/v1/admin/audit?"+tt.query, nil)
+
+			var got auditQuery
+			err := c.ShouldBindQuery(&got)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("bound %+v; want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (got.Since == nil) != (tt.want.Since == nil) || (got.Since != nil && !got.Since.Equal(*tt.want.Since)) {
+				t.Errorf("since = %v; want %v", got.Since, tt.want.Since)
+			}
+			got.Since, tt.want.Since = nil, nil
+			if got != tt.want {
+				t.Errorf("bound %+v; want %+v", got, tt.want)
+			}
+		})
+	}
+}
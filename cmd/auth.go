@@ -3,12 +3,15 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"log/slog"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/Aergiaaa/rollet/internal/database"
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"golang.org/x/crypto/bcrypt"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
@@ -20,9 +23,9 @@ const (
 )
 
 type registerRequest struct {
-	Email    string `json:"email" binding:"required,email"`
-	Name     string `json:"name" binding:"required,min=3"`
-	Password string `json:"password" binding:"min=8"`
+	Email    string `json:"email" binding:"required,email,max=255"`
+	Name     string `json:"name" binding:"required,min=3,max=100"`
+	Password string `json:"password" binding:"min=8,max=72"`
 }
 
 type registerResponse struct {
@@ -31,13 +34,25 @@ type registerResponse struct {
 }
 
 type loginRequest struct {
-	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"min=8"`
+	Email    string `json:"email" binding:"required,email,max=255"`
+	Password string `json:"password" binding:"min=8,max=72"`
 }
 
 type loginResponse struct {
 	Token  string `json:"token"`
 	UserID int    `json:"user_id"`
+	// PasswordResetRequired is set when an operator reset the password; the
+	// token then only works on PUT /v1/user/password.
+	PasswordResetRequired bool `json:"password_reset_required,omitempty"`
+}
+
+// changePasswordRoute stays open to users who must reset their password.
+const changePasswordRoute = "/v1/user/password"
+
+type changePasswordRequest struct {
+	// CurrentPassword is required unless the user only signs in with Google.
+	CurrentPassword string `json:"current_password" binding:"max=72"`
+	NewPassword     string `json:"new_password" binding:"required,min=8,max=72"`
 }
 
 type googleAuthRequest struct {
@@ -59,10 +74,6 @@ type googleProfile struct {
 	// Locale        string `json:"locale"`
 }
 
-type errorResponse struct {
-	Error string `json:"error"`
-}
-
 // register godoc
 // @Summary      Register a new user
 // @Description  Register a new user with email, name, and password
@@ -71,38 +82,53 @@ type errorResponse struct {
 // @Produce      json
 // @Param        body  body      registerRequest  true  "Register Request"
 // @Success      201   {object}  registerResponse
-// @Failure      400   {object}  errorResponse
-// @Failure      500   {object}  errorResponse
+// @Failure      400   {object}  problemDetails
+// @Failure      409   {object}  problemDetails
+// @Failure      413   {object}  problemDetails
+// @Failure      422   {object}  problemDetails
+// @Failure      500   {object}  problemDetails
 // @Router       /v1/auth/register [post]
 func (app *app) register(c *gin.Context) {
 	// Bind and validate input
 	var req registerRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, errorResponse{err.Error()})
+		bindProblem(c, err)
 		return
 	}
 
 	// Hash the password
 	hashPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, errorResponse{"failed to hash password"})
+		slog.ErrorContext(c.Request.Context(), "failed to hash password", "error", err)
+		problem(c, http.StatusInternalServerError, codeInternal, "Failed to hash password")
 		return
 	}
 
 	// Create user object
 	user := database.User{
 		Email:    req.Email,
-		Name:     req.Name,
+		Name:     normalizeText(req.Name),
 		Password: string(hashPassword),
 	}
 
 	// Insert user into database
-	err = app.models.Users.Insert(&user)
+	err = app.models.Users.Insert(c.Request.Context(), &user)
+	if errors.Is(err, database.ErrDuplicateEmail) {
+		problem(c, http.StatusConflict, codeEmailTaken, "A user with this email already exists")
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, errorResponse{"failed to register user"})
+		slog.ErrorContext(c.Request.Context(), "failed to register user", "error", err)
+		problem(c, http.StatusInternalServerError, codeInternal, "Failed to register user")
 		return
 	}
 
+	app.audit(c, database.AuditEntry{
+		Action:     auditRegister,
+		ActorId:    &user.Id,
+		ActorEmail: user.Email,
+		Details:    auditDetails(map[string]any{"method": "password"}),
+	})
 	c.JSON(http.StatusCreated, registerResponse{
 		"User registered successfuly",
 		user,
@@ -117,58 +143,127 @@ func (app *app) register(c *gin.Context) {
 // @Produce      json
 // @Param        body  body      loginRequest  true  "Login request"
 // @Success      200   {object}  loginResponse
-// @Failure      400   {object}  errorResponse
-// @Failure      401   {object}  errorResponse
-// @Failure      500   {object}  errorResponse
+// @Failure      400   {object}  problemDetails
+// @Failure      401   {object}  problemDetails
+// @Failure      403   {object}  problemDetails
+// @Failure      413   {object}  problemDetails
+// @Failure      422   {object}  problemDetails
+// @Failure      500   {object}  problemDetails
 // @Router       /v1/auth/login [post]
 func (app *app) login(c *gin.Context) {
 
 	// Bind and validate input
 	var req loginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, errorResponse{err.Error()})
+		bindProblem(c, err)
 		return
 	}
 
 	// Retrieve user by name
-	existingUser, err := app.models.Users.GetByEmail(req.Email)
+	existingUser, err := app.models.Users.GetByEmail(c.Request.Context(), req.Email)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, errorResponse{"Failed to retrieve user"})
+		slog.ErrorContext(c.Request.Context(), "failed to retrieve user", "error", err)
+		problem(c, http.StatusInternalServerError, codeInternal, "Failed to retrieve user")
 		return
 	}
 
 	// Check if user exists
 	if existingUser == nil {
-		c.JSON(http.StatusUnauthorized, errorResponse{"Invalid Google ID or password"})
+		app.metrics.LoginFailed("password", "unknown_user")
+		app.auditLogin(c, nil, req.Email, "password", "unknown_user")
+		problem(c, http.StatusUnauthorized, codeInvalidCredentials, "Invalid email or password")
 		return
 	}
 
 	// Verify password
 	err = bcrypt.CompareHashAndPassword([]byte(existingUser.Password), []byte(req.Password))
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, errorResponse{"Invalid Google ID or password"})
+		app.metrics.LoginFailed("password", "bad_password")
+		app.auditLogin(c, existingUser, req.Email, "password", "bad_password")
+		problem(c, http.StatusUnauthorized, codeInvalidCredentials, "Invalid email or password")
 		return
 	}
 
-	// Generate JWT token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"userId": existingUser.Id,
-		"expr":   time.Now().Add(time.Hour * 3).Unix(),
-	})
+	// Only tell the right password apart from a disabled account
+	if existingUser.DisabledAt != nil {
+		app.metrics.LoginFailed("password", "disabled")
+		app.auditLogin(c, existingUser, req.Email, "password", "disabled")
+		problem(c, http.StatusForbidden, codeAccountDisabled, "This account is disabled")
+		return
+	}
 
-	// Sign the token with the secret
-	tokenStr, err := token.SignedString([]byte(app.jwtSecret))
+	tokenStr, err := app.issueToken(existingUser)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, errorResponse{"Failed to generate token"})
+		slog.ErrorContext(c.Request.Context(), "failed to generate token", "error", err)
+		problem(c, http.StatusInternalServerError, codeInternal, "Failed to generate token")
 		return
 	}
 
+	app.auditLogin(c, existingUser, existingUser.Email, "password", "")
 	c.JSON(http.StatusOK, loginResponse{
-		Token:  tokenStr,
-		UserID: existingUser.Id,
+		Token:                 tokenStr,
+		UserID:                existingUser.Id,
+		PasswordResetRequired: existingUser.PasswordResetRequired,
 	})
 }
 
+// changePassword godoc
+// @Summary      Change password
+// @Description  Sets a new password after checking the current one. Users whose password an operator reset must call this before any other authenticated route. Tokens issued before, including the one used here, stop working; log in again with the new password.
+// @Tags         auth
+// @Accept       json
+// @Param        body  body  changePasswordRequest  true  "Passwords"
+// @Success      204
+// @Failure      400   {object}  problemDetails
+// @Failure      401   {object}  problemDetails
+// @Failure      403   {object}  problemDetails
+// @Failure      422   {object}  problemDetails
+// @Failure      500   {object}  problemDetails
+// @Router       /v1/user/password [put]
+func (app *app) changePassword(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists || user == nil {
+		problem(c, http.StatusUnauthorized, codeUnauthorized, "Authentication is required")
+		return
+	}
+	userObj := user.(*database.User)
+
+	var req changePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindProblem(c, err)
+		return
+	}
+
+	if userObj.Password != "" {
+		err := bcrypt.CompareHashAndPassword([]byte(userObj.Password), []byte(req.CurrentPassword))
+		if err != nil {
+			problem(c, http.StatusUnauthorized, codeInvalidCredentials, "The current password is wrong")
+			return
+		}
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to hash password", "error", err)
+		problem(c, http.StatusInternalServerError, codeInternal, "Failed to hash password")
+		return
+	}
+
+	if err := app.models.Users.UpdatePassword(c.Request.Context(), userObj.Id, string(hash)); err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to update password", "error", err)
+		problem(c, http.StatusInternalServerError, codeInternal, "Failed to update password")
+		return
+	}
+
+	app.audit(c, database.AuditEntry{
+		Action:     auditPasswordChanged,
+		TargetType: "user",
+		TargetId:   strconv.Itoa(userObj.Id),
+		Details:    auditDetails(map[string]any{"forced": userObj.PasswordResetRequired}),
+	})
+	c.Status(http.StatusNoContent)
+}
+
 // google godoc
 // @Summary      Google OAuth login/signup
 // @Description  Exchanges Google OAuth2 code for user info, upserts the user, and returns JWT
@@ -177,14 +272,17 @@ func (app *app) login(c *gin.Context) {
 // @Produce      json
 // @Param        body  body      googleAuthRequest  true  "OAuth exchange request"
 // @Success      200   {object}  loginResponse
-// @Failure      400   {object}  errorResponse
-// @Failure      500   {object}  errorResponse
+// @Failure      400   {object}  problemDetails
+// @Failure      403   {object}  problemDetails
+// @Failure      413   {object}  problemDetails
+// @Failure      422   {object}  problemDetails
+// @Failure      500   {object}  problemDetails
 // @Router       /v1/auth/google [post]
 func (app *app) googleAuth(c *gin.Context) {
 	// load oauth
 	var req googleAuthRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, errorResponse{err.Error()})
+		bindProblem(c, err)
 		return
 	}
 
@@ -207,30 +305,51 @@ func (app *app) googleAuth(c *gin.Context) {
 		return
 	}
 
-	ctx := context.Background()
+	// Route the token exchange and the userinfo request through a traced
+	// transport so they show up as child spans of this request.
+	ctx := context.WithValue(c.Request.Context(), oauth2.HTTPClient, &http.Client{
+		Transport: otelhttp.NewTransport(http.DefaultTransport),
+		Timeout:   10 * time.Second,
+	})
 	googleToken, err := cfg.Exchange(ctx, authCode)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, errorResponse{"Failed to exchange token"})
+		app.metrics.LoginFailed("google", "exchange_failed")
+		app.auditLogin(c, nil, "", "google", "exchange_failed")
+		problem(c, http.StatusBadRequest, codeOAuthFailed, "Failed to exchange the authorization code")
 		return
 	}
 
 	client := cfg.Client(ctx, googleToken)
-	res, err := client.Get(GOOGLE_URI_OAUTH2_USERINFO)
-	if err != nil || res.StatusCode != http.StatusOK {
-		c.JSON(http.StatusInternalServerError, errorResponse{"Failed to get user info"})
+	infoReq, err := http.NewRequestWithContext(ctx, http.MethodGet, GOOGLE_URI_OAUTH2_USERINFO, nil)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to build user info request", "error", err)
+		problem(c, http.StatusInternalServerError, codeInternal, "Failed to get user info")
+		return
+	}
+	res, err := client.Do(infoReq)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to get user info", "error", err)
+		problem(c, http.StatusInternalServerError, codeInternal, "Failed to get user info")
 		return
 	}
 	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		slog.ErrorContext(c.Request.Context(), "failed to get user info", "status", res.StatusCode)
+		problem(c, http.StatusInternalServerError, codeInternal, "Failed to get user info")
+		return
+	}
 
 	var p googleProfile
 	if err := json.NewDecoder(res.Body).Decode(&p); err != nil {
-		c.JSON(http.StatusInternalServerError, errorResponse{"Failed to decode user info"})
+		slog.ErrorContext(c.Request.Context(), "failed to decode user info", "error", err)
+		problem(c, http.StatusInternalServerError, codeInternal, "Failed to decode user info")
 		return
 	}
 
-	user, err := app.models.Users.GetByEmail(p.Email)
+	user, err := app.models.Users.GetByEmail(ctx, p.Email)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, errorResponse{"Failed to retrieve user"})
+		slog.ErrorContext(c.Request.Context(), "failed to retrieve user", "error", err)
+		problem(c, http.StatusInternalServerError, codeInternal, "Failed to retrieve user")
 		return
 	}
 
@@ -242,25 +361,34 @@ func (app *app) googleAuth(c *gin.Context) {
 			Password: "",
 		}
 
-		if err = app.models.Users.Insert(user); err != nil {
-			c.JSON(http.StatusInternalServerError, errorResponse{"Failed to create user"})
+		if err = app.models.Users.Insert(ctx, user); err != nil {
+			slog.ErrorContext(c.Request.Context(), "failed to create user", "error", err)
+			problem(c, http.StatusInternalServerError, codeInternal, "Failed to create user")
 			return
 		}
+		app.audit(c, database.AuditEntry{
+			Action:     auditRegister,
+			ActorId:    &user.Id,
+			ActorEmail: user.Email,
+			Details:    auditDetails(map[string]any{"method": "google"}),
+		})
 	}
 
-	// Generate JWT token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"userId": user.Id,
-		"expr":   time.Now().Add(time.Hour * 3).Unix(),
-	})
+	if user.DisabledAt != nil {
+		app.metrics.LoginFailed("google", "disabled")
+		app.auditLogin(c, user, user.Email, "google", "disabled")
+		problem(c, http.StatusForbidden, codeAccountDisabled, "This account is disabled")
+		return
+	}
 
-	// Sign the token with the secret
-	tokenStr, err := token.SignedString([]byte(app.jwtSecret))
+	tokenStr, err := app.issueToken(user)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, errorResponse{"Failed to generate token"})
+		slog.ErrorContext(c.Request.Context(), "failed to generate token", "error", err)
+		problem(c, http.StatusInternalServerError, codeInternal, "Failed to generate token")
 		return
 	}
 
+	app.auditLogin(c, user, user.Email, "google", "")
 	c.JSON(http.StatusOK, loginResponse{
 		Token:  tokenStr,
 		UserID: user.Id,
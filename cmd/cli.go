@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/Aergiaaa/rollet/internal/database"
+	"github.com/Aergiaaa/rollet/internal/env"
+	"github.com/Aergiaaa/rollet/internal/live"
+	"github.com/Aergiaaa/rollet/internal/metrics"
+	"github.com/Aergiaaa/rollet/internal/ratelimit"
+	"github.com/Aergiaaa/rollet/internal/tracing"
+	"github.com/Aergiaaa/rollet/internal/webhook"
+)
+
+// command is a top-level subcommand of the rollet binary.
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) error
+}
+
+var errUsage = errors.New("invalid usage")
+
+func commands() []command {
+	return []command{
+		{"serve", "start the HTTP API server", serveCmd},
+		{"config", "print the effective configuration with secrets redacted", configCmd},
+		{"migrate", "manage database migrations", migrateCmd},
+		{"user", "create, list and delete users", userCmd},
+		{"randomize", "assign people from a file into teams offline", randomizeCmd},
+	}
+}
+
+// run dispatches args to a subcommand and returns the process exit code.
+// Without arguments the server is started, as it always has been.
+func run(args []string) int {
+	if len(args) == 0 {
+		args = []string{"serve"}
+	}
+
+	name := args[0]
+	if name == "help" || name == "-h" || name == "--help" {
+		printUsage(os.Stdout)
+		return 0
+	}
+
+	for _, cmd := range commands() {
+		if cmd.name != name {
+			continue
+		}
+
+		err := cmd.run(args[1:])
+		switch {
+		case err == nil, errors.Is(err, flag.ErrHelp):
+			return 0
+		case errors.Is(err, errUsage):
+			return 2
+		default:
+			slog.Error("command failed", "command", name, "error", err)
+			return 1
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+	printUsage(os.Stderr)
+	return 2
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: rollet <command> [flags] [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands() {
+		fmt.Fprintf(w, "  %-11s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `Run "rollet <command> -h" for the flags of a command.`)
+}
+
+// newFlagSet returns a flag set for a subcommand whose usage output starts
+// with the given synopsis.
+func newFlagSet(name, synopsis string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: rollet %s\n", synopsis)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseFlags parses args into fs and maps parse failures to errUsage, the
+// flag package having already printed the problem and the usage.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return errUsage
+	}
+	return nil
+}
+
+// databaseURLFlag registers the -database-url flag shared by all commands
+// that talk to Postgres.
+func databaseURLFlag(fs *flag.FlagSet) *string {
+	return fs.String("database-url", "", "Postgres connection URL (default env DATABASE_URL)")
+}
+
+// openDB opens Postgres at url, falling back to DATABASE_URL or
+// DATABASE_URL_FILE when url is empty.
+func openDB(url string) (*sql.DB, error) {
+	sources := []env.Source{env.Environ()}
+	if url != "" {
+		sources = append(sources, env.Map{"DATABASE_URL": url})
+	}
+
+	var cfg dbConfig
+	if err := env.Load(&cfg, sources...); err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("postgres", cfg.DatabaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to database: %w", err)
+	}
+
+	return db, nil
+}
+
+func serveCmd(args []string) error {
+	fs := newFlagSet("serve", "serve [flags]")
+	cfg, err := loadConfig(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := setupLogging(cfg.logConfig); err != nil {
+		return err
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		Exporter:    cfg.TracingExporter,
+		Endpoint:    cfg.TracingEndpoint,
+		SampleRatio: cfg.TracingSampleRatio,
+		ServiceName: "rollet",
+	})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// Flush spans of the last requests; bounded so a dead collector
+		// cannot hold up the exit.
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Warn("failed to flush traces", "error", err)
+		}
+	}()
+
+	db, err := openDB(cfg.DatabaseURL)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	expectedVersion, err := database.LatestMigration(database.MigrationsDir)
+	if err != nil {
+		return err
+	}
+
+	rateLimit, err := cfg.rateLimitOptions()
+	if err != nil {
+		return err
+	}
+	rateLimit.store = ratelimit.NewMemoryStore()
+
+	app := &app{
+		host:            cfg.Host,
+		port:            cfg.Port,
+		jwtSecret:       cfg.JWTSecret,
+		randomSource:    cfg.RandomSource,
+		randomSeed:      cfg.RandomSeed,
+		shutdownTimeout: cfg.ShutdownTimeout,
+		shutdownDelay:   cfg.ShutdownDelay,
+		expectedVersion: expectedVersion,
+		metricsToken:    cfg.MetricsToken,
+		tls: tlsOptions{
+			certFile:       cfg.TLSCertFile,
+			keyFile:        cfg.TLSKeyFile,
+			clientCAFile:   cfg.TLSClientCAFile,
+			clientAuth:     cfg.TLSClientAuth,
+			redirectPort:   cfg.TLSRedirectPort,
+			reloadInterval: cfg.TLSReloadInterval,
+		},
+		maxBodyBytes: cfg.MaxBodyBytes,
+		limits: drawLimits{
+			maxPeople:     cfg.MaxPeople,
+			maxTeams:      cfg.MaxTeams,
+			maxNameLength: cfg.MaxNameLength,
+			maxRoleLength: cfg.MaxRoleLength,
+		},
+		live:           live.NewHub(cfg.LiveSessionTTL, cfg.LiveMaxSessions),
+		cors:           cfg.corsOptions(),
+		rateLimit:      rateLimit,
+		trustedProxies: cfg.TrustedProxies,
+		db:             db,
+		models:         database.NewModels(db),
+		metrics:        metrics.New(db),
+	}
+	app.webhooks = webhook.NewWorker(app.models.Webhooks,
+		webhook.NewClient(cfg.WebhookTimeout, cfg.WebhookAllowPrivate), cfg.WebhookPollInterval, cfg.WebhookMaxAttempts)
+
+	// Deliveries interrupted by shutdown stay queued for the next start.
+	webhookCtx, stopWebhooks := context.WithCancel(context.Background())
+	webhooksDone := make(chan struct{})
+	go func() {
+		defer close(webhooksDone)
+		app.webhooks.Run(webhookCtx)
+	}()
+
+	// Sessions also expire when looked up; the sweep disconnects viewers
+	// of abandoned ones.
+	liveCtx, stopLive := context.WithCancel(context.Background())
+	go app.live.Run(liveCtx, min(cfg.LiveSessionTTL, liveSweepInterval))
+
+	err = app.serve(app.routes())
+	stopLive()
+	stopWebhooks()
+	<-webhooksDone
+	if err != nil {
+		return fmt.Errorf("error serving app: %w", err)
+	}
+
+	// Only close the pool once every request has finished with it.
+	if err := db.Close(); err != nil {
+		return fmt.Errorf("error closing database: %w", err)
+	}
+	slog.Info("Database connections closed")
+
+	return nil
+}
+
+func configCmd(args []string) error {
+	fs := newFlagSet("config", "config [flags]")
+	cfg, err := loadConfig(fs, args)
+	if cfg == nil {
+		return err
+	}
+
+	if perr := env.Print(os.Stdout, cfg); perr != nil {
+		return perr
+	}
+
+	return err
+}
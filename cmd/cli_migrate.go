@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/Aergiaaa/rollet/internal/database"
+)
+
+const migrateUsage = `Usage: rollet migrate [flags] <command> [argument]
+
+Commands:
+  up             apply all pending migrations
+  down [N]       roll back the last N migrations (default 1)
+  status         print the current version and dirty flag
+  goto N         migrate up or down to version N
+  steps N        apply N migrations, or roll back when N is negative
+  force N        set the version to N without running migrations
+  create NAME    create a new empty up/down migration pair
+
+Flags:
+`
+
+// migrateArgs holds the number of arguments each migrate command accepts.
+var migrateArgs = map[string][2]int{
+	"up":     {0, 0},
+	"down":   {0, 1},
+	"status": {0, 0},
+	"goto":   {1, 1},
+	"steps":  {1, 1},
+	"force":  {1, 1},
+	"create": {1, 1},
+}
+
+func migrateCmd(args []string) error {
+	fs := newFlagSet("migrate", "")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), migrateUsage)
+		fs.PrintDefaults()
+	}
+	dbURL := databaseURLFlag(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errUsage
+	}
+
+	cmd, args := fs.Arg(0), fs.Args()[1:]
+	bounds, ok := migrateArgs[cmd]
+	if !ok || len(args) < bounds[0] || len(args) > bounds[1] {
+		fs.Usage()
+		return errUsage
+	}
+
+	// create only touches the migrations directory
+	if cmd == "create" {
+		up, down, err := database.MigrationCreate(database.MigrationsDir, args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Created %s\n", up)
+		fmt.Printf("Created %s\n", down)
+		return nil
+	}
+
+	// Reject a bad argument before connecting
+	n, err := migrateNumber(cmd, args)
+	if err != nil {
+		return err
+	}
+
+	db, err := openDB(*dbURL)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	switch cmd {
+	case "up":
+		return database.MigrationUp(db)
+	case "down":
+		if err := database.MigrationSteps(db, -n); err != nil {
+			return err
+		}
+		fmt.Printf("Rolled back %d migration(s)\n", n)
+	case "status":
+		version, dirty, err := database.MigrationStatus(db)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("version: %d\ndirty: %t\n", version, dirty)
+	case "goto":
+		if err := database.MigrationGoto(db, uint(n)); err != nil {
+			return err
+		}
+		fmt.Printf("Migrated to version %d\n", n)
+	case "steps":
+		if err := database.MigrationSteps(db, n); err != nil {
+			return err
+		}
+		fmt.Printf("Applied %d step(s)\n", n)
+	case "force":
+		if err := database.MigrationForce(db, n); err != nil {
+			return err
+		}
+		fmt.Printf("Forced version %d\n", n)
+	}
+
+	return nil
+}
+
+// migrateNumber parses the numeric argument of cmd: the count for down,
+// which defaults to 1, the version for goto and force, or the steps. Other
+// commands take none and get 0.
+func migrateNumber(cmd string, args []string) (int, error) {
+	switch cmd {
+	case "down":
+		if len(args) == 0 {
+			return 1, nil
+		}
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 1 {
+			return 0, fmt.Errorf("down expects a positive number, got %q", args[0])
+		}
+		return n, nil
+	case "goto":
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("goto expects a version number, got %q", args[0])
+		}
+		return n, nil
+	case "steps":
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n == 0 {
+			return 0, fmt.Errorf("steps expects a non-zero number, got %q", args[0])
+		}
+		return n, nil
+	case "force":
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < -1 {
+			return 0, fmt.Errorf("force expects a version number, got %q", args[0])
+		}
+		return n, nil
+	}
+	return 0, nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMigrateNumber(t *testing.T) {
+	tests := []struct {
+		cmd     string
+		args    []string
+		want    int
+		wantErr bool
+	}{
+		{cmd: "up", want: 0},
+		{cmd: "down", want: 1},
+		{cmd: "down", args: []string{"3"}, want: 3},
+		{cmd: "down", args: []string{"0"}, wantErr: true},
+		{cmd: "down", args: []string{"-1"}, wantErr: true},
+		{cmd: "goto", args: []string{"7"}, want: 7},
+		{cmd: "goto", args: []string{"0"}, want: 0},
+		{cmd: "goto", args: []string{"-1"}, wantErr: true},
+		{cmd: "goto", args: []string{"v7"}, wantErr: true},
+		{cmd: "steps", args: []string{"2"}, want: 2},
+		{cmd: "steps", args: []string{"-2"}, want: -2},
+		{cmd: "steps", args: []string{"0"}, wantErr: true},
+		{cmd: "steps", args: []string{"two"}, wantErr: true},
+		{cmd: "force", args: []string{"5"}, want: 5},
+		{cmd: "force", args: []string{"-1"}, want: -1},
+		{cmd: "force", args: []string{"-2"}, wantErr: true},
+		{cmd: "force", args: []string{"1.5"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := migrateNumber(tt.cmd, tt.args)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("migrateNumber(%q, %q) = %d; want an error", tt.cmd, tt.args, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("migrateNumber(%q, %q) = %d, %v; want %d", tt.cmd, tt.args, got, err, tt.want)
+		}
+	}
+}
+
+// Bad arguments are rejected before a connection is attempted, so these
+// need no database.
+func TestMigrateCmdArguments(t *testing.T) {
+	usage := [][]string{
+		{},
+		{"sideways"},
+		{"up", "1"},
+		{"down", "1", "2"},
+		{"goto"},
+		{"steps"},
+		{"force"},
+		{"create"},
+	}
+	for _, args := range usage {
+		if err := migrateCmd(args); !errors.Is(err, errUsage) {
+			t.Errorf("migrateCmd(%q) = %v; want errUsage", args, err)
+		}
+	}
+
+	invalid := [][]string{
+		{"down", "0"},
+		{"goto", "latest"},
+		{"steps", "0"},
+		{"force", "-2"},
+	}
+	for _, args := range invalid {
+		err := migrateCmd(append([]string{"-database-url", "postgres://invalid"}, args...))
+		if err == nil || !strings.Contains(err.Error(), args[0]+" expects") {
+			t.Errorf("migrateCmd(%q) = %v; want an argument error", args, err)
+		}
+	}
+}
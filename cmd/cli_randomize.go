@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Aergiaaa/rollet/internal/database"
+	"github.com/Aergiaaa/rollet/internal/randomizer"
+)
+
+func randomizeCmd(args []string) error {
+	fs := newFlagSet("randomize", "randomize -file PATH -teams N [flags]")
+	file := fs.String("file", "", `people file, JSON array or CSV with name,role columns ("-" reads JSON from stdin)`)
+	teamCount := fs.Int("teams", 0, "number of teams (required)")
+	format := fs.String("format", "text", "output format: text or json")
+	source := fs.String("source", randomizer.SourceCrypto, "randomness: crypto, seeded or deterministic")
+	seed := fs.String("seed", "", "seed for the seeded source; a fresh seed is printed when empty")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if *file == "" || *teamCount < 1 || (*format != "text" && *format != "json") {
+		fs.Usage()
+		return errUsage
+	}
+
+	app := &app{randomSource: *source}
+	var err error
+	if app.randomSeed, err = parseSeed(*source, *seed); err != nil {
+		return err
+	}
+
+	inputs, err := readPeopleFile(*file)
+	if err != nil {
+		return err
+	}
+	if len(inputs) == 0 {
+		return errors.New("no people found in file")
+	}
+	normalizePeople(inputs)
+
+	src, drawSeed, err := app.newSource()
+	if err != nil {
+		return err
+	}
+
+	allPeople, err := randomize(inputs, *teamCount, src)
+	if err != nil {
+		return err
+	}
+
+	res := drawResponse(&database.Draw{
+		TeamCount: *teamCount,
+		Source:    *source,
+		Seed:      drawSeed,
+		People:    allPeople,
+	})
+
+	if *format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(res)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, team := range res.Teams {
+		fmt.Fprintf(w, "Team %d\n", team.Team)
+		for _, m := range team.Members {
+			fmt.Fprintf(w, "  %s\t%s\n", m.Name, m.Role)
+		}
+	}
+	fmt.Fprintf(w, "\n%d people in %d teams (source: %s", res.Total, len(res.Teams), res.Source)
+	if res.Seed != nil {
+		fmt.Fprintf(w, ", seed: %d", *res.Seed)
+	}
+	fmt.Fprintln(w, ")")
+	return w.Flush()
+}
+
+// readPeopleFile reads people from a JSON array of {"name","role"} objects
+// or, for .csv files, from a CSV whose header has name and role columns.
+func readPeopleFile(path string) ([]PersonInput, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		check := drawLimits{maxNameLength: maxColumnLength, maxRoleLength: maxColumnLength}.checkPerson
+		people, rowErrs, err := readPeopleCSV(r, defaultCSVMapping, check)
+		if err != nil {
+			return nil, err
+		}
+		if len(rowErrs) > 0 {
+			e := rowErrs[0]
+			return nil, fmt.Errorf("line %d: %s %s", e.Row, e.Field, e.Message)
+		}
+		return people, nil
+	}
+
+	var people []PersonInput
+	if err := json.NewDecoder(r).Decode(&people); err != nil {
+		return nil, fmt.Errorf("invalid people file: %w", err)
+	}
+	for i, p := range people {
+		if p.Name == "" || p.Role == "" {
+			return nil, fmt.Errorf("person %d: name and role are required", i+1)
+		}
+	}
+
+	return people, nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRandomizeCmdArguments(t *testing.T) {
+	usage := [][]string{
+		{},
+		{"-teams", "2"},
+		{"-file", "people.json"},
+		{"-file", "people.json", "-teams", "0"},
+		{"-file", "people.json", "-teams", "2", "-format", "yaml"},
+		{"-file", "people.json", "-teams", "two"},
+	}
+	for _, args := range usage {
+		if err := randomizeCmd(args); !errors.Is(err, errUsage) {
+			t.Errorf("randomizeCmd(%q) = %v; want errUsage", args, err)
+		}
+	}
+}
+
+func TestReadPeopleFile(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		want    []PersonInput
+		wantErr string
+	}{
+		{
+			name: "json",
+			path: write("people.json", `[{"name":"Ada","role":"dev"},{"name":"Grace","role":"ops"}]`),
+			want: []PersonInput{{Name: "Ada", Role: "dev"}, {Name: "Grace", Role: "ops"}},
+		},
+		{
+			name: "csv",
+			path: write("people.CSV", "name,role\nAda,dev\n"),
+			want: []PersonInput{{Name: "Ada", Role: "dev"}},
+		},
+		{name: "missing", path: filepath.Join(dir, "nobody.json"), wantErr: "no such file"},
+		{name: "not json", path: write("broken.json", `name,role`), wantErr: "invalid people file"},
+		{name: "not an array", path: write("object.json", `{"name":"Ada","role":"dev"}`), wantErr: "invalid people file"},
+		{name: "wrong type", path: write("type.json", `[{"name":"Ada","role":7}]`), wantErr: "invalid people file"},
+		{name: "missing role", path: write("role.json", `[{"name":"Ada","role":"dev"},{"name":"Grace"}]`), wantErr: "person 2: name and role are required"},
+		{name: "csv row error", path: write("rows.csv", "name,role\nAda,dev\n,ops\n"), wantErr: "line 3: name is required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readPeopleFile(tt.path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v; want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("people = %+v; want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Aergiaaa/rollet/internal/database"
+	"github.com/Aergiaaa/rollet/internal/rbac"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const userUsage = `Usage: rollet user <command> [flags]
+
+Commands:
+  create           create a password user
+  list             list all users
+  delete           delete a user and everything they saved
+  reset-password   set a new password for a user
+  set-role         set the system-wide role of a user, e.g. admin
+
+Run "rollet user <command> -h" for the flags of a command.
+`
+
+const minPasswordLength = 8
+
+func userCmd(args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, userUsage)
+		return errUsage
+	}
+
+	switch args[0] {
+	case "create":
+		return userCreateCmd(args[1:])
+	case "list":
+		return userListCmd(args[1:])
+	case "delete":
+		return userDeleteCmd(args[1:])
+	case "reset-password":
+		return userResetPasswordCmd(args[1:])
+	case "set-role":
+		return userSetRoleCmd(args[1:])
+	case "help", "-h", "--help":
+		fmt.Fprint(os.Stdout, userUsage)
+		return nil
+	default:
+		fmt.Fprint(os.Stderr, userUsage)
+		return errUsage
+	}
+}
+
+func userCreateCmd(args []string) error {
+	fs := newFlagSet("user create", "user create -email EMAIL -name NAME [-password-stdin]")
+	email := fs.String("email", "", "email address of the user (required)")
+	name := fs.String("name", "", "display name of the user (required)")
+	passwordStdin := fs.Bool("password-stdin", false, "read the password from the first line of stdin; generated and printed otherwise")
+	dbURL := databaseURLFlag(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if *email == "" || *name == "" {
+		fs.Usage()
+		return errUsage
+	}
+
+	password, err := readPasswordFlag(*passwordStdin)
+	if err != nil {
+		return err
+	}
+
+	models, closeDB, err := openModels(*dbURL)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	existing, err := models.Users.GetByEmail(context.Background(), *email)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve user: %w", err)
+	}
+	if existing != nil {
+		return errors.New("a user with this email already exists")
+	}
+
+	plain, hash, err := preparePassword(password)
+	if err != nil {
+		return err
+	}
+
+	user := database.User{
+		Email:    *email,
+		Name:     *name,
+		Password: hash,
+	}
+	err = models.Users.Insert(context.Background(), &user)
+	if errors.Is(err, database.ErrDuplicateEmail) {
+		return errors.New("a user with this email already exists")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+
+	auditCLI(models, auditUser(auditUserCreated, &user, map[string]any{"email": user.Email}))
+	fmt.Printf("Created user %d (%s)\n", user.Id, user.Email)
+	if password == "" {
+		fmt.Printf("Generated password: %s\n", plain)
+	}
+	return nil
+}
+
+func userListCmd(args []string) error {
+	fs := newFlagSet("user list", "user list [flags]")
+	dbURL := databaseURLFlag(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	models, closeDB, err := openModels(*dbURL)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	users, err := models.Users.List(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to list users: %w", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tEMAIL\tNAME\tLOGIN\tROLE\tSTATUS")
+	for _, u := range users {
+		login := "password"
+		if u.GoogleID != "" {
+			login = "google"
+		}
+		status := "active"
+		if u.DisabledAt != nil {
+			status = "disabled"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", u.Id, u.Email, u.Name, login, u.Role, status)
+	}
+	return w.Flush()
+}
+
+func userDeleteCmd(args []string) error {
+	fs := newFlagSet("user delete", "user delete (-id ID | -email EMAIL)")
+	id := fs.Int("id", 0, "id of the user")
+	email := fs.String("email", "", "email address of the user")
+	dbURL := databaseURLFlag(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	models, closeDB, err := openModels(*dbURL)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	user, err := findUser(models, fs, *id, *email)
+	if err != nil {
+		return err
+	}
+
+	err = models.Users.Delete(context.Background(), user.Id)
+	var lastOwner *database.LastOwnerError
+	if errors.As(err, &lastOwner) {
+		return fmt.Errorf("the user is the last owner of %q; it needs another owner or must be deleted first", lastOwner.OrgName)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+
+	auditCLI(models, auditUser(auditUserDeleted, user, map[string]any{"email": user.Email}))
+	fmt.Printf("Deleted user %d (%s)\n", user.Id, user.Email)
+	return nil
+}
+
+func userResetPasswordCmd(args []string) error {
+	fs := newFlagSet("user reset-password", "user reset-password (-id ID | -email EMAIL) [-password-stdin]")
+	id := fs.Int("id", 0, "id of the user")
+	email := fs.String("email", "", "email address of the user")
+	passwordStdin := fs.Bool("password-stdin", false, "read the new password from the first line of stdin; generated and printed otherwise")
+	dbURL := databaseURLFlag(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	password, err := readPasswordFlag(*passwordStdin)
+	if err != nil {
+		return err
+	}
+
+	models, closeDB, err := openModels(*dbURL)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	user, err := findUser(models, fs, *id, *email)
+	if err != nil {
+		return err
+	}
+
+	plain, hash, err := preparePassword(password)
+	if err != nil {
+		return err
+	}
+
+	if err := models.Users.UpdatePassword(context.Background(), user.Id, hash); err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+
+	auditCLI(models, auditUser(auditPasswordReset, user, nil))
+	fmt.Printf("Password reset for user %d (%s)\n", user.Id, user.Email)
+	if password == "" {
+		fmt.Printf("Generated password: %s\n", plain)
+	}
+	return nil
+}
+
+func userSetRoleCmd(args []string) error {
+	fs := newFlagSet("user set-role", "user set-role (-id ID | -email EMAIL) -role ROLE")
+	id := fs.Int("id", 0, "id of the user")
+	email := fs.String("email", "", "email address of the user")
+	role := fs.String("role", "", "one of "+strings.Join(rbac.Roles, ", ")+" (required)")
+	dbURL := databaseURLFlag(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if !rbac.Valid(*role) {
+		fs.Usage()
+		return errUsage
+	}
+
+	models, closeDB, err := openModels(*dbURL)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	user, err := findUser(models, fs, *id, *email)
+	if err != nil {
+		return err
+	}
+
+	if err := models.Users.SetRole(context.Background(), user.Id, *role); err != nil {
+		return fmt.Errorf("failed to update role: %w", err)
+	}
+
+	auditCLI(models, auditUser(auditRoleChanged, user, map[string]any{"from": user.Role, "to": *role}))
+	fmt.Printf("User %d (%s) is now %s\n", user.Id, user.Email, *role)
+	return nil
+}
+
+func openModels(url string) (database.Models, func() error, error) {
+	db, err := openDB(url)
+	if err != nil {
+		return database.Models{}, nil, err
+	}
+
+	return database.NewModels(db), db.Close, nil
+}
+
+// auditCLI appends e to the audit log with the CLI as its actor. A failure
+// is reported but does not fail the command, which has already taken
+// effect.
+func auditCLI(models database.Models, e database.AuditEntry) {
+	e.Outcome = database.OutcomeSuccess
+	e.ActorEmail = auditCLIActor
+	if err := models.Audit.Insert(context.Background(), &e); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to write audit entry: %v\n", err)
+	}
+}
+
+// findUser looks a user up by exactly one of id or email.
+func findUser(models database.Models, fs *flag.FlagSet, id int, email string) (*database.User, error) {
+	if (id == 0) == (email == "") {
+		fs.Usage()
+		return nil, errUsage
+	}
+
+	var user *database.User
+	var err error
+	if id != 0 {
+		user, err = models.Users.Get(context.Background(), id)
+	} else {
+		user, err = models.Users.GetByEmail(context.Background(), email)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve user: %w", err)
+	}
+	if user == nil {
+		return nil, errors.New("user not found")
+	}
+
+	return user, nil
+}
+
+// readPasswordFlag reads the password from stdin when fromStdin is set, so
+// it stays out of the shell history and the process list. It returns ""
+// otherwise, for preparePassword to generate one.
+func readPasswordFlag(fromStdin bool) (string, error) {
+	if !fromStdin {
+		return "", nil
+	}
+	return readPassword(os.Stdin)
+}
+
+// readPassword returns the first line of r without its line ending.
+func readPassword(r io.Reader) (string, error) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("failed to read password: %w", err)
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", errors.New("no password on stdin")
+	}
+	return password, nil
+}
+
+// preparePassword validates password, generating one when it is empty, and
+// returns the plain text together with its bcrypt hash.
+func preparePassword(password string) (string, string, error) {
+	if password == "" {
+		buf := make([]byte, 12)
+		if _, err := rand.Read(buf); err != nil {
+			return "", "", fmt.Errorf("failed to generate password: %w", err)
+		}
+		password = base64.RawURLEncoding.EncodeToString(buf)
+	}
+
+	if len(password) < minPasswordLength {
+		return "", "", errors.New("password must be at least 8 characters")
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	return password, string(hash), nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/Aergiaaa/rollet/internal/database"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Bad arguments are rejected before a connection is attempted, so these
+// need no database.
+func TestUserCmdArguments(t *testing.T) {
+	usage := [][]string{
+		{},
+		{"rename"},
+		{"create"},
+		{"create", "-email", "ada@example.com"},
+		{"create", "-email", "ada@example.com", "-name", "Ada", "-password", "hunter22"},
+		{"delete", "-database-url", "postgres://invalid"},
+		{"delete", "-database-url", "postgres://invalid", "-id", "1", "-email", "ada@example.com"},
+		{"reset-password", "-database-url", "postgres://invalid"},
+		{"reset-password", "-id", "1", "-password", "hunter22"},
+		{"set-role", "-id", "1"},
+		{"set-role", "-id", "1", "-role", "superuser"},
+		{"list", "-bogus"},
+	}
+	for _, args := range usage {
+		if err := userCmd(args); !errors.Is(err, errUsage) {
+			t.Errorf("userCmd(%q) = %v; want errUsage", args, err)
+		}
+	}
+
+	if err := userCmd([]string{"create", "-h"}); !errors.Is(err, flag.ErrHelp) {
+		t.Errorf("userCmd(create -h) = %v; want flag.ErrHelp", err)
+	}
+}
+
+func TestFindUser(t *testing.T) {
+	ada := &database.User{Id: 1, Email: "ada@example.com"}
+	grace := &database.User{Id: 2, Email: "grace@example.com"}
+	models := database.Models{Users: &stubUsers{users: map[int]*database.User{1: ada, 2: grace}}}
+
+	tests := []struct {
+		name    string
+		id      int
+		email   string
+		want    *database.User
+		wantErr error
+	}{
+		{name: "by id", id: 2, want: grace},
+		{name: "by email", email: "ada@example.com", want: ada},
+		{name: "neither", wantErr: errUsage},
+		{name: "both", id: 1, email: "ada@example.com", wantErr: errUsage},
+		{name: "unknown id", id: 3},
+		{name: "unknown email", email: "alan@example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := newFlagSet("user delete", "user delete (-id ID | -email EMAIL)")
+			fs.SetOutput(io.Discard)
+
+			got, err := findUser(models, fs, tt.id, tt.email)
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("error = %v; want %v", err, tt.wantErr)
+				}
+			case tt.want == nil:
+				if err == nil || err.Error() != "user not found" {
+					t.Errorf("findUser = %v, %v; want user not found", got, err)
+				}
+			case err != nil || got != tt.want:
+				t.Errorf("findUser = %v, %v; want %v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadPassword(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "hunter22\n", want: "hunter22"},
+		{in: "hunter22\r\nsecond line\n", want: "hunter22"},
+		{in: "hunter22", want: "hunter22"},
+		{in: "  spaced out  \n", want: "  spaced out  "},
+		{in: "", wantErr: true},
+		{in: "\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := readPassword(strings.NewReader(tt.in))
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("readPassword(%q) = %q; want an error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("readPassword(%q) = %q, %v; want %q", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestPreparePassword(t *testing.T) {
+	if _, _, err := preparePassword("short"); err == nil {
+		t.Error("preparePassword accepted a 5 character password")
+	}
+
+	plain, hash, err := preparePassword("hunter22")
+	if err != nil || plain != "hunter22" {
+		t.Fatalf("preparePassword = %q, %v; want hunter22", plain, err)
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(plain)); err != nil {
+		t.Errorf("hash does not match the password: %v", err)
+	}
+
+	generated, hash, err := preparePassword("")
+	if err != nil || len(generated) < minPasswordLength {
+		t.Fatalf("preparePassword(\"\") = %q, %v; want a generated password", generated, err)
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(generated)); err != nil {
+		t.Errorf("hash does not match the generated password: %v", err)
+	}
+}
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Aergiaaa/rollet/internal/env"
+	"github.com/Aergiaaa/rollet/internal/logging"
+	"github.com/Aergiaaa/rollet/internal/randomizer"
+	"github.com/Aergiaaa/rollet/internal/ratelimit"
+	"github.com/Aergiaaa/rollet/internal/tracing"
+)
+
+const (
+	envDevelopment = "development"
+	envTest        = "test"
+	envProduction  = "production"
+
+	// defaultJWTSecret keeps local development working without any setup.
+	// It is public, so production refuses to start with it.
+	defaultJWTSecret = "apakah-apakah-bukan-ini-bukan-secret-kamu"
+
+	minJWTSecretLength = 32
+)
+
+// dbConfig is the configuration shared by every command that talks to
+// Postgres.
+type dbConfig struct {
+	DatabaseURL string `env:"DATABASE_URL" required:"true" secret:"true" usage:"Postgres connection URL"`
+}
+
+// logConfig selects the log level and format. It is read from the
+// environment at startup and again with the full configuration by serve.
+type logConfig struct {
+	LogLevel  string `env:"LOG_LEVEL" default:"info" usage:"debug, info, warn or error"`
+	LogFormat string `env:"LOG_FORMAT" default:"json" usage:"json or text"`
+}
+
+// config is the configuration of the serve command, loaded with env.Load.
+type config struct {
+	Env          string `env:"APP_ENV" default:"development" usage:"development, test or production"`
+	Host         string `env:"HOST" default:"" usage:"address to listen on; all interfaces when empty"`
+	Port         int    `env:"PORT" default:"8080" usage:"port to listen on"`
+	JWTSecret    string `env:"JWT_SECRET" secret:"true" usage:"secret used to sign login tokens; a public development secret when empty"`
+	RandomSource string `env:"RANDOM_SOURCE" default:"crypto" usage:"randomness for draws: crypto, seeded or deterministic"`
+	RandomSeed   *int64 `env:"RANDOM_SEED" usage:"fixed seed for the seeded source; a fresh seed per draw when empty"`
+
+	ShutdownTimeout time.Duration `env:"SHUTDOWN_TIMEOUT" default:"30s" usage:"how long to wait for in-flight requests on shutdown"`
+	ShutdownDelay   time.Duration `env:"SHUTDOWN_DELAY" default:"0s" usage:"how long to report not ready before draining starts"`
+
+	MetricsToken string `env:"METRICS_TOKEN" secret:"true" usage:"bearer token required to read /metrics; open when empty"`
+
+	TLSCertFile       string        `env:"TLS_CERT_FILE" usage:"PEM certificate; enables HTTPS and HTTP/2 when set"`
+	TLSKeyFile        string        `env:"TLS_KEY_FILE" usage:"PEM private key for TLS_CERT_FILE"`
+	TLSClientCAFile   string        `env:"TLS_CLIENT_CA_FILE" usage:"PEM CA bundle used to verify client certificates"`
+	TLSClientAuth     string        `env:"TLS_CLIENT_AUTH" default:"none" usage:"client certificates: none, request, verify (if given) or require"`
+	TLSRedirectPort   int           `env:"TLS_REDIRECT_PORT" default:"0" usage:"port that redirects plain HTTP to HTTPS; disabled when 0"`
+	TLSReloadInterval time.Duration `env:"TLS_RELOAD_INTERVAL" default:"1m" usage:"how often to check the certificate files for changes"`
+
+	CORSAllowedOrigins   []string      `env:"CORS_ALLOWED_ORIGINS" default:"*" usage:"origins allowed to call the API: *, or a list of origins such as https://app.example.com and https://*.example.com"`
+	CORSAllowedMethods   []string      `env:"CORS_ALLOWED_METHODS" default:"GET,POST,PUT,PATCH,DELETE,OPTIONS" usage:"methods allowed in cross-origin requests"`
+	CORSAllowedHeaders   []string      `env:"CORS_ALLOWED_HEADERS" default:"Origin,Content-Type,Authorization,X-Request-ID,X-Share-Password,X-Host-Token,Last-Event-ID,X-API-Key" usage:"request headers allowed in cross-origin requests"`
+	CORSExposedHeaders   []string      `env:"CORS_EXPOSED_HEADERS" default:"X-Request-ID,RateLimit-Limit,RateLimit-Remaining,RateLimit-Reset,RateLimit-Policy,Retry-After" usage:"response headers readable by cross-origin callers"`
+	CORSAllowCredentials bool          `env:"CORS_ALLOW_CREDENTIALS" default:"false" usage:"allow cookies and credentials; requires an explicit origin list"`
+	CORSMaxAge           time.Duration `env:"CORS_MAX_AGE" default:"12h" usage:"how long browsers may cache preflight responses"`
+
+	MaxBodyBytes  int64 `env:"MAX_BODY_BYTES" default:"1048576" usage:"largest request body accepted, in bytes"`
+	MaxPeople     int   `env:"MAX_PEOPLE" default:"1000" usage:"most people in one draw"`
+	MaxTeams      int   `env:"MAX_TEAMS" default:"100" usage:"most teams in one draw"`
+	MaxNameLength int   `env:"MAX_NAME_LENGTH" default:"100" usage:"longest person name, in characters"`
+	MaxRoleLength int   `env:"MAX_ROLE_LENGTH" default:"50" usage:"longest role, in characters"`
+
+	LiveSessionTTL  time.Duration `env:"LIVE_SESSION_TTL" default:"2h" usage:"how long a live draw session lasts without host activity"`
+	LiveMaxSessions int           `env:"LIVE_MAX_SESSIONS" default:"1000" usage:"most live draw sessions held at once"`
+
+	WebhookTimeout      time.Duration `env:"WEBHOOK_TIMEOUT" default:"10s" usage:"how long to wait for a webhook subscriber to respond"`
+	WebhookMaxAttempts  int           `env:"WEBHOOK_MAX_ATTEMPTS" default:"8" usage:"how many times a webhook delivery is tried before it fails"`
+	WebhookPollInterval time.Duration `env:"WEBHOOK_POLL_INTERVAL" default:"5s" usage:"how often to look for webhook deliveries that are due"`
+	WebhookAllowPrivate bool          `env:"WEBHOOK_ALLOW_PRIVATE" default:"false" usage:"allow webhook deliveries to loopback and private addresses, for development"`
+
+	RateLimitDefault string   `env:"RATE_LIMIT_DEFAULT" default:"120/m" usage:"requests per client and route: N/s, N/m, N/h, N/<duration> or none"`
+	RateLimitRoutes  []string `env:"RATE_LIMIT_ROUTES" default:"POST /v1/random/default=30/m,POST /v1/auth/login=10/m,POST /v1/auth/register=5/m,POST /v1/auth/google=10/m,GET /v1/share/:slug=30/m,POST /v1/live=10/m,PUT /v1/user/password=10/m" usage:"per-route limits as METHOD /path=LIMIT"`
+	RateLimitStore   string   `env:"RATE_LIMIT_STORE" default:"memory" usage:"where rate limit state is kept: memory"`
+	TrustedProxies   []string `env:"TRUSTED_PROXIES" usage:"IPs or CIDRs of proxies whose X-Forwarded-For is trusted for the client IP"`
+
+	TracingExporter    string  `env:"TRACING_EXPORTER" default:"none" usage:"span exporter: none, stdout or otlp"`
+	TracingEndpoint    string  `env:"TRACING_OTLP_ENDPOINT" usage:"OTLP/HTTP collector URL; defaults to the OTEL_EXPORTER_OTLP_* variables"`
+	TracingSampleRatio float64 `env:"TRACING_SAMPLE_RATIO" default:"1" usage:"fraction of new traces to record, from 0 to 1"`
+
+	dbConfig
+	logConfig
+}
+
+// loadConfig registers the configuration flags and -config on fs, parses
+// args and loads the configuration in layers: config file, then
+// environment, then flags. On a load error the partially filled config is
+// returned as well so it can still be inspected.
+func loadConfig(fs *flag.FlagSet, args []string) (*config, error) {
+	var cfg config
+
+	configFile := fs.String("config", "", "path to a YAML or TOML config file (env CONFIG_FILE)")
+	flags, err := env.Flags(fs, &cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return nil, err
+	}
+
+	path := *configFile
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+
+	var sources []env.Source
+	if path != "" {
+		file, err := env.File(path)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, file)
+	}
+	sources = append(sources, env.Environ(), flags)
+
+	if err := env.Load(&cfg, sources...); err != nil {
+		return &cfg, fmt.Errorf("invalid configuration: %w", err)
+	}
+	if cfg.JWTSecret == "" {
+		cfg.JWTSecret = defaultJWTSecret
+	}
+
+	return &cfg, nil
+}
+
+func (cfg *config) production() bool {
+	return cfg.Env == envProduction
+}
+
+func (cfg *config) Validate() error {
+	var errs []error
+
+	switch cfg.Env {
+	case envDevelopment, envTest, envProduction:
+	default:
+		errs = append(errs, fmt.Errorf("APP_ENV must be one of %s, %s or %s", envDevelopment, envTest, envProduction))
+	}
+
+	if _, err := logging.ParseLevel(cfg.LogLevel); err != nil {
+		errs = append(errs, fmt.Errorf("LOG_LEVEL: %w", err))
+	}
+	if cfg.LogFormat != "json" && cfg.LogFormat != "text" {
+		errs = append(errs, errors.New("LOG_FORMAT must be json or text"))
+	}
+
+	if cfg.Port < 1 || cfg.Port > 65535 {
+		errs = append(errs, errors.New("PORT must be between 1 and 65535"))
+	}
+
+	if cfg.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("SHUTDOWN_TIMEOUT must be positive"))
+	}
+	if cfg.ShutdownDelay < 0 {
+		errs = append(errs, errors.New("SHUTDOWN_DELAY must not be negative"))
+	}
+
+	errs = append(errs, cfg.validateTLS()...)
+	errs = append(errs, cfg.corsOptions().validate()...)
+	errs = append(errs, cfg.validateTracing()...)
+	errs = append(errs, cfg.validateRateLimit()...)
+	errs = append(errs, cfg.validateLimits()...)
+
+	if err := checkSource(cfg.RandomSource, cfg.RandomSeed); err != nil {
+		errs = append(errs, err)
+	}
+
+	if cfg.production() {
+		if cfg.JWTSecret == defaultJWTSecret {
+			errs = append(errs, errors.New("JWT_SECRET must be changed from the default in production"))
+		} else if len(cfg.JWTSecret) < minJWTSecretLength {
+			errs = append(errs, fmt.Errorf("JWT_SECRET must be at least %d characters in production", minJWTSecretLength))
+		}
+		if cfg.MetricsToken == "" {
+			errs = append(errs, errors.New("METRICS_TOKEN must be set in production"))
+		}
+		if cfg.RandomSource == randomizer.SourceDeterministic {
+			errs = append(errs, errors.New("RANDOM_SOURCE deterministic is not allowed in production"))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (cfg *config) validateTLS() []error {
+	var errs []error
+
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		errs = append(errs, errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together"))
+	}
+
+	clientAuth, ok := clientAuthTypes[cfg.TLSClientAuth]
+	if !ok {
+		errs = append(errs, errors.New("TLS_CLIENT_AUTH must be one of none, request, verify or require"))
+	}
+
+	if cfg.TLSCertFile == "" {
+		if cfg.TLSClientCAFile != "" || clientAuth != tls.NoClientCert || cfg.TLSRedirectPort != 0 {
+			errs = append(errs, errors.New("TLS_CLIENT_CA_FILE, TLS_CLIENT_AUTH and TLS_REDIRECT_PORT require TLS_CERT_FILE"))
+		}
+		return errs
+	}
+
+	if (clientAuth == tls.VerifyClientCertIfGiven || clientAuth == tls.RequireAndVerifyClientCert) && cfg.TLSClientCAFile == "" {
+		errs = append(errs, errors.New("TLS_CLIENT_AUTH verify and require need TLS_CLIENT_CA_FILE"))
+	}
+	if cfg.TLSRedirectPort < 0 || cfg.TLSRedirectPort > 65535 || cfg.TLSRedirectPort == cfg.Port {
+		errs = append(errs, errors.New("TLS_REDIRECT_PORT must be a free port other than PORT"))
+	}
+	if cfg.TLSReloadInterval <= 0 {
+		errs = append(errs, errors.New("TLS_RELOAD_INTERVAL must be positive"))
+	}
+
+	return errs
+}
+
+func (cfg *config) corsOptions() corsOptions {
+	return corsOptions{
+		allowedOrigins:   cfg.CORSAllowedOrigins,
+		allowedMethods:   cfg.CORSAllowedMethods,
+		allowedHeaders:   cfg.CORSAllowedHeaders,
+		exposedHeaders:   cfg.CORSExposedHeaders,
+		allowCredentials: cfg.CORSAllowCredentials,
+		maxAge:           cfg.CORSMaxAge,
+	}
+}
+
+func (cfg *config) validateLimits() []error {
+	var errs []error
+
+	if cfg.MaxBodyBytes < 1024 {
+		errs = append(errs, errors.New("MAX_BODY_BYTES must be at least 1024"))
+	}
+	if cfg.MaxPeople < 1 {
+		errs = append(errs, errors.New("MAX_PEOPLE must be positive"))
+	}
+	if cfg.MaxTeams < 1 {
+		errs = append(errs, errors.New("MAX_TEAMS must be positive"))
+	}
+	if cfg.MaxNameLength < 1 || cfg.MaxNameLength > maxColumnLength {
+		errs = append(errs, fmt.Errorf("MAX_NAME_LENGTH must be between 1 and %d", maxColumnLength))
+	}
+	if cfg.MaxRoleLength < 1 || cfg.MaxRoleLength > maxColumnLength {
+		errs = append(errs, fmt.Errorf("MAX_ROLE_LENGTH must be between 1 and %d", maxColumnLength))
+	}
+	if cfg.LiveSessionTTL <= 0 {
+		errs = append(errs, errors.New("LIVE_SESSION_TTL must be positive"))
+	}
+	if cfg.LiveMaxSessions < 1 {
+		errs = append(errs, errors.New("LIVE_MAX_SESSIONS must be positive"))
+	}
+	if cfg.WebhookTimeout <= 0 {
+		errs = append(errs, errors.New("WEBHOOK_TIMEOUT must be positive"))
+	}
+	if cfg.WebhookMaxAttempts < 1 {
+		errs = append(errs, errors.New("WEBHOOK_MAX_ATTEMPTS must be positive"))
+	}
+	if cfg.WebhookPollInterval <= 0 {
+		errs = append(errs, errors.New("WEBHOOK_POLL_INTERVAL must be positive"))
+	}
+
+	return errs
+}
+
+// rateLimitOptions parses the rate limit settings. The store is left for
+// the caller to create.
+func (cfg *config) rateLimitOptions() (rateLimitOptions, error) {
+	def, err := ratelimit.ParseLimit(cfg.RateLimitDefault)
+	if err != nil {
+		return rateLimitOptions{}, fmt.Errorf("RATE_LIMIT_DEFAULT: %w", err)
+	}
+	routes, err := parseRouteLimits(cfg.RateLimitRoutes)
+	if err != nil {
+		return rateLimitOptions{}, fmt.Errorf("RATE_LIMIT_ROUTES: %w", err)
+	}
+
+	return rateLimitOptions{defaultLimit: def, routes: routes}, nil
+}
+
+func (cfg *config) validateRateLimit() []error {
+	var errs []error
+
+	if _, err := cfg.rateLimitOptions(); err != nil {
+		errs = append(errs, err)
+	}
+	if cfg.RateLimitStore != rateLimitStoreMemory {
+		errs = append(errs, fmt.Errorf("RATE_LIMIT_STORE must be %s", rateLimitStoreMemory))
+	}
+	for _, p := range cfg.TrustedProxies {
+		if net.ParseIP(p) == nil {
+			if _, _, err := net.ParseCIDR(p); err != nil {
+				errs = append(errs, fmt.Errorf("TRUSTED_PROXIES: %q is not an IP or CIDR", p))
+			}
+		}
+	}
+
+	return errs
+}
+
+func (cfg *config) validateTracing() []error {
+	var errs []error
+
+	switch cfg.TracingExporter {
+	case tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOTLP:
+	default:
+		errs = append(errs, fmt.Errorf("TRACING_EXPORTER must be one of %s, %s or %s", tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOTLP))
+	}
+	if cfg.TracingEndpoint != "" {
+		if u, err := url.Parse(cfg.TracingEndpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, errors.New("TRACING_OTLP_ENDPOINT must be an http or https URL"))
+		}
+	}
+	if cfg.TracingSampleRatio < 0 || cfg.TracingSampleRatio > 1 {
+		errs = append(errs, errors.New("TRACING_SAMPLE_RATIO must be between 0 and 1"))
+	}
+
+	return errs
+}
+
+// checkSource validates the randomness source name and that a fixed seed is
+// only given to the seeded source.
+func checkSource(source string, seed *int64) error {
+	if _, err := randomizer.NewNamedSource(source, 0); err != nil {
+		return err
+	}
+
+	if seed == nil {
+		return nil
+	}
+	if source != randomizer.SourceSeeded {
+		return fmt.Errorf("a seed requires the %s source", randomizer.SourceSeeded)
+	}
+	if *seed < 0 {
+		return errors.New("seed must not be negative")
+	}
+
+	return nil
+}
+
+// parseSeed parses an optional seed given on the command line and checks it
+// against the randomness source.
+func parseSeed(source, seed string) (*int64, error) {
+	var n *int64
+	if seed != "" {
+		v, err := strconv.ParseInt(seed, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid seed %q: must be a non-negative integer", seed)
+		}
+		n = &v
+	}
+
+	if err := checkSource(source, n); err != nil {
+		return nil, err
+	}
+
+	return n, nil
+}
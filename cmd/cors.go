@@ -0,0 +1,174 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/cors"
+)
+
+// corsOptions is the cross-origin policy applied to every route.
+type corsOptions struct {
+	allowedOrigins   []string
+	allowedMethods   []string
+	allowedHeaders   []string
+	exposedHeaders   []string
+	allowCredentials bool
+	maxAge           time.Duration
+}
+
+// corsMethods are the methods a policy may allow.
+var corsMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodOptions: true,
+}
+
+// originPattern is an allowed origin: an exact scheme://host[:port], or one
+// whose host starts with "*." to allow any subdomain of the rest.
+type originPattern struct {
+	scheme   string
+	host     string
+	port     string
+	wildcard bool
+}
+
+// parseOrigin parses an origin or origin pattern. Origins have no path,
+// query or user info, so any of those is an error.
+func parseOrigin(s string) (originPattern, error) {
+	scheme, rest, ok := strings.Cut(strings.ToLower(s), "://")
+	if !ok || (scheme != "http" && scheme != "https") {
+		return originPattern{}, fmt.Errorf("origin %q must start with http:// or https://", s)
+	}
+	if rest == "" || strings.ContainsAny(rest, "/?#@") {
+		return originPattern{}, fmt.Errorf("origin %q must be scheme://host[:port] without a path", s)
+	}
+
+	p := originPattern{scheme: scheme, host: rest}
+	if i := strings.LastIndexByte(rest, ':'); i >= 0 && !strings.HasSuffix(rest, "]") {
+		p.host, p.port = rest[:i], rest[i+1:]
+		if p.port == "" || strings.Trim(p.port, "0123456789") != "" {
+			return originPattern{}, fmt.Errorf("origin %q has an invalid port", s)
+		}
+	}
+
+	if suffix, ok := strings.CutPrefix(p.host, "*."); ok {
+		p.host, p.wildcard = "."+suffix, true
+	}
+	if p.host == "" || p.host == "." || strings.Contains(p.host, "*") {
+		return originPattern{}, fmt.Errorf("origin %q may only use * as the whole leftmost label, e.g. https://*.example.com", s)
+	}
+
+	return p, nil
+}
+
+// matches reports whether origin, as sent by a browser, is allowed by p. A
+// wildcard pattern matches subdomains at any depth but not the bare domain.
+func (p originPattern) matches(origin originPattern) bool {
+	if origin.wildcard || origin.scheme != p.scheme || origin.port != p.port {
+		return false
+	}
+	if p.wildcard {
+		return len(origin.host) > len(p.host) && strings.HasSuffix(origin.host, p.host)
+	}
+	return origin.host == p.host
+}
+
+func (o corsOptions) validate() []error {
+	var errs []error
+
+	for _, origin := range o.allowedOrigins {
+		if origin == "*" {
+			if len(o.allowedOrigins) > 1 {
+				errs = append(errs, errors.New("CORS_ALLOWED_ORIGINS: * cannot be combined with other origins"))
+			}
+			if o.allowCredentials {
+				errs = append(errs, errors.New("CORS_ALLOW_CREDENTIALS cannot be used with CORS_ALLOWED_ORIGINS *; list the origins instead"))
+			}
+			continue
+		}
+		if _, err := parseOrigin(origin); err != nil {
+			errs = append(errs, fmt.Errorf("CORS_ALLOWED_ORIGINS: %w", err))
+		}
+	}
+
+	for _, m := range o.allowedMethods {
+		if !corsMethods[m] {
+			errs = append(errs, fmt.Errorf("CORS_ALLOWED_METHODS: unknown method %q", m))
+		}
+	}
+	for _, h := range o.allowedHeaders {
+		if !validHeaderName(h) {
+			errs = append(errs, fmt.Errorf("CORS_ALLOWED_HEADERS: invalid header name %q", h))
+		}
+	}
+	for _, h := range o.exposedHeaders {
+		if !validHeaderName(h) {
+			errs = append(errs, fmt.Errorf("CORS_EXPOSED_HEADERS: invalid header name %q", h))
+		}
+	}
+
+	if o.maxAge < 0 {
+		errs = append(errs, errors.New("CORS_MAX_AGE must not be negative"))
+	}
+
+	return errs
+}
+
+// config returns the gin CORS configuration for o, which must have passed
+// validate. Cross-origin requests from origins that are not allowed are
+// refused with 403.
+func (o corsOptions) config() cors.Config {
+	cfg := cors.Config{
+		AllowMethods:     o.allowedMethods,
+		AllowHeaders:     o.allowedHeaders,
+		ExposeHeaders:    o.exposedHeaders,
+		AllowCredentials: o.allowCredentials,
+		MaxAge:           o.maxAge,
+	}
+
+	if len(o.allowedOrigins) == 1 && o.allowedOrigins[0] == "*" {
+		cfg.AllowAllOrigins = true
+		return cfg
+	}
+
+	patterns := make([]originPattern, 0, len(o.allowedOrigins))
+	for _, s := range o.allowedOrigins {
+		if p, err := parseOrigin(s); err == nil {
+			patterns = append(patterns, p)
+		}
+	}
+	cfg.AllowOriginFunc = func(s string) bool {
+		origin, err := parseOrigin(s)
+		if err != nil {
+			return false
+		}
+		for _, p := range patterns {
+			if p.matches(origin) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return cfg
+}
+
+func validHeaderName(h string) bool {
+	if h == "" {
+		return false
+	}
+	for _, r := range h {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
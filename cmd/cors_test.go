@@ -0,0 +1,78 @@
+package main
+
+import "testing"
+
+func TestParseOrigin(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    originPattern
+		wantErr bool
+	}{
+		{in: "https://example.com", want: originPattern{scheme: "https", host: "example.com"}},
+		{in: "HTTP://Example.com:8080", want: originPattern{scheme: "http", host: "example.com", port: "8080"}},
+		{in: "https://*.example.com", want: originPattern{scheme: "https", host: ".example.com", wildcard: true}},
+		{in: "http://[::1]", want: originPattern{scheme: "http", host: "[::1]"}},
+		{in: "http://[::1]:3000", want: originPattern{scheme: "http", host: "[::1]", port: "3000"}},
+		{in: "example.com", wantErr: true},
+		{in: "ftp://example.com", wantErr: true},
+		{in: "https://", wantErr: true},
+		{in: "https://example.com/", wantErr: true},
+		{in: "https://example.com?x", wantErr: true},
+		{in: "https://user@example.com", wantErr: true},
+		{in: "https://example.com:", wantErr: true},
+		{in: "https://example.com:80a", wantErr: true},
+		{in: "https://*", wantErr: true},
+		{in: "https://*.", wantErr: true},
+		{in: "https://a.*.example.com", wantErr: true},
+		{in: "https://*example.com", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseOrigin(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseOrigin(%q) = %+v; want an error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("parseOrigin(%q) = %+v, %v; want %+v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestOriginMatches(t *testing.T) {
+	tests := []struct {
+		pattern, origin string
+		want            bool
+	}{
+		{"https://example.com", "https://example.com", true},
+		{"https://example.com", "https://EXAMPLE.com", true},
+		{"https://example.com", "https://www.example.com", false},
+		{"https://*.example.com", "https://app.example.com", true},
+		{"https://*.example.com", "https://a.b.example.com", true},
+		{"https://*.example.com", "https://example.com", false},
+		{"https://*.example.com", "https://evil-example.com", false},
+		{"https://*.example.com", "https://example.com.evil.com", false},
+		{"https://*.example.com", "http://app.example.com", false},
+		{"https://*.example.com", "https://app.example.com:8443", false},
+		{"https://example.com:8443", "https://example.com:8443", true},
+		{"https://example.com:8443", "https://example.com", false},
+		{"https://example.com", "http://example.com", false},
+		{"http://[::1]:3000", "http://[::1]:3000", true},
+		{"http://[::1]:3000", "http://[::1]", false},
+		{"http://[::1]", "http://[::2]", false},
+	}
+	for _, tt := range tests {
+		p, err := parseOrigin(tt.pattern)
+		if err != nil {
+			t.Fatalf("parseOrigin(%q): %v", tt.pattern, err)
+		}
+		origin, err := parseOrigin(tt.origin)
+		if err != nil {
+			t.Fatalf("parseOrigin(%q): %v", tt.origin, err)
+		}
+		if got := p.matches(origin); got != tt.want {
+			t.Errorf("%q matches %q = %v; want %v", tt.pattern, tt.origin, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxRowErrors caps the row errors reported for one CSV, so a file in the
+// wrong format doesn't produce an error per line.
+const maxRowErrors = 50
+
+// csvMapping names the CSV columns holding each person's name and role, and
+// the extra columns to keep as attributes.
+type csvMapping struct {
+	name       string
+	role       string
+	attributes []string
+}
+
+var defaultCSVMapping = csvMapping{name: "name", role: "role"}
+
+// errMissingColumn is returned by readPeopleCSV when a mapped column is not
+// in the header.
+type errMissingColumn struct {
+	param  string
+	column string
+}
+
+func (e *errMissingColumn) Error() string {
+	return fmt.Sprintf("CSV header has no %q column", e.column)
+}
+
+// readPeopleCSV reads people from a CSV whose header names the mapped
+// columns, matched case-insensitively. Each bad line is reported as a
+// fieldError with its line number; err is only set when the CSV cannot be
+// read at all.
+func readPeopleCSV(r io.Reader, m csvMapping, check func(*PersonInput) []fieldError) (people []PersonInput, rowErrs []fieldError, err error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid CSV header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, h := range header {
+		h = strings.ToLower(normalizeText(h))
+		if _, dup := columns[h]; !dup {
+			columns[h] = i
+		}
+	}
+	column := func(param, name string) (int, error) {
+		i, ok := columns[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return 0, &errMissingColumn{param: param, column: name}
+		}
+		return i, nil
+	}
+
+	nameCol, err := column("name_column", m.name)
+	if err != nil {
+		return nil, nil, err
+	}
+	roleCol, err := column("role_column", m.role)
+	if err != nil {
+		return nil, nil, err
+	}
+	attrCols := make([]int, len(m.attributes))
+	for i, a := range m.attributes {
+		if attrCols[i], err = column("attributes", a); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for len(rowErrs) < maxRowErrors {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			rowErrs = append(rowErrs, fieldError{Row: parseErr.Line, Code: "csv", Message: parseErr.Err.Error()})
+			continue
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid CSV: %w", err)
+		}
+		line, _ := cr.FieldPos(0)
+
+		field := func(col int) string {
+			if col < len(record) {
+				return record[col]
+			}
+			return ""
+		}
+		p := PersonInput{Name: field(nameCol), Role: field(roleCol)}
+		for i, a := range m.attributes {
+			if v := field(attrCols[i]); strings.TrimSpace(v) != "" {
+				if p.Attributes == nil {
+					p.Attributes = make(map[string]string, len(m.attributes))
+				}
+				p.Attributes[a] = v
+			}
+		}
+
+		errs := check(&p)
+		for _, e := range errs {
+			switch e.Field {
+			case "name":
+				e.Field = m.name
+			case "role":
+				e.Field = m.role
+			}
+			e.Row = line
+			rowErrs = append(rowErrs, e)
+		}
+		if len(errs) == 0 {
+			people = append(people, p)
+		}
+	}
+
+	return people, rowErrs, nil
+}
+
+// importRandomize godoc
+// @Summary      Randomly assign people from a CSV into teams
+// @Description  Reads people from a CSV, sent as the body with Content-Type text/csv or as the file field of a multipart form, and assigns them like /v1/random/default. The header row names the columns; name_column, role_column and attributes select them. Options are query parameters, or form fields for multipart uploads. Bad lines are reported with their line number. On /v1/user/random/import the draw is also saved to the user's history.
+// @Tags         people
+// @Accept       text/csv
+// @Accept       mpfd
+// @Produce      json,text/csv,application/vnd.openxmlformats-officedocument.spreadsheetml.sheet,text/markdown,application/pdf
+// @Param        file         formData  file    false  "CSV file, for multipart uploads"
+// @Param        team_count   query     int     true   "Number of teams"
+// @Param        name_column  query     string  false  "Column holding names"  default(name)
+// @Param        role_column  query     string  false  "Column holding roles"  default(role)
+// @Param        attributes   query     string  false  "Comma-separated extra columns to keep"
+// @Param        format       query     string  false  "Response format, overriding the Accept header"  Enums(json, csv, xlsx, md, pdf)
+// @Success      200   {object}  RandomizeResponse
+// @Failure      400   {object}  problemDetails
+// @Failure      401   {object}  problemDetails
+// @Failure      406   {object}  problemDetails
+// @Failure      413   {object}  problemDetails
+// @Failure      415   {object}  problemDetails
+// @Failure      422   {object}  problemDetails
+// @Failure      500   {object}  problemDetails
+// @Router       /v1/random/import [post]
+// @Router       /v1/user/random/import [post]
+func (app *app) importRandomize(c *gin.Context) {
+	var body io.Reader
+	param := c.Query
+	switch c.ContentType() {
+	case "text/csv":
+		body = c.Request.Body
+	case "multipart/form-data":
+		fh, err := c.FormFile("file")
+		if err != nil {
+			formProblem(c, err)
+			return
+		}
+		f, err := fh.Open()
+		if err != nil {
+			formProblem(c, err)
+			return
+		}
+		defer f.Close()
+		body = f
+		param = func(key string) string {
+			if v, ok := c.GetPostForm(key); ok {
+				return v
+			}
+			return c.Query(key)
+		}
+	default:
+		problem(c, http.StatusUnsupportedMediaType, codeUnsupportedMediaType, "Send the CSV as text/csv or as the file field of multipart/form-data")
+		return
+	}
+
+	teamCount, err := strconv.Atoi(param("team_count"))
+	if err != nil || teamCount < 1 {
+		problem(c, http.StatusUnprocessableEntity, codeValidationFailed, "The request has invalid fields",
+			fieldError{Field: "team_count", Code: "min", Message: "must be an integer of at least 1"})
+		return
+	}
+
+	m := defaultCSVMapping
+	if v := param("name_column"); v != "" {
+		m.name = v
+	}
+	if v := param("role_column"); v != "" {
+		m.role = v
+	}
+	for a := range strings.SplitSeq(param("attributes"), ",") {
+		if a = strings.TrimSpace(a); a != "" {
+			m.attributes = append(m.attributes, a)
+		}
+	}
+	if len(m.attributes) > maxAttributes {
+		problem(c, http.StatusUnprocessableEntity, codeValidationFailed, "The request has invalid fields",
+			fieldError{Field: "attributes", Code: "max", Message: "must have at most " + strconv.Itoa(maxAttributes) + " items"})
+		return
+	}
+
+	people, rowErrs, err := readPeopleCSV(body, m, app.limits.checkPerson)
+	var missing *errMissingColumn
+	var sizeErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &sizeErr):
+		tooLarge(c, sizeErr.Limit)
+		return
+	case errors.As(err, &missing):
+		problem(c, http.StatusUnprocessableEntity, codeValidationFailed, "The request has invalid fields",
+			fieldError{Field: missing.param, Code: "column", Message: missing.Error()})
+		return
+	case err != nil:
+		problem(c, http.StatusBadRequest, codeInvalidRequest, "The CSV could not be read: "+err.Error())
+		return
+	case len(rowErrs) > 0:
+		problem(c, http.StatusUnprocessableEntity, codeValidationFailed, "Some lines of the CSV are invalid", rowErrs...)
+		return
+	case len(people) == 0:
+		problem(c, http.StatusUnprocessableEntity, codeValidationFailed, "The request has invalid fields",
+			fieldError{Field: "people", Code: "required", Message: "the CSV has no people"})
+		return
+	}
+
+	app.runDraw(c, RandomizeRequest{People: people, TeamCount: teamCount})
+}
+
+// formProblem responds to an error reading a multipart upload.
+func formProblem(c *gin.Context, err error) {
+	var sizeErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &sizeErr):
+		tooLarge(c, sizeErr.Limit)
+	case errors.Is(err, http.ErrMissingFile):
+		problem(c, http.StatusUnprocessableEntity, codeValidationFailed, "The request has invalid fields",
+			fieldError{Field: "file", Code: "required", Message: "is required"})
+	default:
+		problem(c, http.StatusBadRequest, codeInvalidRequest, "The multipart form could not be read")
+	}
+}
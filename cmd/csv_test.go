@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestReadPeopleCSV(t *testing.T) {
+	limits := newTestApp().limits
+
+	tests := []struct {
+		name       string
+		csv        string
+		mapping    csvMapping
+		want       []PersonInput
+		wantErrs   []fieldError
+		wantColumn *errMissingColumn
+		wantErr    bool
+	}{
+		{
+			name:    "default columns",
+			csv:     "name,role\nAnn,dev\nBob,qa\n",
+			mapping: defaultCSVMapping,
+			want:    []PersonInput{{Name: "Ann", Role: "dev"}, {Name: "Bob", Role: "qa"}},
+		},
+		{
+			name:    "header case and spacing are ignored",
+			csv:     "  NAME , Role,Team\nAnn,dev,1\n",
+			mapping: defaultCSVMapping,
+			want:    []PersonInput{{Name: "Ann", Role: "dev"}},
+		},
+		{
+			name:    "mapped columns match case-insensitively",
+			csv:     "Full Name,Job\nAnn,dev\n",
+			mapping: csvMapping{name: "full name", role: "JOB"},
+			want:    []PersonInput{{Name: "Ann", Role: "dev"}},
+		},
+		{
+			name:    "values are trimmed and normalized",
+			csv:     "name,role\n José ,dev\n",
+			mapping: defaultCSVMapping,
+			want:    []PersonInput{{Name: "José", Role: "dev"}},
+		},
+		{
+			name:    "the first of duplicate columns wins",
+			csv:     "name,role,name\nAnn,dev,Other\n",
+			mapping: defaultCSVMapping,
+			want:    []PersonInput{{Name: "Ann", Role: "dev"}},
+		},
+		{
+			name:    "attributes keep non-empty values under the mapped name",
+			csv:     "name,role,Shirt,Team\nAnn,dev,M,red\nBob,qa,,blue\nCy,pm,,\n",
+			mapping: csvMapping{name: "name", role: "role", attributes: []string{"Shirt", "team"}},
+			want: []PersonInput{
+				{Name: "Ann", Role: "dev", Attributes: map[string]string{"Shirt": "M", "team": "red"}},
+				{Name: "Bob", Role: "qa", Attributes: map[string]string{"team": "blue"}},
+				{Name: "Cy", Role: "pm"},
+			},
+		},
+		{
+			name:       "missing name column",
+			csv:        "person,role\nAnn,dev\n",
+			mapping:    defaultCSVMapping,
+			wantColumn: &errMissingColumn{param: "name_column", column: "name"},
+		},
+		{
+			name:       "missing role column",
+			csv:        "name\nAnn\n",
+			mapping:    defaultCSVMapping,
+			wantColumn: &errMissingColumn{param: "role_column", column: "role"},
+		},
+		{
+			name:       "missing attribute column",
+			csv:        "name,role\nAnn,dev\n",
+			mapping:    csvMapping{name: "name", role: "role", attributes: []string{"shirt"}},
+			wantColumn: &errMissingColumn{param: "attributes", column: "shirt"},
+		},
+		{
+			name:    "empty input",
+			csv:     "",
+			mapping: defaultCSVMapping,
+			wantErr: true,
+		},
+		{
+			name:    "row errors carry the line and the mapped column",
+			csv:     "Who,What\nAnn,dev\n,qa\nCy\n",
+			mapping: csvMapping{name: "who", role: "what"},
+			want:    []PersonInput{{Name: "Ann", Role: "dev"}},
+			wantErrs: []fieldError{
+				{Field: "who", Row: 3, Code: "required", Message: "is required"},
+				{Field: "what", Row: 4, Code: "required", Message: "is required"},
+			},
+		},
+		{
+			name:    "lines of quoted fields spanning lines",
+			csv:     "name,role\n\"Ann\nBee\",dev\n,qa\n",
+			mapping: defaultCSVMapping,
+			wantErrs: []fieldError{
+				{Field: "name", Row: 2, Code: "printable", Message: "must not contain control characters"},
+				{Field: "name", Row: 4, Code: "required", Message: "is required"},
+			},
+		},
+		{
+			name:    "too long values",
+			csv:     "name,role\n" + strings.Repeat("a", 21) + ",dev\n",
+			mapping: defaultCSVMapping,
+			wantErrs: []fieldError{
+				{Field: "name", Row: 2, Code: "max", Message: "must be at most 20 characters long"},
+			},
+		},
+		{
+			name:    "malformed quoting",
+			csv:     "name,role\nAnn,dev\nB\"ob,qa\nCy,pm\n",
+			mapping: defaultCSVMapping,
+			want:    []PersonInput{{Name: "Ann", Role: "dev"}, {Name: "Cy", Role: "pm"}},
+			wantErrs: []fieldError{
+				{Row: 3, Code: "csv", Message: csv.ErrBareQuote.Error()},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			people, rowErrs, err := readPeopleCSV(strings.NewReader(tt.csv), tt.mapping, limits.checkPerson)
+
+			var missing *errMissingColumn
+			switch {
+			case tt.wantColumn != nil:
+				if !errors.As(err, &missing) || *missing != *tt.wantColumn {
+					t.Fatalf("err = %v; want missing %+v", err, tt.wantColumn)
+				}
+				return
+			case tt.wantErr:
+				if err == nil {
+					t.Fatal("err = nil; want an error")
+				}
+				return
+			case err != nil:
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(people, tt.want) {
+				t.Errorf("people = %+v; want %+v", people, tt.want)
+			}
+			if !reflect.DeepEqual(rowErrs, tt.wantErrs) {
+				t.Errorf("row errors = %+v; want %+v", rowErrs, tt.wantErrs)
+			}
+		})
+	}
+}
+
+func TestReadPeopleCSVCapsRowErrors(t *testing.T) {
+	input := "name,role\n" + strings.Repeat(",\n", maxRowErrors)
+	_, rowErrs, err := readPeopleCSV(strings.NewReader(input), defaultCSVMapping, newTestApp().limits.checkPerson)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Each line breaks two rules; reading stops once the cap is reached
+	if len(rowErrs) != maxRowErrors {
+		t.Errorf("%d row errors; want %d", len(rowErrs), maxRowErrors)
+	}
+	if last := rowErrs[len(rowErrs)-1]; last.Row != maxRowErrors/2+1 {
+		t.Errorf("last error on line %d; want %d", last.Row, maxRowErrors/2+1)
+	}
+}
+
+func TestImportRandomize(t *testing.T) {
+	app := newTestApp()
+	r := gin.New()
+	r.Use(app.BodyLimitMiddleware())
+	r.POST("/v1/random/import", app.importRandomize)
+
+	multipartBody := func(fields map[string]string, file string) (string, *bytes.Buffer) {
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		for k, v := range fields {
+			mw.WriteField(k, v)
+		}
+		if file != "" {
+			fw, _ := mw.CreateFormFile("file", "people.csv")
+			fw.Write([]byte(file))
+		}
+		mw.Close()
+		return mw.FormDataContentType(), &buf
+	}
+
+	tests := []struct {
+		name        string
+		query       string
+		contentType string
+		body        string
+		multipart   map[string]string
+		file        string
+		wantStatus  int
+		wantField   string
+		wantTotal   int
+	}{
+		{name: "text/csv", query: "team_count=2", contentType: "text/csv", body: "name,role\nAnn,dev\nBob,qa\nCy,pm\n", wantStatus: http.StatusOK, wantTotal: 3},
+		{name: "text/csv with charset", query: "team_count=1", contentType: "text/csv; charset=utf-8", body: "name,role\nAnn,dev\n", wantStatus: http.StatusOK, wantTotal: 1},
+		{name: "mapped columns from the query", query: "team_count=1&name_column=Who&role_column=What&attributes=shirt", contentType: "text/csv", body: "who,what,shirt\nAnn,dev,M\n", wantStatus: http.StatusOK, wantTotal: 1},
+		{name: "multipart with form fields", multipart: map[string]string{"team_count": "2", "name_column": "who"}, file: "who,role\nAnn,dev\nBob,qa\n", wantStatus: http.StatusOK, wantTotal: 2},
+		{name: "multipart falls back to the query", query: "team_count=1", multipart: map[string]string{}, file: "name,role\nAnn,dev\n", wantStatus: http.StatusOK, wantTotal: 1},
+		{name: "multipart without a file", query: "team_count=1", multipart: map[string]string{}, wantStatus: http.StatusUnprocessableEntity, wantField: "file"},
+		{name: "unsupported content type", query: "team_count=1", contentType: "application/json", body: `{}`, wantStatus: http.StatusUnsupportedMediaType},
+		{name: "missing team count", contentType: "text/csv", body: "name,role\nAnn,dev\n", wantStatus: http.StatusUnprocessableEntity, wantField: "team_count"},
+		{name: "zero team count", query: "team_count=0", contentType: "text/csv", body: "name,role\nAnn,dev\n", wantStatus: http.StatusUnprocessableEntity, wantField: "team_count"},
+		{name: "too many attributes", query: "team_count=1&attributes=" + strings.Repeat("a,", maxAttributes) + "z", contentType: "text/csv", body: "name,role\n", wantStatus: http.StatusUnprocessableEntity, wantField: "attributes"},
+		{name: "missing column", query: "team_count=1&role_column=job", contentType: "text/csv", body: "name,role\nAnn,dev\n", wantStatus: http.StatusUnprocessableEntity, wantField: "role_column"},
+		{name: "invalid rows", query: "team_count=1", contentType: "text/csv", body: "name,role\n,dev\n", wantStatus: http.StatusUnprocessableEntity, wantField: "name"},
+		{name: "no people", query: "team_count=1", contentType: "text/csv", body: "name,role\n", wantStatus: http.StatusUnprocessableEntity, wantField: "people"},
+		{name: "empty body", query: "team_count=1", contentType: "text/csv", body: "", wantStatus: http.StatusBadRequest},
+		{name: "too many people", query: "team_count=1", contentType: "text/csv", body: "name,role\n" + strings.Repeat("Ann,dev\n", 101), wantStatus: http.StatusUnprocessableEntity, wantField: "people"},
+		{name: "body over the limit", query: "team_count=1", contentType: "text/csv", body: "name,role\n" + strings.Repeat("Ann,dev\n", 1<<14), wantStatus: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			contentType, body := tt.contentType, bytes.NewBufferString(tt.body)
+			if tt.multipart != nil {
+				contentType, body = multipartBody(tt.multipart, tt.file)
+			}
+
+			w := do(r, http.MethodPost, "/v1/random/import?"+tt.query, contentType, body)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d; want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+
+			if tt.wantStatus == http.StatusOK {
+				var res RandomizeResponse
+				if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+					t.Fatal(err)
+				}
+				if res.Total != tt.wantTotal {
+					t.Errorf("total = %d; want %d", res.Total, tt.wantTotal)
+				}
+				return
+			}
+
+			p := decodeProblem(t, w)
+			if tt.wantField == "" {
+				return
+			}
+			var fields []string
+			for _, e := range p.Errors {
+				fields = append(fields, e.Field)
+			}
+			if len(fields) == 0 || fields[0] != tt.wantField {
+				t.Errorf("fields = %v; want %s first", fields, tt.wantField)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+
+	"github.com/Aergiaaa/rollet/internal/database"
+	"github.com/Aergiaaa/rollet/internal/export"
+	"github.com/gin-gonic/gin"
+)
+
+// exportFormat picks the response format for draws from ?format=, or else
+// from the Accept header. It returns nil for JSON. When ok is false a
+// problem response has been sent.
+func exportFormat(c *gin.Context) (format *export.Format, ok bool) {
+	if name := c.Query("format"); name != "" {
+		if name == "json" {
+			return nil, true
+		}
+		f, found := export.Lookup(name)
+		if !found {
+			problem(c, http.StatusUnprocessableEntity, codeValidationFailed, "The request has invalid fields",
+				fieldError{Field: "format", Code: "oneof", Message: "must be one of json, csv, xlsx, md or pdf"})
+			return nil, false
+		}
+		return &f, true
+	}
+
+	c.Header("Vary", "Accept")
+	if c.GetHeader("Accept") == "" {
+		return nil, true
+	}
+
+	offers := []string{gin.MIMEJSON}
+	for _, f := range export.Formats {
+		mediaType, _, _ := mime.ParseMediaType(f.ContentType)
+		offers = append(offers, mediaType)
+	}
+	switch chosen := c.NegotiateFormat(offers...); chosen {
+	case "":
+		problem(c, http.StatusNotAcceptable, codeNotAcceptable,
+			"Accept one of application/json, text/csv, text/markdown, application/pdf or the XLSX media type")
+		return nil, false
+	case gin.MIMEJSON:
+		return nil, true
+	default:
+		for _, f := range export.Formats {
+			if mediaType, _, _ := mime.ParseMediaType(f.ContentType); mediaType == chosen {
+				return &f, true
+			}
+		}
+		return nil, true
+	}
+}
+
+// writeExport renders draws in format as a download named name.
+func writeExport(c *gin.Context, format export.Format, name string, draws []*database.Draw) {
+	docs := make([]export.Draw, len(draws))
+	for i, d := range draws {
+		docs[i] = exportDraw(d)
+	}
+
+	var buf bytes.Buffer
+	if err := format.Write(&buf, docs); err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to export draws", "format", format.Name, "error", err)
+		problem(c, http.StatusInternalServerError, codeInternal, "Failed to export draws")
+		return
+	}
+
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name + format.Extension}))
+	c.Data(http.StatusOK, format.ContentType, buf.Bytes())
+}
+
+func exportDraw(d *database.Draw) export.Draw {
+	doc := export.Draw{ID: d.Id, CreatedAt: d.CreatedAt, Source: d.Source}
+	for _, t := range groupTeams(d.People, d.TeamCount) {
+		team := export.Team{Number: t.Team, Members: make([]export.Member, len(t.Members))}
+		for i, p := range t.Members {
+			team.Members[i] = export.Member{Name: p.Name, Role: p.Role, Attributes: p.Attributes}
+		}
+		doc.Teams = append(doc.Teams, team)
+	}
+	return doc
+}
+
+// drawFilename names the download of a single draw.
+func drawFilename(d *database.Draw) string {
+	if d.Id == 0 {
+		return "teams"
+	}
+	return fmt.Sprintf("draw-%d", d.Id)
+}
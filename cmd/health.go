@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Aergiaaa/rollet/internal/database"
+	"github.com/gin-gonic/gin"
+)
+
+const readinessTimeout = 2 * time.Second
+
+type checkResult struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type healthResponse struct {
+	Status string                 `json:"status"`
+	Checks map[string]checkResult `json:"checks,omitempty"`
+}
+
+// healthz godoc
+// @Summary      Liveness probe
+// @Description  Reports whether the process is running; it stays up while requests drain during shutdown
+// @Tags         health
+// @Produce      json
+// @Success      200  {object}  healthResponse
+// @Failure      503  {object}  healthResponse
+// @Router       /healthz [get]
+func (app *app) healthz(c *gin.Context) {
+	if !app.lifecycle.alive() {
+		c.JSON(http.StatusServiceUnavailable, healthResponse{Status: app.lifecycle.String()})
+		return
+	}
+
+	c.JSON(http.StatusOK, healthResponse{Status: "ok"})
+}
+
+// readyz godoc
+// @Summary      Readiness probe
+// @Description  Checks the database connection and schema version and reports each check with its latency
+// @Tags         health
+// @Produce      json
+// @Success      200  {object}  healthResponse
+// @Failure      503  {object}  healthResponse
+// @Router       /readyz [get]
+func (app *app) readyz(c *gin.Context) {
+	if !app.lifecycle.ready() {
+		c.JSON(http.StatusServiceUnavailable, healthResponse{Status: app.lifecycle.String()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+	defer cancel()
+
+	checks := map[string]func(context.Context) error{
+		"database":   app.checkDatabase,
+		"migrations": app.checkMigrations,
+	}
+
+	res := healthResponse{Status: "ok", Checks: make(map[string]checkResult, len(checks))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Go(func() {
+			start := time.Now()
+			err := check(ctx)
+			result := checkResult{
+				Status:    "ok",
+				LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+			}
+			if err != nil {
+				result.Status = "fail"
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			res.Checks[name] = result
+			mu.Unlock()
+		})
+	}
+	wg.Wait()
+
+	status := http.StatusOK
+	for _, result := range res.Checks {
+		if result.Status != "ok" {
+			res.Status = "fail"
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	c.JSON(status, res)
+}
+
+func (app *app) checkDatabase(ctx context.Context) error {
+	return app.db.PingContext(ctx)
+}
+
+// checkMigrations fails unless the database schema is exactly at the
+// version this build ships migrations for.
+func (app *app) checkMigrations(ctx context.Context) error {
+	version, dirty, err := database.SchemaVersion(ctx, app.db)
+	if err != nil {
+		return err
+	}
+	if dirty {
+		return fmt.Errorf("schema version %d is dirty", version)
+	}
+	if version != app.expectedVersion {
+		return fmt.Errorf("schema version %d, expected %d", version, app.expectedVersion)
+	}
+
+	return nil
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// healthDB is a database/sql driver that answers the readiness checks: a
+// ping and the schema_migrations query.
+type healthDB struct {
+	pingErr error
+	version int64
+	dirty   bool
+	// noVersion answers the migrations query with no rows.
+	noVersion bool
+}
+
+func (d *healthDB) Connect(context.Context) (driver.Conn, error) { return healthConn{d}, nil }
+func (d *healthDB) Driver() driver.Driver                        { return nil }
+
+type healthConn struct{ d *healthDB }
+
+func (c healthConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("healthdb: prepare") }
+func (c healthConn) Close() error                        { return nil }
+func (c healthConn) Begin() (driver.Tx, error)           { return nil, errors.New("healthdb: begin") }
+func (c healthConn) Ping(context.Context) error          { return c.d.pingErr }
+
+func (c healthConn) QueryContext(context.Context, string, []driver.NamedValue) (driver.Rows, error) {
+	if c.d.pingErr != nil {
+		return nil, c.d.pingErr
+	}
+	rows := &healthRows{}
+	if !c.d.noVersion {
+		rows.row = []driver.Value{c.d.version, c.d.dirty}
+	}
+	return rows, nil
+}
+
+type healthRows struct{ row []driver.Value }
+
+func (r *healthRows) Columns() []string { return []string{"version", "dirty"} }
+func (r *healthRows) Close() error      { return nil }
+
+func (r *healthRows) Next(dest []driver.Value) error {
+	if r.row == nil {
+		return io.EOF
+	}
+	copy(dest, r.row)
+	r.row = nil
+	return nil
+}
+
+func TestReadyz(t *testing.T) {
+	tests := []struct {
+		name       string
+		state      int32
+		db         healthDB
+		wantStatus int
+		want       healthResponse
+	}{
+		{
+			name:       "ready",
+			state:      stateReady,
+			db:         healthDB{version: 14},
+			wantStatus: http.StatusOK,
+			want:       healthResponse{Status: "ok", Checks: map[string]checkResult{"database": {Status: "ok"}, "migrations": {Status: "ok"}}},
+		},
+		{
+			name:       "database down",
+			state:      stateReady,
+			db:         healthDB{pingErr: errors.New("connection refused")},
+			wantStatus: http.StatusServiceUnavailable,
+			want: healthResponse{Status: "fail", Checks: map[string]checkResult{
+				"database":   {Status: "fail", Error: "connection refused"},
+				"migrations": {Status: "fail", Error: "connection refused"},
+			}},
+		},
+		{
+			name:       "schema behind",
+			state:      stateReady,
+			db:         healthDB{version: 13},
+			wantStatus: http.StatusServiceUnavailable,
+			want: healthResponse{Status: "fail", Checks: map[string]checkResult{
+				"database":   {Status: "ok"},
+				"migrations": {Status: "fail", Error: "schema version 13, expected 14"},
+			}},
+		},
+		{
+			name:       "schema ahead",
+			state:      stateReady,
+			db:         healthDB{version: 15},
+			wantStatus: http.StatusServiceUnavailable,
+			want: healthResponse{Status: "fail", Checks: map[string]checkResult{
+				"database":   {Status: "ok"},
+				"migrations": {Status: "fail", Error: "schema version 15, expected 14"},
+			}},
+		},
+		{
+			name:       "dirty schema",
+			state:      stateReady,
+			db:         healthDB{version: 14, dirty: true},
+			wantStatus: http.StatusServiceUnavailable,
+			want: healthResponse{Status: "fail", Checks: map[string]checkResult{
+				"database":   {Status: "ok"},
+				"migrations": {Status: "fail", Error: "schema version 14 is dirty"},
+			}},
+		},
+		{
+			name:       "never migrated",
+			state:      stateReady,
+			db:         healthDB{noVersion: true},
+			wantStatus: http.StatusServiceUnavailable,
+			want: healthResponse{Status: "fail", Checks: map[string]checkResult{
+				"database":   {Status: "ok"},
+				"migrations": {Status: "fail", Error: "schema version 0, expected 14"},
+			}},
+		},
+		{
+			name:       "starting",
+			state:      stateStarting,
+			wantStatus: http.StatusServiceUnavailable,
+			want:       healthResponse{Status: "starting"},
+		},
+		{
+			name:       "draining",
+			state:      stateDraining,
+			wantStatus: http.StatusServiceUnavailable,
+			want:       healthResponse{Status: "draining"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApp()
+			app.expectedVersion = 14
+			app.lifecycle.set(tt.state)
+			app.db = sql.OpenDB(&tt.db)
+			defer app.db.Close()
+
+			r := gin.New()
+			r.GET("/readyz", app.readyz)
+			w := do(r, http.MethodGet, "/readyz", "", nil)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d; want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+
+			var got healthResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			// Latencies vary from run to run
+			for name, c := range got.Checks {
+				c.LatencyMs = 0
+				got.Checks[name] = c
+			}
+			if got.Status != tt.want.Status || len(got.Checks) != len(tt.want.Checks) {
+				t.Fatalf("readyz = %+v; want %+v", got, tt.want)
+			}
+			for name, want := range tt.want.Checks {
+				if got.Checks[name] != want {
+					t.Errorf("check %s = %+v; want %+v", name, got.Checks[name], want)
+				}
+			}
+		})
+	}
+}
+
+func TestHealthz(t *testing.T) {
+	tests := []struct {
+		state      int32
+		wantStatus int
+	}{
+		{stateStarting, http.StatusOK},
+		{stateReady, http.StatusOK},
+		{stateDraining, http.StatusOK},
+		{stateStopped, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		app := newTestApp()
+		app.lifecycle.set(tt.state)
+
+		r := gin.New()
+		r.GET("/healthz", app.healthz)
+		if w := do(r, http.MethodGet, "/healthz", "", nil); w.Code != tt.wantStatus {
+			t.Errorf("healthz while %s = %d; want %d", &app.lifecycle, w.Code, tt.wantStatus)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/text/unicode/norm"
+)
+
+// maxColumnLength is the size of the people.name and people.role columns.
+const maxColumnLength = 255
+
+// Bounds on the extra attributes a person may carry.
+const (
+	maxAttributes         = 10
+	maxAttributeKeyLength = 50
+	maxAttributeLength    = 255
+)
+
+// drawLimits bounds the size of a single draw.
+type drawLimits struct {
+	maxPeople     int
+	maxTeams      int
+	maxNameLength int
+	maxRoleLength int
+}
+
+// normalizeText trims s and puts it in Unicode NFC form, so names that look
+// the same are stored and compared the same.
+func normalizeText(s string) string {
+	return norm.NFC.String(strings.TrimSpace(s))
+}
+
+// normalizePeople normalizes names, roles and attributes in place.
+func normalizePeople(people []PersonInput) {
+	for i := range people {
+		people[i].normalize()
+	}
+}
+
+func (p *PersonInput) normalize() {
+	p.Name = normalizeText(p.Name)
+	p.Role = normalizeText(p.Role)
+	if len(p.Attributes) == 0 {
+		return
+	}
+	attrs := make(map[string]string, len(p.Attributes))
+	for k, v := range p.Attributes {
+		attrs[normalizeText(k)] = normalizeText(v)
+	}
+	p.Attributes = attrs
+}
+
+// check normalizes the people in req and reports every field that breaks
+// the limits.
+func (l drawLimits) check(req *RandomizeRequest) []fieldError {
+	var errs []fieldError
+
+	if req.TeamCount > l.maxTeams {
+		errs = append(errs, fieldError{
+			Field:   "team_count",
+			Code:    "max",
+			Message: "must be at most " + strconv.Itoa(l.maxTeams),
+		})
+	}
+
+	return append(errs, l.checkPeople(req.People)...)
+}
+
+// checkPeople normalizes people and reports every field that breaks the
+// limits, named like people[0].name.
+func (l drawLimits) checkPeople(people []PersonInput) []fieldError {
+	var errs []fieldError
+
+	if len(people) > l.maxPeople {
+		errs = append(errs, fieldError{
+			Field:   "people",
+			Code:    "max",
+			Message: "must have at most " + strconv.Itoa(l.maxPeople) + " items",
+		})
+	}
+
+	for i := range people {
+		for _, e := range l.checkPerson(&people[i]) {
+			e.Field = fmt.Sprintf("people[%d].%s", i, e.Field)
+			errs = append(errs, e)
+		}
+	}
+
+	return errs
+}
+
+// checkPerson normalizes p and reports the fields that break the limits,
+// named relative to the person.
+func (l drawLimits) checkPerson(p *PersonInput) []fieldError {
+	p.normalize()
+
+	var errs []fieldError
+	errs = append(errs, checkText("name", p.Name, l.maxNameLength)...)
+	errs = append(errs, checkText("role", p.Role, l.maxRoleLength)...)
+
+	if len(p.Attributes) > maxAttributes {
+		errs = append(errs, fieldError{
+			Field:   "attributes",
+			Code:    "max",
+			Message: "must have at most " + strconv.Itoa(maxAttributes) + " items",
+		})
+		return errs
+	}
+	for _, k := range slices.Sorted(maps.Keys(p.Attributes)) {
+		errs = append(errs, checkText("attributes", k, maxAttributeKeyLength)...)
+		if v := p.Attributes[k]; v != "" {
+			errs = append(errs, checkText("attributes."+k, v, maxAttributeLength)...)
+		}
+	}
+
+	return errs
+}
+
+// checkText validates a normalized name, role or attribute.
+func checkText(field, s string, maxLength int) []fieldError {
+	switch {
+	case s == "":
+		return []fieldError{{Field: field, Code: "required", Message: "is required"}}
+	case utf8.RuneCountInString(s) > maxLength:
+		return []fieldError{{Field: field, Code: "max", Message: "must be at most " + strconv.Itoa(maxLength) + " characters long"}}
+	case strings.ContainsFunc(s, unicode.IsControl):
+		return []fieldError{{Field: field, Code: "printable", Message: "must not contain control characters"}}
+	}
+	return nil
+}
+
+func (app *app) BodyLimitMiddleware() gin.HandlerFunc {
+	// Middleware to cap request bodies at maxBodyBytes, rejecting requests
+	// that declare a larger body before reading any of it
+	return func(c *gin.Context) {
+		if c.Request.ContentLength > app.maxBodyBytes {
+			tooLarge(c, app.maxBodyBytes)
+			return
+		}
+
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, app.maxBodyBytes)
+		c.Next()
+	}
+}
+
+func tooLarge(c *gin.Context, limit int64) {
+	problem(c, http.StatusRequestEntityTooLarge, codeRequestTooLarge,
+		"The request body must not exceed "+strconv.FormatInt(limit, 10)+" bytes")
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCheckText(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want []fieldError
+	}{
+		{name: "valid", s: "Ada"},
+		{name: "at the limit", s: "abcde"},
+		{name: "required", s: "", want: []fieldError{
+			{Field: "name", Code: "required", Message: "is required"},
+		}},
+		{name: "too long", s: "abcdef", want: []fieldError{
+			{Field: "name", Code: "max", Message: "must be at most 5 characters long"},
+		}},
+		{name: "counts runes", s: "\u00e9\u00e9\u00e9\u00e9\u00e9"},
+		{name: "control characters", s: "a\tb", want: []fieldError{
+			{Field: "name", Code: "printable", Message: "must not contain control characters"},
+		}},
+		{name: "null byte", s: "a\x00", want: []fieldError{
+			{Field: "name", Code: "printable", Message: "must not contain control characters"},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checkText("name", tt.s, 5); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("checkText(%q) = %+v; want %+v", tt.s, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeText(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"Ada", "Ada"},
+		{"  Ada \n", "Ada"},
+		{"Rene\u0301", "Ren\u00e9"},
+		{"   ", ""},
+	}
+	for _, tt := range tests {
+		if got := normalizeText(tt.in); got != tt.want {
+			t.Errorf("normalizeText(%q) = %q; want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCheckPeople(t *testing.T) {
+	l := drawLimits{maxPeople: 2, maxTeams: 2, maxNameLength: 4, maxRoleLength: 3}
+
+	t.Run("normalizes before checking", func(t *testing.T) {
+		// Four characters once composed, though five runes as sent.
+		people := []PersonInput{{
+			Name:       " Rene\u0301 ",
+			Role:       "dev",
+			Attributes: map[string]string{" cafe\u0301 ": " L "},
+		}}
+		if errs := l.checkPeople(people); len(errs) != 0 {
+			t.Fatalf("errors = %+v; want none", errs)
+		}
+		want := PersonInput{Name: "Ren\u00e9", Role: "dev", Attributes: map[string]string{"caf\u00e9": "L"}}
+		if !reflect.DeepEqual(people[0], want) {
+			t.Errorf("person = %+v; want %+v", people[0], want)
+		}
+	})
+
+	t.Run("names fields by index", func(t *testing.T) {
+		people := []PersonInput{
+			{Name: "Ada", Role: "dev"},
+			{Name: "Grace", Role: " ", Attributes: map[string]string{"b": "x\ty", "a": ""}},
+		}
+		want := []fieldError{
+			{Field: "people[1].name", Code: "max", Message: "must be at most 4 characters long"},
+			{Field: "people[1].role", Code: "required", Message: "is required"},
+			{Field: "people[1].attributes.b", Code: "printable", Message: "must not contain control characters"},
+		}
+		if got := l.checkPeople(people); !reflect.DeepEqual(got, want) {
+			t.Errorf("errors = %+v; want %+v", got, want)
+		}
+	})
+
+	t.Run("too many people", func(t *testing.T) {
+		people := []PersonInput{{Name: "a", Role: "b"}, {Name: "c", Role: "d"}, {Name: "e", Role: "f"}}
+		want := []fieldError{{Field: "people", Code: "max", Message: "must have at most 2 items"}}
+		if got := l.checkPeople(people); !reflect.DeepEqual(got, want) {
+			t.Errorf("errors = %+v; want %+v", got, want)
+		}
+	})
+
+	t.Run("too many attributes", func(t *testing.T) {
+		attrs := map[string]string{}
+		for i := range maxAttributes + 1 {
+			attrs[strings.Repeat("k", i+1)] = "v"
+		}
+		people := []PersonInput{{Name: "a", Role: "b", Attributes: attrs}}
+		want := []fieldError{{Field: "people[0].attributes", Code: "max", Message: "must have at most 10 items"}}
+		if got := l.checkPeople(people); !reflect.DeepEqual(got, want) {
+			t.Errorf("errors = %+v; want %+v", got, want)
+		}
+	})
+
+	t.Run("team count first", func(t *testing.T) {
+		req := RandomizeRequest{TeamCount: 3, People: []PersonInput{{Name: "", Role: "b"}}}
+		want := []fieldError{
+			{Field: "team_count", Code: "max", Message: "must be at most 2"},
+			{Field: "people[0].name", Code: "required", Message: "is required"},
+		}
+		if got := l.check(&req); !reflect.DeepEqual(got, want) {
+			t.Errorf("errors = %+v; want %+v", got, want)
+		}
+	})
+}
+
+func TestBodyLimitMiddleware(t *testing.T) {
+	a := newTestApp()
+	a.maxBodyBytes = 128
+	r := gin.New()
+	r.Use(a.BodyLimitMiddleware())
+	r.POST("/v1/random/default", a.createRandomize)
+
+	const people = `[{"name":"Ada","role":"dev"},{"name":"Grace","role":"ops"}]`
+	tests := []struct {
+		name       string
+		body       string
+		hideLength bool
+		wantStatus int
+		wantCode   string
+	}{
+		{name: "within the limit", body: `{"team_count":2,"people":` + people + `}`, wantStatus: http.StatusOK},
+		{name: "declared too large", body: `{"team_count":2,"people":` + people + strings.Repeat(" ", 100) + `}`,
+			wantStatus: http.StatusRequestEntityTooLarge, wantCode: codeRequestTooLarge},
+		{name: "streamed too large", body: `{"team_count":2,"people":` + people + strings.Repeat(" ", 100) + `}`, hideLength: true,
+			wantStatus: http.StatusRequestEntityTooLarge, wantCode: codeRequestTooLarge},
+		{name: "too many teams", body: `{"team_count":11,"people":` + people + `}`,
+			wantStatus: http.StatusUnprocessableEntity, wantCode: codeValidationFailed},
+		{name: "control characters", body: `{"team_count":2,"people":[{"name":"A\u0007","role":"dev"}]}`,
+			wantStatus: http.StatusUnprocessableEntity, wantCode: codeValidationFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body io.Reader = strings.NewReader(tt.body)
+			if tt.hideLength {
+				body = struct{ *strings.Reader }{strings.NewReader(tt.body)}
+			}
+			w := do(r, http.MethodPost, "/v1/random/default", "application/json", body)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d; want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantCode == "" {
+				return
+			}
+			if p := decodeProblem(t, w); p.Code != tt.wantCode {
+				t.Errorf("code = %q; want %q", p.Code, tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Aergiaaa/rollet/internal/live"
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+// hostTokenHeader carries the token that lets the host advance a session.
+const hostTokenHeader = "X-Host-Token"
+
+// liveHeartbeat is how often an idle event stream gets a comment, so
+// proxies do not close it.
+const liveHeartbeat = 15 * time.Second
+
+// liveSweepInterval is how often idle sessions are ended, at most; shorter
+// TTLs are swept once per TTL.
+const liveSweepInterval = time.Minute
+
+type liveSessionResponse struct {
+	live.State
+	HostToken  string `json:"host_token"`
+	EventsPath string `json:"events_path"`
+}
+
+type revealRequest struct {
+	Count int `json:"count" binding:"omitempty,min=1"`
+}
+
+// createLive godoc
+// @Summary      Start a live draw
+// @Description  Assigns people like /v1/random/default, but reveals them one at a time. Viewers follow GET /v1/live/{id}/events; the host advances the draw with the returned host token in the X-Host-Token header.
+// @Tags         live
+// @Accept       json
+// @Produce      json
+// @Param        body  body      RandomizeRequest  true  "Randomize request"
+// @Success      201   {object}  liveSessionResponse
+// @Failure      400   {object}  problemDetails
+// @Failure      413   {object}  problemDetails
+// @Failure      422   {object}  problemDetails
+// @Failure      500   {object}  problemDetails
+// @Failure      503   {object}  problemDetails
+// @Router       /v1/live [post]
+func (app *app) createLive(c *gin.Context) {
+	var req RandomizeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindProblem(c, err)
+		return
+	}
+
+	draw, ok := app.assign(c, req)
+	if !ok {
+		return
+	}
+
+	people := make([]live.Person, len(draw.People))
+	for i, p := range draw.People {
+		people[i] = live.Person{Name: p.Name, Role: p.Role, Team: p.Team, Attributes: p.Attributes}
+	}
+
+	session, token, err := app.live.Create(people, draw.TeamCount)
+	if errors.Is(err, live.ErrTooManySessions) {
+		problem(c, http.StatusServiceUnavailable, codeLiveUnavailable, "Too many live sessions are running; try again later")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to create live session", "error", err)
+		problem(c, http.StatusInternalServerError, codeInternal, "Failed to create live session")
+		return
+	}
+
+	c.JSON(http.StatusCreated, liveSessionResponse{
+		State:      session.State(),
+		HostToken:  token,
+		EventsPath: "/v1/live/" + session.ID() + "/events",
+	})
+}
+
+// getLive godoc
+// @Summary      Get a live draw
+// @Description  Reports how far a live draw has been revealed
+// @Tags         live
+// @Produce      json
+// @Param        id   path      string  true  "Session ID"
+// @Success      200  {object}  live.State
+// @Failure      404  {object}  problemDetails
+// @Router       /v1/live/{id} [get]
+func (app *app) getLive(c *gin.Context) {
+	session := app.liveSession(c)
+	if session == nil {
+		return
+	}
+
+	c.JSON(http.StatusOK, session.State())
+}
+
+// liveEvents godoc
+// @Summary      Follow a live draw
+// @Description  Streams the draw as server-sent events: start, then one reveal per person, then done; end when the host stops the session or it expires. Every event has an id; reconnecting with Last-Event-ID resumes after it, otherwise the stream replays from the start.
+// @Tags         live
+// @Produce      text/event-stream
+// @Param        id             path    string  true   "Session ID"
+// @Param        Last-Event-ID  header  int     false  "ID of the last event received"
+// @Success      200
+// @Failure      404  {object}  problemDetails
+// @Router       /v1/live/{id}/events [get]
+func (app *app) liveEvents(c *gin.Context) {
+	session := app.liveSession(c)
+	if session == nil {
+		return
+	}
+
+	cursor, _ := strconv.Atoi(c.GetHeader("Last-Event-ID"))
+
+	// The stream outlives the server's write timeout.
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		slog.WarnContext(c.Request.Context(), "failed to clear write deadline for event stream", "error", err)
+	}
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	heartbeat := time.NewTicker(liveHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		events, changed := session.Events(cursor)
+		for _, e := range events {
+			c.Render(-1, sse.Event{Id: strconv.Itoa(e.ID), Event: e.Type, Data: e.Data})
+			cursor = e.ID
+		}
+		c.Writer.Flush()
+		if changed == nil {
+			return
+		}
+
+		select {
+		case <-changed:
+		case <-heartbeat.C:
+			if _, err := c.Writer.WriteString(": ping\n\n"); err != nil {
+				return
+			}
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+}
+
+// revealLive godoc
+// @Summary      Reveal the next people
+// @Description  Reveals the next person of a live draw, or the next count people, to every viewer. Needs the host token.
+// @Tags         live
+// @Accept       json
+// @Produce      json
+// @Param        id            path      string         true   "Session ID"
+// @Param        X-Host-Token  header    string         true   "Host token from starting the session"
+// @Param        body          body      revealRequest  false  "How many people to reveal"
+// @Success      200  {object}  live.State
+// @Failure      401  {object}  problemDetails
+// @Failure      404  {object}  problemDetails
+// @Failure      422  {object}  problemDetails
+// @Router       /v1/live/{id}/reveal [post]
+func (app *app) revealLive(c *gin.Context) {
+	session := app.liveHost(c)
+	if session == nil {
+		return
+	}
+
+	req := revealRequest{Count: 1}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			bindProblem(c, err)
+			return
+		}
+		req.Count = max(req.Count, 1)
+	}
+
+	state, err := session.Reveal(req.Count)
+	if errors.Is(err, live.ErrEnded) {
+		problem(c, http.StatusNotFound, codeNotFound, "Live session not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, state)
+}
+
+// endLive godoc
+// @Summary      End a live draw
+// @Description  Ends the session; viewers get an end event and their streams close. Needs the host token.
+// @Tags         live
+// @Param        id            path  string  true  "Session ID"
+// @Param        X-Host-Token  header  string  true  "Host token from starting the session"
+// @Success      204
+// @Failure      401  {object}  problemDetails
+// @Failure      404  {object}  problemDetails
+// @Router       /v1/live/{id} [delete]
+func (app *app) endLive(c *gin.Context) {
+	session := app.liveHost(c)
+	if session == nil {
+		return
+	}
+
+	app.live.Remove(session)
+	c.Status(http.StatusNoContent)
+}
+
+// liveSession returns the session named by the id parameter. When it
+// returns nil a problem response has been sent.
+func (app *app) liveSession(c *gin.Context) *live.Session {
+	session := app.live.Get(c.Param("id"))
+	if session == nil {
+		problem(c, http.StatusNotFound, codeNotFound, "Live session not found")
+	}
+	return session
+}
+
+// liveHost is liveSession for requests that must come from the host.
+func (app *app) liveHost(c *gin.Context) *live.Session {
+	session := app.liveSession(c)
+	if session == nil {
+		return nil
+	}
+	if !session.IsHost(c.GetHeader(hostTokenHeader)) {
+		problem(c, http.StatusUnauthorized, codeInvalidToken, "Missing or invalid "+hostTokenHeader+" header")
+		return nil
+	}
+	return session
+}
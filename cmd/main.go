@@ -2,70 +2,77 @@ package main
 
 import (
 	"database/sql"
-	"log"
+	"fmt"
+	"log/slog"
 	"os"
+	"time"
 
 	"github.com/Aergiaaa/rollet/internal/database"
 	"github.com/Aergiaaa/rollet/internal/env"
+	"github.com/Aergiaaa/rollet/internal/live"
+	"github.com/Aergiaaa/rollet/internal/logging"
+	"github.com/Aergiaaa/rollet/internal/metrics"
+	"github.com/Aergiaaa/rollet/internal/webhook"
 	"github.com/joho/godotenv"
 )
 
 type app struct {
-	host      string
-	port      int
-	jwtSecret string
-	models    database.Models
+	host            string
+	port            int
+	jwtSecret       string
+	randomSource    string
+	randomSeed      *int64
+	shutdownTimeout time.Duration
+	shutdownDelay   time.Duration
+	expectedVersion uint
+	tls             tlsOptions
+	maxBodyBytes    int64
+	limits          drawLimits
+	live            *live.Hub
+	webhooks        *webhook.Worker
+	cors            corsOptions
+	rateLimit       rateLimitOptions
+	trustedProxies  []string
+	lifecycle       lifecycle
+	metricsToken    string
+	db              *sql.DB
+	models          database.Models
+	metrics         *metrics.Metrics
 }
 
 func main() {
-	err := godotenv.Load()
-	if err != nil {
-		log.Println("No .env file found, using environment variables")
-	}
+	dotenvErr := godotenv.Load()
 
-	db, err := sql.Open("postgres", env.GetEnvString("DATABASE_URL", ""))
-	if err != nil {
-		log.Fatalf("error opening database: %v", err)
+	var lc logConfig
+	if err := env.Load(&lc); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if err := setupLogging(lc); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
 	}
 
-	url := env.GetEnvString("DATABASE_URL", "")
-	if url == "" {
-		log.Fatal("DATABASE_URL is not set")
+	if dotenvErr != nil {
+		slog.Debug("No .env file found, using environment variables")
 	}
 
-	if len(os.Args) > 1 && os.Args[1] == "migrate" {
-		db, err := sql.Open("postgres", url)
-		if err != nil {
-			log.Fatalf("Could not connect to database: %v", err)
-		}
-		defer db.Close()
+	os.Exit(run(os.Args[1:]))
+}
 
-		switch os.Args[2] {
-		case "up":
-			if err = database.MigrationUp(db); err != nil {
-				log.Fatalf("Migration Failed: %v", err)
-			}
-			log.Println("Migrations completed successfully")
-			return
-		case "down":
-			if err = database.MigrationDown(db); err != nil {
-				log.Fatalf("Migration Failed: %v", err)
-			}
-			log.Println("Migrations completed successfully")
-			return
-		}
+// setupLogging installs the default slog logger. The standard log package
+// is routed through it as well.
+func setupLogging(lc logConfig) error {
+	level, err := logging.ParseLevel(lc.LogLevel)
+	if err != nil {
+		return err
 	}
 
-	models := database.NewModels(db)
-	app := &app{
-		host: env.GetEnvString("HOST", "localhost"),
-		port: env.GetEnvInt("PORT", 8080),
-		jwtSecret: env.GetEnvString("JWT_SECRET",
-			"apakah-apakah-bukan-ini-bukan-secret-kamu"),
-		models: models,
+	logger, err := logging.New(os.Stderr, level, lc.LogFormat)
+	if err != nil {
+		return err
 	}
 
-	if err := app.serve(); err != nil {
-		log.Fatalf("error serving app: %v", err)
-	}
+	slog.SetDefault(logger)
+	return nil
 }
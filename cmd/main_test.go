@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Aergiaaa/rollet/internal/database"
+	"github.com/Aergiaaa/rollet/internal/metrics"
+	"github.com/Aergiaaa/rollet/internal/randomizer"
+	"github.com/gin-gonic/gin"
+)
+
+// newTestApp returns an app with small limits and no database, enough for
+// handlers that do not store anything.
+func newTestApp() *app {
+	gin.SetMode(gin.TestMode)
+	useJSONFieldNames()
+	return &app{
+		randomSource: randomizer.SourceDeterministic,
+		maxBodyBytes: 1 << 16,
+		limits: drawLimits{
+			maxPeople:     100,
+			maxTeams:      10,
+			maxNameLength: 20,
+			maxRoleLength: 20,
+		},
+		metrics: metrics.New(nil),
+	}
+}
+
+// do sends a request through h and returns the recorded response.
+func do(h http.Handler, method, path, contentType string, body io.Reader) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, body)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+// decodeProblem decodes a problem response, failing the test if the body
+// is not one.
+func decodeProblem(t *testing.T, w *httptest.ResponseRecorder) problemDetails {
+	t.Helper()
+	var p problemDetails
+	if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+		t.Fatalf("body %q is not a problem: %v", w.Body.String(), err)
+	}
+	return p
+}
+
+// stubUsers serves Get from a map and counts the lookups. Other methods
+// panic through the nil embedded store.
+type stubUsers struct {
+	database.UserStore
+	users map[int]*database.User
+	gets  int
+}
+
+func (s *stubUsers) Get(_ context.Context, id int) (*database.User, error) {
+	s.gets++
+	return s.users[id], nil
+}
+
+func (s *stubUsers) GetByEmail(_ context.Context, email string) (*database.User, error) {
+	for _, u := range s.users {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+	return nil, nil
+}
+
+// stubAPIKeys serves GetByHash from a map keyed by hash and counts the
+// lookups.
+type stubAPIKeys struct {
+	database.APIKeyStore
+	keys    map[string]*database.APIKey
+	lookups int
+}
+
+func (s *stubAPIKeys) GetByHash(_ context.Context, hash string) (*database.APIKey, error) {
+	s.lookups++
+	return s.keys[hash], nil
+}
+
+// stubAudit records the entries written.
+type stubAudit struct {
+	database.AuditStore
+	entries []database.AuditEntry
+}
+
+func (s *stubAudit) Insert(_ context.Context, e *database.AuditEntry) error {
+	s.entries = append(s.entries, *e)
+	return nil
+}
+
+// actions returns the actions of the recorded entries.
+func (s *stubAudit) actions() []string {
+	var actions []string
+	for _, e := range s.entries {
+		actions = append(actions, e.Action)
+	}
+	return actions
+}
+
+// stubPeople records the draws saved and serves GetDraw from a map.
+type stubPeople struct {
+	database.PeopleStore
+	saved []*database.Draw
+	draws map[int]*database.Draw
+}
+
+func (s *stubPeople) Save(_ context.Context, _ int, draw *database.Draw) error {
+	s.saved = append(s.saved, draw)
+	draw.Id = len(s.saved)
+	return nil
+}
+
+func (s *stubPeople) GetDraw(_ context.Context, id int) (*database.Draw, error) {
+	return s.draws[id], nil
+}
+
+// withMember is a stand-in for AuthMiddleware that sets the user and their
+// membership of organisation orgId with the given role.
+func withMember(user *database.User, orgId int, role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("user", user)
+		c.Set("membership", &database.Membership{OrgId: orgId, UserId: user.Id, Role: role})
+		c.Next()
+	}
+}
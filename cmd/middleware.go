@@ -1,65 +1,351 @@
 package main
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"runtime/debug"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/Aergiaaa/rollet/internal/database"
+	"github.com/Aergiaaa/rollet/internal/logging"
+	"github.com/Aergiaaa/rollet/internal/rbac"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt"
 )
 
+var errInvalidToken = errors.New("invalid token")
+
 func (app *app) AuthMiddleware() gin.HandlerFunc {
 	// Middleware to authenticate requests using JWT tokens
 	return func(c *gin.Context) {
 
-		// Extract the Authorization header
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization header missing"})
-			c.Abort()
+		// Machine clients send an API key instead of a bearer token
+		var key *database.APIKey
+		var userId, tokenVersion int
+		if requestAPIKey(c) != "" {
+			var err error
+			key, err = app.verifiedAPIKey(c)
+			if err != nil {
+				slog.ErrorContext(c.Request.Context(), "failed to verify api key", "error", err)
+				problem(c, http.StatusInternalServerError, codeInternal, "Failed to retrieve data")
+				return
+			}
+			if key == nil {
+				problem(c, http.StatusUnauthorized, codeInvalidToken, "Invalid API key")
+				return
+			}
+			if !checkAPIKeyRoute(c, key) {
+				return
+			}
+			userId = key.UserId
+		} else {
+			// Extract the Authorization header
+			authHeader := c.GetHeader("Authorization")
+			if authHeader == "" {
+				problem(c, http.StatusUnauthorized, codeUnauthorized, "Authorization header missing")
+				return
+			}
+
+			// Parse and validate the JWT token
+			tokenStr := strings.TrimPrefix(authHeader, "Bearer ")
+			if tokenStr == authHeader {
+				problem(c, http.StatusUnauthorized, codeUnauthorized, "Bearer token or API key is required")
+				return
+			}
+
+			// Parse and validate the JWT token
+			var err error
+			userId, tokenVersion, err = app.tokenClaims(tokenStr)
+			if err != nil {
+				problem(c, http.StatusUnauthorized, codeInvalidToken, "Invalid token")
+				return
+			}
+		}
+
+		user, err := app.requestUser(c, userId)
+		if err != nil || user == nil {
+			problem(c, http.StatusUnauthorized, codeUnauthorized, "Unauthorized access")
 			return
 		}
 
-		// Parse and validate the JWT token
-		tokenStr := strings.TrimPrefix(authHeader, "Bearer ")
-		if tokenStr == authHeader {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Bearer token is required"})
-			c.Abort()
+		// Tokens issued before the password changed or the account was
+		// disabled stop working at once, and a forced reset leaves only the
+		// password route open.
+		if key == nil && tokenVersion != user.TokenVersion {
+			problem(c, http.StatusUnauthorized, codeInvalidToken, "This token is no longer valid; log in again")
+			return
+		}
+		if user.DisabledAt != nil {
+			problem(c, http.StatusForbidden, codeAccountDisabled, "This account is disabled")
 			return
 		}
+		if user.PasswordResetRequired && c.FullPath() != changePasswordRoute {
+			problem(c, http.StatusForbidden, codePasswordResetRequired, "A new password must be set first")
+			return
+		}
+
+		c.Set("user", user)
 
-		// Parse and validate the JWT token
-		token, err := jwt.Parse(tokenStr, func(t *jwt.Token) (any, error) {
-			if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, jwt.ErrSignatureInvalid
+		if key != nil {
+			if err := app.models.APIKeys.Touch(c.Request.Context(), key.Id); err != nil {
+				slog.WarnContext(c.Request.Context(), "failed to record api key use", "error", err)
 			}
+		}
 
-			return []byte(app.jwtSecret), nil
-		})
-		if err != nil || !token.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			c.Abort()
-			return
+		// Routes under /orgs/:org act on an organisation; resolve the
+		// user's membership so handlers can check the role.
+		if orgParam := c.Param("org"); orgParam != "" {
+			orgId, err := strconv.Atoi(orgParam)
+			if err != nil {
+				problem(c, http.StatusNotFound, codeNotFound, "Organisation not found")
+				return
+			}
+			membership, err := app.models.Orgs.GetMembership(c.Request.Context(), orgId, user.Id)
+			if err != nil {
+				slog.ErrorContext(c.Request.Context(), "failed to resolve organisation membership", "error", err)
+				problem(c, http.StatusInternalServerError, codeInternal, "Failed to retrieve data")
+				return
+			}
+			// Non-members cannot tell the organisation exists.
+			if membership == nil {
+				problem(c, http.StatusNotFound, codeNotFound, "Organisation not found")
+				return
+			}
+			c.Set("membership", membership)
 		}
 
-		// Extract user information from token claims
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
-			c.Abort()
+		c.Next()
+	}
+}
+
+// RequireOrgRole lets the request through if the user's membership,
+// resolved by AuthMiddleware, has at least the given role.
+func (app *app) RequireOrgRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		membership, ok := c.Get("membership")
+		if !ok || roleRank(membership.(*database.Membership).Role) < roleRank(role) {
+			problem(c, http.StatusForbidden, codeForbidden, "This needs the "+role+" role in the organisation")
 			return
 		}
+		c.Next()
+	}
+}
 
-		// Extract user ID from token claims
-		userId := int(claims["userId"].(float64))
-		user, err := app.models.Users.Get(userId)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized access"})
-			c.Abort()
+// RequirePermission lets the request through if the system-wide role of the
+// user authenticated by AuthMiddleware grants perm.
+func (app *app) RequirePermission(perm rbac.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := c.Get("user")
+		if !ok || !rbac.Can(user.(*database.User).Role, perm) {
+			problem(c, http.StatusForbidden, codeForbidden, "This needs the "+string(perm)+" permission")
 			return
 		}
+		c.Next()
+	}
+}
 
-		c.Set("user", user)
+// tokenTTL is how long a login token stays valid.
+const tokenTTL = 3 * time.Hour
+
+// issueToken signs a login token for the user at their current token
+// version.
+func (app *app) issueToken(u *database.User) (string, error) {
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"userId": u.Id,
+		"ver":    u.TokenVersion,
+		"iat":    now.Unix(),
+		"exp":    now.Add(tokenTTL).Unix(),
+	})
+	return token.SignedString([]byte(app.jwtSecret))
+}
+
+// tokenClaims verifies a JWT signed with the app secret and returns the
+// user ID and token version it was issued for. Tokens without an expiry
+// are refused.
+func (app *app) tokenClaims(tokenStr string) (userId, version int, err error) {
+	token, err := jwt.Parse(tokenStr, func(t *jwt.Token) (any, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, jwt.ErrSignatureInvalid
+		}
+
+		return []byte(app.jwtSecret), nil
+	})
+	if err != nil || !token.Valid {
+		return 0, 0, errInvalidToken
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return 0, 0, errInvalidToken
+	}
+	if _, ok := claims["exp"].(float64); !ok {
+		return 0, 0, errInvalidToken
+	}
+	id, ok := claims["userId"].(float64)
+	if !ok {
+		return 0, 0, errInvalidToken
+	}
+	// Tokens from before versions existed count as version 0
+	ver, _ := claims["ver"].(float64)
+
+	return int(id), int(ver), nil
+}
+
+// requestUser returns the user with the given id, or nil if there is none.
+// Found users are kept on the context, so the rate limiter and
+// AuthMiddleware look them up once.
+func (app *app) requestUser(c *gin.Context, id int) (*database.User, error) {
+	if u, ok := c.Get("requestUser"); ok && u.(*database.User).Id == id {
+		return u.(*database.User), nil
+	}
+
+	u, err := app.models.Users.Get(c.Request.Context(), id)
+	if err != nil || u == nil {
+		return nil, err
+	}
+	c.Set("requestUser", u)
+	return u, nil
+}
+
+func (app *app) MetricsMiddleware() gin.HandlerFunc {
+	// Middleware to record request counts and latency per route
+	return func(c *gin.Context) {
+		start := time.Now()
 		c.Next()
+
+		// Use the route pattern so ids in paths don't create new series
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		app.metrics.ObserveRequest(c.Request.Method, route, c.Writer.Status(), time.Since(start))
+	}
+}
+
+// metricsHandler serves /metrics, requiring the metrics token as a bearer
+// token when one is configured.
+func (app *app) metricsHandler() gin.HandlerFunc {
+	h := app.metrics.Handler()
+	return func(c *gin.Context) {
+		if app.metricsToken != "" {
+			token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(token), []byte(app.metricsToken)) != 1 {
+				problem(c, http.StatusUnauthorized, codeInvalidToken, "Invalid metrics token")
+				return
+			}
+		}
+
+		h.ServeHTTP(c.Writer, c.Request)
 	}
 }
+
+const maxRequestIDLength = 128
+
+func (app *app) RequestIDMiddleware() gin.HandlerFunc {
+	// Middleware to propagate X-Request-ID, or generate one, for every request
+	return func(c *gin.Context) {
+		id := c.GetHeader("X-Request-ID")
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+
+		c.Header("X-Request-ID", id)
+		c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), id))
+		c.Next()
+	}
+}
+
+func (app *app) LoggerMiddleware() gin.HandlerFunc {
+	// Middleware to log one structured line per request. Bodies and query
+	// strings are left out because they carry emails and people names.
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+
+		slog.Log(c.Request.Context(), level, "request",
+			"method", c.Request.Method,
+			"route", c.FullPath(),
+			"path", redactedPath(c),
+			"status", status,
+			"duration_ms", float64(time.Since(start).Microseconds())/1000,
+			"bytes", c.Writer.Size(),
+			"client_ip", c.ClientIP(),
+		)
+	}
+}
+
+// redactedPath is the request path for logs and problem bodies. Path
+// parameters that are not plain numbers, such as share slugs and live
+// session IDs, are bearer secrets, so they are replaced by their name from
+// the route, e.g. /v1/share/:slug. Paths matching no route are returned as
+// they are.
+func redactedPath(c *gin.Context) string {
+	route := c.FullPath()
+	if route == "" {
+		return c.Request.URL.Path
+	}
+
+	segments := strings.Split(route, "/")
+	for i, s := range segments {
+		if len(s) < 2 || (s[0] != ':' && s[0] != '*') {
+			continue
+		}
+		if v := c.Param(s[1:]); v != "" && strings.Trim(v, "0123456789") == "" {
+			segments[i] = v
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+func (app *app) RecoveryMiddleware() gin.HandlerFunc {
+	// Middleware to turn panics into a logged 500 response
+	return func(c *gin.Context) {
+		defer func() {
+			if r := recover(); r != nil {
+				slog.ErrorContext(c.Request.Context(), "panic while handling request",
+					"panic", fmt.Sprint(r),
+					"stack", string(debug.Stack()),
+				)
+				problem(c, http.StatusInternalServerError, codeInternal, "Internal server error")
+			}
+		}()
+		c.Next()
+	}
+}
+
+// validRequestID accepts incoming request IDs that are short and made of
+// characters safe to echo into headers and logs.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_.:", r)) {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
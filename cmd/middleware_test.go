@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRedactedPath(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		route string
+		path  string
+		want  string
+	}{
+		{"/v1/share/:slug", "/v1/share/Zx8_secret-slug", "/v1/share/:slug"},
+		{"/v1/live/:id/events", "/v1/live/q1w2e3r4t5y6/events", "/v1/live/:id/events"},
+		{"/v1/user/history/:id", "/v1/user/history/42", "/v1/user/history/42"},
+		{"/v1/orgs/:org/members/:user", "/v1/orgs/7/members/3", "/v1/orgs/7/members/3"},
+		{"/v1/user/webhooks/:id/deliveries/:delivery/redeliver", "/v1/user/webhooks/5/deliveries/abc/redeliver", "/v1/user/webhooks/5/deliveries/:delivery/redeliver"},
+		{"/v1/user/history/:id", "/v1/user/history/-1", "/v1/user/history/:id"},
+		{"/healthz", "/healthz", "/healthz"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			r := gin.New()
+			r.GET(tt.route, func(c *gin.Context) {
+				problem(c, http.StatusGone, codeNotFound, "gone")
+			})
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			var body problemDetails
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Instance != tt.want {
+				t.Errorf("instance = %q; want %q", body.Instance, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactedPathUnmatched(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/no/such/route", nil)
+	if got := redactedPath(c); got != "/no/such/route" {
+		t.Errorf("redactedPath = %q; want the raw path", got)
+	}
+}
+
+func TestMetricsHandlerToken(t *testing.T) {
+	tests := []struct {
+		token  string
+		header string
+		want   int
+	}{
+		{"", "", http.StatusOK},
+		{"s3cret", "Bearer s3cret", http.StatusOK},
+		{"s3cret", "", http.StatusUnauthorized},
+		{"s3cret", "Bearer wrong", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		app := newTestApp()
+		app.metricsToken = tt.token
+
+		r := gin.New()
+		r.Use(app.MetricsMiddleware())
+		r.GET("/metrics", app.metricsHandler())
+		r.GET("/draws/:id", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+		do(r, http.MethodGet, "/draws/42", "", nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if tt.header != "" {
+			req.Header.Set("Authorization", tt.header)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("token %q, Authorization %q: status = %d; want %d", tt.token, tt.header, w.Code, tt.want)
+			continue
+		}
+		// Requests are labelled by route so ids do not create series
+		if w.Code == http.StatusOK && !strings.Contains(w.Body.String(), `route="/draws/:id",status="204"`) {
+			t.Errorf("metrics lack the request to /draws/:id")
+		}
+	}
+}
@@ -0,0 +1,500 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/Aergiaaa/rollet/internal/database"
+	"github.com/gin-gonic/gin"
+)
+
+type orgRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+type orgResponse struct {
+	database.Org
+	// Role is the caller's role in the organisation.
+	Role string `json:"role"`
+}
+
+type orgDetailResponse struct {
+	orgResponse
+	Members []*database.Member `json:"members"`
+}
+
+type orgListResponse struct {
+	Orgs []*database.Membership `json:"orgs"`
+}
+
+type memberRequest struct {
+	Email string `json:"email" binding:"required,email,max=255"`
+	Role  string `json:"role" binding:"required,oneof=owner admin member viewer"`
+}
+
+type memberRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=owner admin member viewer"`
+}
+
+// lastOwnerDetail explains why the last owner cannot step down or leave.
+const lastOwnerDetail = "The organisation needs at least one other owner first"
+
+// roleRank orders organisation roles; higher ranks can do everything lower
+// ones can.
+func roleRank(role string) int {
+	switch role {
+	case database.RoleOwner:
+		return 4
+	case database.RoleAdmin:
+		return 3
+	case database.RoleMember:
+		return 2
+	case database.RoleViewer:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// canAssign reports whether a member with role actor may move a member from
+// role from to role to. Owners manage everyone; admins only manage members
+// and viewers, so they cannot promote themselves or others past their own
+// role.
+func canAssign(actor, from, to string) bool {
+	if actor == database.RoleOwner {
+		return true
+	}
+	return actor == database.RoleAdmin &&
+		roleRank(from) < roleRank(database.RoleAdmin) && roleRank(to) < roleRank(database.RoleAdmin)
+}
+
+// createOrg godoc
+// @Summary      Create an organisation
+// @Description  Creates an organisation with the caller as its owner. Draws saved through its routes are visible to every member.
+// @Tags         orgs
+// @Accept       json
+// @Produce      json
+// @Param        body  body      orgRequest  true  "Organisation"
+// @Success      201   {object}  orgResponse
+// @Failure      400   {object}  problemDetails
+// @Failure      401   {object}  problemDetails
+// @Failure      422   {object}  problemDetails
+// @Failure      500   {object}  problemDetails
+// @Router       /v1/orgs [post]
+func (app *app) createOrg(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists || user == nil {
+		problem(c, http.StatusUnauthorized, codeUnauthorized, "Authentication is required")
+		return
+	}
+	userObj := user.(*database.User)
+
+	var req orgRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindProblem(c, err)
+		return
+	}
+	req.Name = normalizeText(req.Name)
+	if errs := checkText("name", req.Name, 100); len(errs) > 0 {
+		problem(c, http.StatusUnprocessableEntity, codeValidationFailed, "The request has invalid fields", errs...)
+		return
+	}
+
+	org := database.Org{Name: req.Name}
+	if err := app.models.Orgs.Create(c.Request.Context(), &org, userObj.Id); err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to create organisation", "error", err)
+		problem(c, http.StatusInternalServerError, codeInternal, "Failed to create organisation")
+		return
+	}
+
+	c.JSON(http.StatusCreated, orgResponse{Org: org, Role: database.RoleOwner})
+}
+
+// listOrgs godoc
+// @Summary      List organisations
+// @Description  Returns the organisations the caller belongs to, with the caller's role in each
+// @Tags         orgs
+// @Produce      json
+// @Success      200  {object}  orgListResponse
+// @Failure      401  {object}  problemDetails
+// @Failure      500  {object}  problemDetails
+// @Router       /v1/orgs [get]
+func (app *app) listOrgs(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists || user == nil {
+		problem(c, http.StatusUnauthorized, codeUnauthorized, "Authentication is required")
+		return
+	}
+	userObj := user.(*database.User)
+
+	orgs, err := app.models.Orgs.ListByUserId(c.Request.Context(), userObj.Id)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to list organisations", "error", err)
+		problem(c, http.StatusInternalServerError, codeInternal, "Failed to retrieve data")
+		return
+	}
+
+	c.JSON(http.StatusOK, orgListResponse{Orgs: orgs})
+}
+
+// getOrg godoc
+// @Summary      Get an organisation
+// @Description  Returns the organisation and its members. Needs any role.
+// @Tags         orgs
+// @Produce      json
+// @Param        org  path      int  true  "Organisation ID"
+// @Success      200  {object}  orgDetailResponse
+// @Failure      401  {object}  problemDetails
+// @Failure      404  {object}  problemDetails
+// @Failure      500  {object}  problemDetails
+// @Router       /v1/orgs/{org} [get]
+func (app *app) getOrg(c *gin.Context) {
+	membership := c.MustGet("membership").(*database.Membership)
+
+	org, err := app.models.Orgs.Get(c.Request.Context(), membership.OrgId)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to retrieve organisation", "error", err)
+		problem(c, http.StatusInternalServerError, codeInternal, "Failed to retrieve data")
+		return
+	}
+	if org == nil {
+		problem(c, http.StatusNotFound, codeNotFound, "Organisation not found")
+		return
+	}
+
+	members, err := app.models.Orgs.ListMembers(c.Request.Context(), membership.OrgId)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to list organisation members", "error", err)
+		problem(c, http.StatusInternalServerError, codeInternal, "Failed to retrieve data")
+		return
+	}
+
+	c.JSON(http.StatusOK, orgDetailResponse{
+		orgResponse: orgResponse{Org: *org, Role: membership.Role},
+		Members:     members,
+	})
+}
+
+// deleteOrg godoc
+// @Summary      Delete an organisation
+// @Description  Deletes the organisation with all of its rosters and draws. Needs the owner role.
+// @Tags         orgs
+// @Param        org  path  int  true  "Organisation ID"
+// @Success      204
+// @Failure      401  {object}  problemDetails
+// @Failure      403  {object}  problemDetails
+// @Failure      404  {object}  problemDetails
+// @Failure      500  {object}  problemDetails
+// @Router       /v1/orgs/{org} [delete]
+func (app *app) deleteOrg(c *gin.Context) {
+	membership := c.MustGet("membership").(*database.Membership)
+
+	err := app.models.Orgs.Delete(c.Request.Context(), membership.OrgId)
+	if errors.Is(err, sql.ErrNoRows) {
+		problem(c, http.StatusNotFound, codeNotFound, "Organisation not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to delete organisation", "error", err)
+		problem(c, http.StatusInternalServerError, codeInternal, "Failed to delete organisation")
+		return
+	}
+
+	// The name keeps the entry meaningful once the organisation is gone
+	app.audit(c, database.AuditEntry{
+		Action:     auditOrgDeleted,
+		OrgId:      &membership.OrgId,
+		TargetType: "org",
+		TargetId:   strconv.Itoa(membership.OrgId),
+		Details:    auditDetails(map[string]any{"name": membership.OrgName}),
+	})
+	c.Status(http.StatusNoContent)
+}
+
+// addOrgMember godoc
+// @Summary      Add a member
+// @Description  Adds a registered user to the organisation by email. Admins can add members and viewers; owners can add any role.
+// @Tags         orgs
+// @Accept       json
+// @Produce      json
+// @Param        org   path      int            true  "Organisation ID"
+// @Param        body  body      memberRequest  true  "Member"
+// @Success      201   {object}  database.Member
+// @Failure      400   {object}  problemDetails
+// @Failure      401   {object}  problemDetails
+// @Failure      403   {object}  problemDetails
+// @Failure      404   {object}  problemDetails
+// @Failure      409   {object}  problemDetails
+// @Failure      422   {object}  problemDetails
+// @Failure      500   {object}  problemDetails
+// @Router       /v1/orgs/{org}/members [post]
+func (app *app) addOrgMember(c *gin.Context) {
+	membership := c.MustGet("membership").(*database.Membership)
+
+	var req memberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindProblem(c, err)
+		return
+	}
+	if !canAssign(membership.Role, "", req.Role) {
+		problem(c, http.StatusForbidden, codeForbidden, "Only owners can add members with the "+req.Role+" role")
+		return
+	}
+
+	user, err := app.models.Users.GetByEmail(c.Request.Context(), req.Email)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to retrieve user", "error", err)
+		problem(c, http.StatusInternalServerError, codeInternal, "Failed to retrieve data")
+		return
+	}
+	if user == nil {
+		problem(c, http.StatusUnprocessableEntity, codeValidationFailed, "The request has invalid fields",
+			fieldError{Field: "email", Code: "not_found", Message: "no user has registered with this email"})
+		return
+	}
+
+	err = app.models.Orgs.AddMember(c.Request.Context(), membership.OrgId, user.Id, req.Role)
+	if errors.Is(err, database.ErrDuplicateMember) {
+		problem(c, http.StatusConflict, codeAlreadyMember, "This user is already a member")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to add organisation member", "error", err)
+		problem(c, http.StatusInternalServerError, codeInternal, "Failed to add member")
+		return
+	}
+
+	app.audit(c, auditMember(auditMemberAdded, membership.OrgId, user.Id, map[string]any{"role": req.Role}))
+	c.JSON(http.StatusCreated, database.Member{UserId: user.Id, Name: user.Name, Email: user.Email, Role: req.Role})
+}
+
+// updateOrgMember godoc
+// @Summary      Change a member's role
+// @Description  Admins can move members and viewers between those roles; owners can change any role. The last owner cannot be demoted. API keys the member created for the organisation are revoked when they drop below admin.
+// @Tags         orgs
+// @Accept       json
+// @Param        org   path  int                true  "Organisation ID"
+// @Param        user  path  int                true  "User ID"
+// @Param        body  body  memberRoleRequest  true  "New role"
+// @Success      204
+// @Failure      400   {object}  problemDetails
+// @Failure      401   {object}  problemDetails
+// @Failure      403   {object}  problemDetails
+// @Failure      404   {object}  problemDetails
+// @Failure      409   {object}  problemDetails
+// @Failure      422   {object}  problemDetails
+// @Failure      500   {object}  problemDetails
+// @Router       /v1/orgs/{org}/members/{user} [patch]
+func (app *app) updateOrgMember(c *gin.Context) {
+	membership := c.MustGet("membership").(*database.Membership)
+
+	var req memberRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindProblem(c, err)
+		return
+	}
+
+	target := app.orgMember(c, membership.OrgId)
+	if target == nil {
+		return
+	}
+	if !canAssign(membership.Role, target.Role, req.Role) {
+		problem(c, http.StatusForbidden, codeForbidden, "Only owners can change the roles of owners and admins")
+		return
+	}
+
+	err := app.models.Orgs.UpdateMember(c.Request.Context(), membership.OrgId, target.UserId, req.Role)
+	if errors.Is(err, database.ErrLastOwner) {
+		problem(c, http.StatusConflict, codeLastOwner, lastOwnerDetail)
+		return
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		problem(c, http.StatusNotFound, codeNotFound, "Member not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to update organisation member", "error", err)
+		problem(c, http.StatusInternalServerError, codeInternal, "Failed to update member")
+		return
+	}
+
+	app.audit(c, auditMember(auditMemberRole, membership.OrgId, target.UserId,
+		map[string]any{"from": target.Role, "to": req.Role}))
+	c.Status(http.StatusNoContent)
+}
+
+// removeOrgMember godoc
+// @Summary      Remove a member
+// @Description  Removes a member, or lets any member leave by removing themselves. Admins can remove members and viewers; owners can remove anyone. The last owner cannot leave. Draws the member saved stay with the organisation; links they made to them and API keys they created for it are revoked.
+// @Tags         orgs
+// @Param        org   path  int  true  "Organisation ID"
+// @Param        user  path  int  true  "User ID"
+// @Success      204
+// @Failure      401   {object}  problemDetails
+// @Failure      403   {object}  problemDetails
+// @Failure      404   {object}  problemDetails
+// @Failure      409   {object}  problemDetails
+// @Failure      500   {object}  problemDetails
+// @Router       /v1/orgs/{org}/members/{user} [delete]
+func (app *app) removeOrgMember(c *gin.Context) {
+	membership := c.MustGet("membership").(*database.Membership)
+
+	target := app.orgMember(c, membership.OrgId)
+	if target == nil {
+		return
+	}
+	leaving := target.UserId == membership.UserId
+	if !leaving && !canAssign(membership.Role, target.Role, "") {
+		problem(c, http.StatusForbidden, codeForbidden, "Only owners can remove owners and admins")
+		return
+	}
+
+	err := app.models.Orgs.RemoveMember(c.Request.Context(), membership.OrgId, target.UserId)
+	if errors.Is(err, database.ErrLastOwner) {
+		problem(c, http.StatusConflict, codeLastOwner, lastOwnerDetail)
+		return
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		problem(c, http.StatusNotFound, codeNotFound, "Member not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to remove organisation member", "error", err)
+		problem(c, http.StatusInternalServerError, codeInternal, "Failed to remove member")
+		return
+	}
+
+	app.audit(c, auditMember(auditMemberRemoved, membership.OrgId, target.UserId,
+		map[string]any{"role": target.Role, "left": leaving}))
+	c.Status(http.StatusNoContent)
+}
+
+// orgMember returns the membership named by the user parameter. When it
+// returns nil a problem response has been sent.
+func (app *app) orgMember(c *gin.Context, orgId int) *database.Membership {
+	userId, err := strconv.Atoi(c.Param("user"))
+	if err != nil {
+		problem(c, http.StatusNotFound, codeNotFound, "Member not found")
+		return nil
+	}
+
+	m, err := app.models.Orgs.GetMembership(c.Request.Context(), orgId, userId)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to retrieve organisation member", "error", err)
+		problem(c, http.StatusInternalServerError, codeInternal, "Failed to retrieve data")
+		return nil
+	}
+	if m == nil {
+		problem(c, http.StatusNotFound, codeNotFound, "Member not found")
+	}
+	return m
+}
+
+// createOrgRandomize godoc
+// @Summary      Randomize and save to an organisation
+// @Description  Assigns people like /v1/user/random/custom and saves the draw to the organisation's history, visible to every member. Needs the member role.
+// @Tags         orgs
+// @Accept       json
+// @Produce      json,text/csv,application/vnd.openxmlformats-officedocument.spreadsheetml.sheet,text/markdown,application/pdf
+// @Param        org     path      int               true   "Organisation ID"
+// @Param        body    body      RandomizeRequest  true   "Randomize request"
+// @Param        format  query     string            false  "Response format, overriding the Accept header"  Enums(json, csv, xlsx, md, pdf)
+// @Success      200   {object}  RandomizeResponse
+// @Failure      400   {object}  problemDetails
+// @Failure      401   {object}  problemDetails
+// @Failure      403   {object}  problemDetails
+// @Failure      404   {object}  problemDetails
+// @Failure      406   {object}  problemDetails
+// @Failure      413   {object}  problemDetails
+// @Failure      422   {object}  problemDetails
+// @Failure      500   {object}  problemDetails
+// @Router       /v1/orgs/{org}/random/custom [post]
+func (app *app) createOrgRandomize(c *gin.Context) {
+	app.createCustomRandomize(c)
+}
+
+// importOrgRandomize godoc
+// @Summary      Import a CSV and save to an organisation
+// @Description  Reads people from a CSV like /v1/user/random/import and saves the draw to the organisation's history. Needs the member role.
+// @Tags         orgs
+// @Accept       text/csv
+// @Accept       mpfd
+// @Produce      json,text/csv,application/vnd.openxmlformats-officedocument.spreadsheetml.sheet,text/markdown,application/pdf
+// @Param        org          path      int     true   "Organisation ID"
+// @Param        file         formData  file    false  "CSV file, for multipart uploads"
+// @Param        team_count   query     int     true   "Number of teams"
+// @Param        name_column  query     string  false  "Column holding names"  default(name)
+// @Param        role_column  query     string  false  "Column holding roles"  default(role)
+// @Param        attributes   query     string  false  "Comma-separated extra columns to keep"
+// @Param        format       query     string  false  "Response format, overriding the Accept header"  Enums(json, csv, xlsx, md, pdf)
+// @Success      200   {object}  RandomizeResponse
+// @Failure      400   {object}  problemDetails
+// @Failure      401   {object}  problemDetails
+// @Failure      403   {object}  problemDetails
+// @Failure      404   {object}  problemDetails
+// @Failure      406   {object}  problemDetails
+// @Failure      413   {object}  problemDetails
+// @Failure      415   {object}  problemDetails
+// @Failure      422   {object}  problemDetails
+// @Failure      500   {object}  problemDetails
+// @Router       /v1/orgs/{org}/random/import [post]
+func (app *app) importOrgRandomize(c *gin.Context) {
+	app.importRandomize(c)
+}
+
+// getOrgHistory godoc
+// @Summary      Get an organisation's history
+// @Description  Returns the draws saved to the organisation, newest first, whoever saved them. Needs any role.
+// @Tags         orgs
+// @Produce      json,text/csv,application/vnd.openxmlformats-officedocument.spreadsheetml.sheet,text/markdown,application/pdf
+// @Param        org     path      int     true   "Organisation ID"
+// @Param        format  query     string  false  "Response format, overriding the Accept header"  Enums(json, csv, xlsx, md, pdf)
+// @Success      200   {object}  HistoryResponse
+// @Failure      401   {object}  problemDetails
+// @Failure      404   {object}  problemDetails
+// @Failure      406   {object}  problemDetails
+// @Failure      500   {object}  problemDetails
+// @Router       /v1/orgs/{org}/history [get]
+func (app *app) getOrgHistory(c *gin.Context) {
+	app.getHistory(c)
+}
+
+// deleteOrgHistory godoc
+// @Summary      Delete an organisation's draw
+// @Description  Removes one of the draws saved to the organisation together with its share links. Needs the admin role.
+// @Tags         orgs
+// @Param        org  path  int  true  "Organisation ID"
+// @Param        id   path  int  true  "Draw ID"
+// @Success      204
+// @Failure      401  {object}  problemDetails
+// @Failure      403  {object}  problemDetails
+// @Failure      404  {object}  problemDetails
+// @Failure      500  {object}  problemDetails
+// @Router       /v1/orgs/{org}/history/{id} [delete]
+func (app *app) deleteOrgHistory(c *gin.Context) {
+	app.deleteHistory(c)
+}
+
+// createOrgShare godoc
+// @Summary      Share an organisation's draw
+// @Description  Creates a public link to one of the organisation's draws, like /v1/user/draws/{id}/share. The link is listed and revoked with the caller's own links, and is revoked when they leave the organisation. Needs the member role.
+// @Tags         orgs
+// @Accept       json
+// @Produce      json
+// @Param        org   path      int           true   "Organisation ID"
+// @Param        id    path      int           true   "Draw ID"
+// @Param        body  body      shareRequest  false  "Share options"
+// @Success      201   {object}  shareResponse
+// @Failure      400   {object}  problemDetails
+// @Failure      401   {object}  problemDetails
+// @Failure      403   {object}  problemDetails
+// @Failure      404   {object}  problemDetails
+// @Failure      422   {object}  problemDetails
+// @Failure      500   {object}  problemDetails
+// @Router       /v1/orgs/{org}/draws/{id}/share [post]
+func (app *app) createOrgShare(c *gin.Context) {
+	app.createShare(c)
+}
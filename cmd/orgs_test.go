@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Aergiaaa/rollet/internal/database"
+	"github.com/gin-gonic/gin"
+)
+
+func TestCanAssign(t *testing.T) {
+	const (
+		owner  = database.RoleOwner
+		admin  = database.RoleAdmin
+		member = database.RoleMember
+		viewer = database.RoleViewer
+	)
+	// An empty to is a removal.
+	tests := []struct {
+		actor, from, to string
+		want            bool
+	}{
+		{owner, member, owner, true},
+		{owner, owner, admin, true},
+		{owner, owner, member, true},
+		{owner, admin, "", true},
+		{owner, "", owner, true},
+		{admin, member, owner, false},
+		{admin, member, admin, false},
+		{admin, viewer, member, true},
+		{admin, member, viewer, true},
+		{admin, owner, member, false},
+		{admin, admin, member, false},
+		{admin, admin, "", false},
+		{admin, owner, "", false},
+		{admin, member, "", true},
+		{admin, "", member, true},
+		{admin, "", admin, false},
+		{member, viewer, member, false},
+		{member, viewer, "", false},
+		{viewer, "", viewer, false},
+		{"", viewer, "", false},
+	}
+	for _, tt := range tests {
+		if got := canAssign(tt.actor, tt.from, tt.to); got != tt.want {
+			t.Errorf("canAssign(%q, %q, %q) = %v; want %v", tt.actor, tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+// stubOrgs keeps the roles of one organisation's members, keyed by user ID,
+// and refuses changes that leave it without an owner like OrgModel does.
+type stubOrgs struct {
+	database.OrgStore
+	orgId int
+	roles map[int]string
+}
+
+func (s *stubOrgs) GetMembership(_ context.Context, orgId, userId int) (*database.Membership, error) {
+	role, ok := s.roles[userId]
+	if !ok || orgId != s.orgId {
+		return nil, nil
+	}
+	return &database.Membership{OrgId: orgId, UserId: userId, Role: role}, nil
+}
+
+func (s *stubOrgs) AddMember(_ context.Context, _, userId int, role string) error {
+	if _, ok := s.roles[userId]; ok {
+		return database.ErrDuplicateMember
+	}
+	s.roles[userId] = role
+	return nil
+}
+
+func (s *stubOrgs) UpdateMember(_ context.Context, _, userId int, role string) error {
+	if role != database.RoleOwner && s.lastOwner(userId) {
+		return database.ErrLastOwner
+	}
+	s.roles[userId] = role
+	return nil
+}
+
+func (s *stubOrgs) RemoveMember(_ context.Context, _, userId int) error {
+	if s.lastOwner(userId) {
+		return database.ErrLastOwner
+	}
+	delete(s.roles, userId)
+	return nil
+}
+
+func (s *stubOrgs) lastOwner(userId int) bool {
+	if s.roles[userId] != database.RoleOwner {
+		return false
+	}
+	for id, role := range s.roles {
+		if id != userId && role == database.RoleOwner {
+			return false
+		}
+	}
+	return true
+}
+
+func TestMemberRoutes(t *testing.T) {
+	const (
+		owner   = 1
+		admin   = 2
+		member  = 3
+		viewer  = 4
+		other   = 5
+		outside = 9
+	)
+	// gone marks a user who is not a member afterwards.
+	const gone = "-"
+
+	tests := []struct {
+		name       string
+		actor      int
+		method     string
+		target     int
+		body       string
+		wantStatus int
+		// wantRole is the target's role afterwards; empty means unchanged.
+		wantRole string
+	}{
+		{"owner adds an owner", owner, http.MethodPost, outside, `{"email":"outside@example.com","role":"owner"}`, http.StatusCreated, database.RoleOwner},
+		{"admin adds a member", admin, http.MethodPost, outside, `{"email":"outside@example.com","role":"member"}`, http.StatusCreated, database.RoleMember},
+		{"admin adds a viewer", admin, http.MethodPost, outside, `{"email":"outside@example.com","role":"viewer"}`, http.StatusCreated, database.RoleViewer},
+		{"admin adds an admin", admin, http.MethodPost, outside, `{"email":"outside@example.com","role":"admin"}`, http.StatusForbidden, gone},
+		{"admin adds an owner", admin, http.MethodPost, outside, `{"email":"outside@example.com","role":"owner"}`, http.StatusForbidden, gone},
+		{"member adds a viewer", member, http.MethodPost, outside, `{"email":"outside@example.com","role":"viewer"}`, http.StatusForbidden, gone},
+		{"add an existing member", owner, http.MethodPost, member, `{"email":"member@example.com","role":"viewer"}`, http.StatusConflict, ""},
+		{"add an unknown email", owner, http.MethodPost, 0, `{"email":"nobody@example.com","role":"viewer"}`, http.StatusUnprocessableEntity, ""},
+		{"add with an unknown role", owner, http.MethodPost, outside, `{"email":"outside@example.com","role":"boss"}`, http.StatusUnprocessableEntity, gone},
+
+		{"owner promotes a member to owner", owner, http.MethodPatch, member, `{"role":"owner"}`, http.StatusNoContent, database.RoleOwner},
+		{"owner demotes an admin", owner, http.MethodPatch, admin, `{"role":"viewer"}`, http.StatusNoContent, database.RoleViewer},
+		{"admin moves a member to viewer", admin, http.MethodPatch, member, `{"role":"viewer"}`, http.StatusNoContent, database.RoleViewer},
+		{"admin moves a viewer to member", admin, http.MethodPatch, viewer, `{"role":"member"}`, http.StatusNoContent, database.RoleMember},
+		{"admin promotes a member to admin", admin, http.MethodPatch, member, `{"role":"admin"}`, http.StatusForbidden, ""},
+		{"admin demotes the owner", admin, http.MethodPatch, owner, `{"role":"member"}`, http.StatusForbidden, ""},
+		{"admin demotes themselves", admin, http.MethodPatch, admin, `{"role":"member"}`, http.StatusForbidden, ""},
+		{"member changes a viewer", member, http.MethodPatch, viewer, `{"role":"member"}`, http.StatusForbidden, ""},
+		{"last owner steps down", owner, http.MethodPatch, owner, `{"role":"admin"}`, http.StatusConflict, ""},
+		{"change a non-member", owner, http.MethodPatch, outside, `{"role":"member"}`, http.StatusNotFound, gone},
+
+		{"owner removes an admin", owner, http.MethodDelete, admin, "", http.StatusNoContent, gone},
+		{"admin removes a member", admin, http.MethodDelete, member, "", http.StatusNoContent, gone},
+		{"admin removes a viewer", admin, http.MethodDelete, viewer, "", http.StatusNoContent, gone},
+		{"admin removes the owner", admin, http.MethodDelete, owner, "", http.StatusForbidden, ""},
+		{"admin removes another admin", admin, http.MethodDelete, other, "", http.StatusForbidden, ""},
+		{"member removes a viewer", member, http.MethodDelete, viewer, "", http.StatusForbidden, ""},
+		{"viewer leaves", viewer, http.MethodDelete, viewer, "", http.StatusNoContent, gone},
+		{"admin leaves", admin, http.MethodDelete, admin, "", http.StatusNoContent, gone},
+		{"last owner leaves", owner, http.MethodDelete, owner, "", http.StatusConflict, ""},
+		{"remove a non-member", owner, http.MethodDelete, outside, "", http.StatusNotFound, gone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApp()
+			orgs := &stubOrgs{orgId: 7, roles: map[int]string{
+				owner:  database.RoleOwner,
+				admin:  database.RoleAdmin,
+				member: database.RoleMember,
+				viewer: database.RoleViewer,
+				other:  database.RoleAdmin,
+			}}
+			users := &stubUsers{users: map[int]*database.User{
+				member:  {Id: member, Email: "member@example.com"},
+				outside: {Id: outside, Email: "outside@example.com"},
+			}}
+			audit := &stubAudit{}
+			app.models = database.Models{Orgs: orgs, Users: users, Audit: audit}
+			before := orgs.roles[tt.target]
+
+			r := gin.New()
+			g := r.Group("/v1/orgs/:org", withMember(&database.User{Id: tt.actor}, 7, orgs.roles[tt.actor]))
+			g.POST("/members", app.RequireOrgRole(database.RoleAdmin), app.addOrgMember)
+			g.PATCH("/members/:user", app.RequireOrgRole(database.RoleAdmin), app.updateOrgMember)
+			g.DELETE("/members/:user", app.RequireOrgRole(database.RoleViewer), app.removeOrgMember)
+
+			path := "/v1/orgs/7/members"
+			if tt.method != http.MethodPost {
+				path += "/" + strconv.Itoa(tt.target)
+			}
+			w := do(r, tt.method, path, "application/json", strings.NewReader(tt.body))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d; want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+
+			role, ok := orgs.roles[tt.target]
+			switch {
+			case tt.wantRole == gone && ok:
+				t.Errorf("target is still a %s; want them gone", role)
+			case tt.wantRole == "" && role != before:
+				t.Errorf("target role = %q; want it unchanged from %q", role, before)
+			case tt.wantRole != gone && tt.wantRole != "" && role != tt.wantRole:
+				t.Errorf("target role = %q; want %q", role, tt.wantRole)
+			}
+
+			if success := w.Code < 300; success != (len(audit.entries) == 1) {
+				t.Errorf("audit = %v after status %d", audit.actions(), w.Code)
+			}
+		})
+	}
+}
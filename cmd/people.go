@@ -1,24 +1,29 @@
 package main
 
 import (
-	"math/rand"
+	"database/sql"
+	"errors"
+	"log/slog"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/Aergiaaa/rollet/internal/database"
+	"github.com/Aergiaaa/rollet/internal/randomizer"
 	"github.com/gin-gonic/gin"
 )
 
 type PersonInput struct {
-	Name string `json:"name" binding:"required"`
-	Role string `json:"role" binding:"required"`
+	Name       string            `json:"name" binding:"required"`
+	Role       string            `json:"role" binding:"required"`
+	Attributes map[string]string `json:"attributes,omitempty"`
 }
 
 type RandomizeRequestOpts struct {
 }
 
 type RandomizeRequest struct {
-	People    []PersonInput        `json:"people" binding:"required,min=1"`
+	People    []PersonInput        `json:"people" binding:"required,min=1,dive"`
 	TeamCount int                  `json:"team_count" binding:"required,min=1"`
 	Opts      RandomizeRequestOpts `json:"options"`
 }
@@ -34,8 +39,16 @@ type TeamGroup struct {
 }
 
 type RandomizeResponse struct {
-	Teams []TeamGroup `json:"teams"`
-	Total int         `json:"total"`
+	DrawID    int         `json:"draw_id,omitempty"`
+	Source    string      `json:"source"`
+	Seed      *int64      `json:"seed,omitempty"`
+	CreatedAt *time.Time  `json:"created_at,omitempty"`
+	Teams     []TeamGroup `json:"teams"`
+	Total     int         `json:"total"`
+}
+
+type HistoryResponse struct {
+	Draws []RandomizeResponse `json:"draws"`
 }
 
 // createRandomize godoc
@@ -43,58 +56,64 @@ type RandomizeResponse struct {
 // @Description  Shuffles people, assigns teams, optionally saves for authenticated users
 // @Tags         people
 // @Accept       json
-// @Produce      json
-// @Param        body  body      RandomizeRequest  true  "Randomize request"
+// @Produce      json,text/csv,application/vnd.openxmlformats-officedocument.spreadsheetml.sheet,text/markdown,application/pdf
+// @Param        body    body      RandomizeRequest  true   "Randomize request"
+// @Param        format  query     string            false  "Response format, overriding the Accept header"  Enums(json, csv, xlsx, md, pdf)
 // @Success      200   {object}  RandomizeResponse
-// @Failure      400   {object}  errorResponse
-// @Failure      500   {object}  errorResponse
-// @Router       /v1/people/randomize [post]
+// @Failure      400   {object}  problemDetails
+// @Failure      406   {object}  problemDetails
+// @Failure      413   {object}  problemDetails
+// @Failure      422   {object}  problemDetails
+// @Failure      500   {object}  problemDetails
+// @Router       /v1/random/default [post]
 func (app *app) createRandomize(c *gin.Context) {
 
 	// Bind and validate input
 	var req RandomizeRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid request: " + err.Error(),
-		})
+		bindProblem(c, err)
 		return
 	}
 
-	// Convert input to People structs
-	people := make([]*database.People, len(req.People))
-	for i, p := range req.People {
-		people[i] = &database.People{
-			Name: p.Name,
-			Role: p.Role,
-			Team: 0,
-		}
-	}
+	app.runDraw(c, req)
+}
 
-	// Group by role
-	roleMap := make(map[string][]*database.People)
-	for _, person := range people {
-		roleMap[person.Role] = append(roleMap[person.Role], person)
+// createCustomRandomize godoc
+// @Summary      Randomize and save to history
+// @Description  Assigns people into teams like /v1/random/default and saves the draw, including its randomness source, to the user's history
+// @Tags         people
+// @Accept       json
+// @Produce      json,text/csv,application/vnd.openxmlformats-officedocument.spreadsheetml.sheet,text/markdown,application/pdf
+// @Param        body    body      RandomizeRequest  true   "Randomize request"
+// @Param        format  query     string            false  "Response format, overriding the Accept header"  Enums(json, csv, xlsx, md, pdf)
+// @Success      200   {object}  RandomizeResponse
+// @Failure      400   {object}  problemDetails
+// @Failure      401   {object}  problemDetails
+// @Failure      406   {object}  problemDetails
+// @Failure      413   {object}  problemDetails
+// @Failure      422   {object}  problemDetails
+// @Failure      500   {object}  problemDetails
+// @Router       /v1/user/random/custom [post]
+func (app *app) createCustomRandomize(c *gin.Context) {
+	var req RandomizeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindProblem(c, err)
+		return
 	}
 
-	// Shuffle and assign to teams
-	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
-	allPeople := make([]*database.People, 0, len(people))
-
-	teamIdx := 0
-	for _, peopleList := range roleMap {
-		shuffled := make([]*database.People, len(peopleList))
-		copy(shuffled, peopleList)
-
-		rng.Shuffle(len(shuffled), func(i, j int) {
-			shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
-		})
+	app.runDraw(c, req)
+}
 
-		// round-robin assignment to teams
-		for _, person := range shuffled {
-			person.Team = (teamIdx % req.TeamCount) + 1
-			allPeople = append(allPeople, person)
-			teamIdx++
-		}
+// runDraw assigns the people, saves the draw for authenticated users and
+// writes the response as JSON or an export format.
+func (app *app) runDraw(c *gin.Context, req RandomizeRequest) {
+	format, ok := exportFormat(c)
+	if !ok {
+		return
+	}
+	draw, ok := app.assign(c, req)
+	if !ok {
+		return
 	}
 
 	// Save to database if authenticated
@@ -102,106 +121,249 @@ func (app *app) createRandomize(c *gin.Context) {
 	isAuthenticated := exists && user != nil
 	if isAuthenticated {
 		userObj := user.(*database.User)
-		err := app.models.People.Save(userObj.Id, allPeople)
+		if membership, ok := c.Get("membership"); ok {
+			draw.OrgId = &membership.(*database.Membership).OrgId
+		}
+		err := app.models.People.Save(c.Request.Context(), userObj.Id, draw)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "Failed to save to database",
-			})
+			slog.ErrorContext(c.Request.Context(), "failed to save to database", "error", err)
+			problem(c, http.StatusInternalServerError, codeInternal, "Failed to save to database")
 			return
 		}
+		if draw.OrgId == nil {
+			app.queueWebhooks(c.Request.Context(), userObj.Id, draw)
+		}
+		app.audit(c, database.AuditEntry{
+			Action:     auditDrawSaved,
+			OrgId:      draw.OrgId,
+			TargetType: "draw",
+			TargetId:   strconv.Itoa(draw.Id),
+			Details:    auditDetails(map[string]any{"source": draw.Source, "people": len(draw.People)}),
+		})
 	}
 
-	// Group by team for response
-	teamMap := make(map[int][]*database.People)
-	for _, person := range allPeople {
-		teamMap[person.Team] = append(teamMap[person.Team], person)
+	app.metrics.DrawCreated(draw.Source, len(draw.People), isAuthenticated)
+	if format != nil {
+		writeExport(c, *format, drawFilename(draw), []*database.Draw{draw})
+		return
 	}
+	c.JSON(http.StatusOK, drawResponse(draw))
+}
 
-	// Prepare teams for response
-	teams := make([]TeamGroup, 0, len(teamMap))
-	for teamNum := 1; teamNum <= req.TeamCount; teamNum++ {
-		if peopleInTeam, ok := teamMap[teamNum]; ok {
-			teams = append(teams, TeamGroup{
-				Team:    teamNum,
-				Members: peopleInTeam,
-			})
-		}
+// assign checks the request against the draw limits and assigns the
+// people with the configured randomness source. When ok is false a problem
+// response has been sent.
+func (app *app) assign(c *gin.Context, req RandomizeRequest) (draw *database.Draw, ok bool) {
+	if errs := app.limits.check(&req); len(errs) > 0 {
+		problem(c, http.StatusUnprocessableEntity, codeValidationFailed, "The request has invalid fields", errs...)
+		return nil, false
 	}
 
-	res := RandomizeResponse{
-		Teams: teams,
-		Total: len(allPeople),
+	src, seed, err := app.newSource()
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to initialize randomness source", "error", err)
+		problem(c, http.StatusInternalServerError, codeInternal, "Failed to initialize randomness source")
+		return nil, false
 	}
-	c.JSON(http.StatusOK, res)
-}
 
-// createCustomRandomize godoc
-// @Summary      Custom randomize (TODO)
-// @Description  Custom randomization of people into teams
-// @Tags         people
-// @Accept       json
-// @Produce      json
-// @Param        body  body      RandomizeRequest  true  "Randomize request"
-// @Success      200   {object}  RandomizeResponse
-// @Failure      400   {object}  errorResponse
-// @Failure      500   {object}  errorResponse
-// @Router       /v1/people/randomize/custom [post]
-func (app *app) createCustomRandomize(c *gin.Context) {
-	var req RandomizeRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid request: " + err.Error(),
-		})
-		return
+	// Shuffle and assign to teams
+	allPeople, err := randomize(req.People, req.TeamCount, src)
+	if err != nil {
+		drawProblem(c, err)
+		return nil, false
 	}
+
+	return &database.Draw{
+		TeamCount: req.TeamCount,
+		Source:    app.randomSource,
+		Seed:      seed,
+		People:    allPeople,
+	}, true
 }
 
 // getHistory godoc
 // @Summary      Get saved team history
-// @Description  Returns saved randomizations for the authenticated user
+// @Description  Returns the personal draws of the authenticated user, newest first. Draws saved to an organisation are under /v1/orgs/{org}/history.
 // @Tags         people
-// @Produce      json
-// @Success      200   {object}  RandomizeResponse
-// @Failure      401   {object}  errorResponse
-// @Failure      500   {object}  errorResponse
-// @Router       /v1/people/history [get]
+// @Produce      json,text/csv,application/vnd.openxmlformats-officedocument.spreadsheetml.sheet,text/markdown,application/pdf
+// @Param        format  query     string  false  "Response format, overriding the Accept header"  Enums(json, csv, xlsx, md, pdf)
+// @Success      200   {object}  HistoryResponse
+// @Failure      401   {object}  problemDetails
+// @Failure      406   {object}  problemDetails
+// @Failure      500   {object}  problemDetails
+// @Router       /v1/user/history [get]
 func (app *app) getHistory(c *gin.Context) {
 
 	// Check authentication
 	user, exists := c.Get("user")
 	if !exists || user == nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": "Unauthorized",
-		})
+		problem(c, http.StatusUnauthorized, codeUnauthorized, "Authentication is required")
+		return
+	}
+
+	format, ok := exportFormat(c)
+	if !ok {
 		return
 	}
 
 	// Retrieve saved data
 	userObj := user.(*database.User)
-	people, err := app.models.People.GetAllbyUserId(userObj.Id)
+	var draws []*database.Draw
+	var err error
+	if membership, ok := c.Get("membership"); ok {
+		draws, err = app.models.People.GetDrawsByOrgId(c.Request.Context(), membership.(*database.Membership).OrgId)
+	} else {
+		draws, err = app.models.People.GetDrawsByUserId(c.Request.Context(), userObj.Id)
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to retrieve data",
-		})
+		slog.ErrorContext(c.Request.Context(), "failed to retrieve data", "error", err)
+		problem(c, http.StatusInternalServerError, codeInternal, "Failed to retrieve data")
 		return
 	}
 
+	if format != nil {
+		writeExport(c, *format, "history", draws)
+		return
+	}
+
+	res := HistoryResponse{
+		Draws: make([]RandomizeResponse, len(draws)),
+	}
+	for i, d := range draws {
+		res.Draws[i] = drawResponse(d)
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+// deleteHistory godoc
+// @Summary      Delete a saved draw
+// @Description  Removes one of the authenticated user's personal draws together with its share links
+// @Tags         people
+// @Param        id   path  int  true  "Draw ID"
+// @Success      204
+// @Failure      401  {object}  problemDetails
+// @Failure      404  {object}  problemDetails
+// @Failure      500  {object}  problemDetails
+// @Router       /v1/user/history/{id} [delete]
+func (app *app) deleteHistory(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists || user == nil {
+		problem(c, http.StatusUnauthorized, codeUnauthorized, "Authentication is required")
+		return
+	}
+	userObj := user.(*database.User)
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		problem(c, http.StatusNotFound, codeNotFound, "Draw not found")
+		return
+	}
+
+	var orgId *int
+	if membership, ok := c.Get("membership"); ok {
+		orgId = &membership.(*database.Membership).OrgId
+		err = app.models.People.DeleteOrgDraw(c.Request.Context(), *orgId, id)
+	} else {
+		err = app.models.People.DeleteDraw(c.Request.Context(), userObj.Id, id)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		problem(c, http.StatusNotFound, codeNotFound, "Draw not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to delete draw", "error", err)
+		problem(c, http.StatusInternalServerError, codeInternal, "Failed to delete draw")
+		return
+	}
+
+	app.audit(c, database.AuditEntry{
+		Action:     auditDrawDeleted,
+		OrgId:      orgId,
+		TargetType: "draw",
+		TargetId:   strconv.Itoa(id),
+	})
+	c.Status(http.StatusNoContent)
+}
+
+// newSource returns the configured randomness source for one draw. For the
+// seeded source it also returns the seed to record with the draw: the
+// operator's fixed seed if set, a fresh one otherwise.
+func (app *app) newSource() (randomizer.Source, *int64, error) {
+	if app.randomSource != randomizer.SourceSeeded {
+		src, err := randomizer.NewNamedSource(app.randomSource, 0)
+		return src, nil, err
+	}
+
+	seed := randomizer.NewSeed()
+	if app.randomSeed != nil {
+		seed = *app.randomSeed
+	}
+	src, err := randomizer.NewNamedSource(app.randomSource, seed)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return src, &seed, nil
+}
+
+// randomize assigns the people to teamCount teams and returns them in
+// assignment order, ready to be saved.
+func randomize(inputs []PersonInput, teamCount int, src randomizer.Source) ([]*database.People, error) {
+	people := make([]randomizer.Person, len(inputs))
+	for i, p := range inputs {
+		people[i] = randomizer.Person{Name: p.Name, Role: p.Role}
+	}
+
+	res, err := randomizer.NewRoleBalanced(src).Assign(people, randomizer.Options{TeamCount: teamCount})
+	if err != nil {
+		return nil, err
+	}
+
+	allPeople := make([]*database.People, len(res.Assignments))
+	for i, a := range res.Assignments {
+		allPeople[i] = &database.People{
+			Name:       a.Person.Name,
+			Role:       a.Person.Role,
+			Team:       a.Team,
+			Attributes: inputs[a.Index].Attributes,
+		}
+	}
+
+	return allPeople, nil
+}
+
+func drawResponse(d *database.Draw) RandomizeResponse {
+	res := RandomizeResponse{
+		DrawID: d.Id,
+		Source: d.Source,
+		Seed:   d.Seed,
+		Teams:  groupTeams(d.People, d.TeamCount),
+		Total:  len(d.People),
+	}
+	if !d.CreatedAt.IsZero() {
+		res.CreatedAt = &d.CreatedAt
+	}
+
+	return res
+}
+
+// groupTeams collects people into teams 1..teamCount, skipping teams that
+// ended up empty.
+func groupTeams(people []*database.People, teamCount int) []TeamGroup {
 	teamMap := make(map[int][]*database.People)
 	for _, person := range people {
 		teamMap[person.Team] = append(teamMap[person.Team], person)
 	}
 
 	teams := make([]TeamGroup, 0, len(teamMap))
-	for teamNum, peopleInTeam := range teamMap {
-		teams = append(teams, TeamGroup{
-			Team:    teamNum,
-			Members: peopleInTeam,
-		})
+	for teamNum := 1; teamNum <= teamCount; teamNum++ {
+		if peopleInTeam, ok := teamMap[teamNum]; ok {
+			teams = append(teams, TeamGroup{
+				Team:    teamNum,
+				Members: peopleInTeam,
+			})
+		}
 	}
 
-	res := RandomizeResponse{
-		Teams: teams,
-		Total: len(people),
-	}
-	c.JSON(http.StatusOK, res)
+	return teams
 }
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/Aergiaaa/rollet/internal/logging"
+	"github.com/Aergiaaa/rollet/internal/randomizer"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+const problemContentType = "application/problem+json"
+
+// Error codes are part of the API: clients branch on them, so existing
+// codes must not change meaning.
+const (
+	codeInvalidRequest        = "invalid_request"
+	codeValidationFailed      = "validation_failed"
+	codeUnauthorized          = "unauthorized"
+	codeInvalidCredentials    = "invalid_credentials"
+	codeInvalidToken          = "invalid_token"
+	codeOAuthFailed           = "oauth_failed"
+	codeForbidden             = "forbidden"
+	codeAccountDisabled       = "account_disabled"
+	codePasswordResetRequired = "password_reset_required"
+	codeEmailTaken            = "email_taken"
+	codeAlreadyMember         = "already_member"
+	codeLastOwner             = "last_owner"
+	codeNotFound              = "not_found"
+	codeMethodNotAllowed      = "method_not_allowed"
+	codeNotAcceptable         = "not_acceptable"
+	codePasswordRequired      = "password_required"
+	codeShareExpired          = "share_expired"
+	codeLiveUnavailable       = "live_unavailable"
+	codeRateLimited           = "rate_limited"
+	codeRequestTooLarge       = "request_too_large"
+	codeUnsupportedMediaType  = "unsupported_media_type"
+	codeInternal              = "internal_error"
+)
+
+// problemDetails is the RFC 7807 body of every error response. Code is a
+// stable machine-readable identifier, Errors lists per-field validation
+// failures.
+type problemDetails struct {
+	Type      string       `json:"type" example:"urn:rollet:problem:validation_failed"`
+	Title     string       `json:"title" example:"Bad Request"`
+	Status    int          `json:"status" example:"400"`
+	Detail    string       `json:"detail,omitempty" example:"The request has invalid fields"`
+	Instance  string       `json:"instance,omitempty" example:"/v1/random/default"`
+	Code      string       `json:"code" example:"validation_failed"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []fieldError `json:"errors,omitempty"`
+}
+
+// fieldError describes one invalid field. Field is the JSON path of the
+// value, e.g. people[2].name, and Code the rule it broke, e.g. required.
+// For uploaded files Row is the line number and Field the column, if any.
+type fieldError struct {
+	Field   string `json:"field,omitempty" example:"team_count"`
+	Row     int    `json:"row,omitempty"`
+	Code    string `json:"code" example:"min"`
+	Message string `json:"message" example:"must be at least 1"`
+}
+
+// problem aborts the request with a problem+json response carrying the
+// request ID, so users can quote it and operators can find the matching
+// logs.
+func problem(c *gin.Context, status int, code, detail string, errs ...fieldError) {
+	c.Header("Content-Type", problemContentType)
+	c.AbortWithStatusJSON(status, problemDetails{
+		Type:      "urn:rollet:problem:" + code,
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  redactedPath(c),
+		Code:      code,
+		RequestID: logging.RequestID(c.Request.Context()),
+		Errors:    errs,
+	})
+}
+
+// bindProblem responds to an error from ShouldBindJSON, listing the invalid
+// fields when the body was well-formed JSON.
+func bindProblem(c *gin.Context, err error) {
+	var verrs validator.ValidationErrors
+	var typeErr *json.UnmarshalTypeError
+	var sizeErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &sizeErr):
+		tooLarge(c, sizeErr.Limit)
+	case errors.As(err, &verrs):
+		errs := make([]fieldError, len(verrs))
+		for i, fe := range verrs {
+			errs[i] = fieldError{
+				Field:   fieldPath(fe.Namespace()),
+				Code:    fe.Tag(),
+				Message: fieldMessage(fe),
+			}
+		}
+		problem(c, http.StatusUnprocessableEntity, codeValidationFailed, "The request has invalid fields", errs...)
+	case errors.As(err, &typeErr):
+		problem(c, http.StatusUnprocessableEntity, codeValidationFailed, "The request has invalid fields", fieldError{
+			Field:   jsonFieldPath(typeErr.Field),
+			Code:    "type",
+			Message: "must be " + jsonType(typeErr.Type),
+		})
+	case errors.Is(err, io.EOF):
+		problem(c, http.StatusBadRequest, codeInvalidRequest, "The request body is empty")
+	default:
+		problem(c, http.StatusBadRequest, codeInvalidRequest, "The request body is not valid JSON")
+	}
+}
+
+// bindQueryProblem responds to an error from ShouldBindQuery, listing the
+// invalid parameters when the values parsed.
+func bindQueryProblem(c *gin.Context, err error) {
+	var verrs validator.ValidationErrors
+	if errors.As(err, &verrs) {
+		bindProblem(c, err)
+		return
+	}
+	problem(c, http.StatusBadRequest, codeInvalidRequest, "The query parameters are invalid")
+}
+
+// drawProblem responds to an error from the randomizer, which only fails on
+// input the binding rules let through.
+func drawProblem(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, randomizer.ErrNoPeople):
+		problem(c, http.StatusUnprocessableEntity, codeValidationFailed, "The request has invalid fields",
+			fieldError{Field: "people", Code: "required", Message: "is required"})
+	case errors.Is(err, randomizer.ErrInvalidTeamCount):
+		problem(c, http.StatusUnprocessableEntity, codeValidationFailed, "The request has invalid fields",
+			fieldError{Field: "team_count", Code: "min", Message: "must be at least 1"})
+	default:
+		problem(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+	}
+}
+
+// fieldPath drops the struct name validator puts in front of the path, so
+// "RandomizeRequest.people[0].name" becomes "people[0].name".
+func fieldPath(namespace string) string {
+	if _, path, ok := strings.Cut(namespace, "."); ok {
+		return path
+	}
+	return namespace
+}
+
+// jsonFieldPath writes the path encoding/json reports, such as
+// "people.0.attributes", like validator paths: "people[0].attributes".
+func jsonFieldPath(field string) string {
+	var b strings.Builder
+	for i, part := range strings.Split(field, ".") {
+		switch {
+		case part != "" && strings.Trim(part, "0123456789") == "":
+			b.WriteString("[" + part + "]")
+		case i > 0:
+			b.WriteString("." + part)
+		default:
+			b.WriteString(part)
+		}
+	}
+	return b.String()
+}
+
+func fieldMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "min", "max":
+		bound := "at least "
+		if fe.Tag() == "max" {
+			bound = "at most "
+		}
+		switch fe.Kind() {
+		case reflect.String:
+			return "must be " + bound + fe.Param() + " characters long"
+		case reflect.Slice:
+			return "must have " + bound + fe.Param() + " items"
+		default:
+			return "must be " + bound + fe.Param()
+		}
+	default:
+		return "failed the " + fe.Tag() + " rule"
+	}
+}
+
+// jsonType names the JSON type a Go value decodes from.
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Struct, reflect.Map:
+		return "an object"
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Pointer:
+		return jsonType(t.Elem())
+	default:
+		return "a number"
+	}
+}
+
+// useJSONFieldNames makes validation errors report fields by their JSON
+// names, which is what clients sent.
+func useJSONFieldNames() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return f.Name
+		}
+		return name
+	})
+}
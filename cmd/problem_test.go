@@ -0,0 +1,188 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Aergiaaa/rollet/internal/logging"
+	"github.com/Aergiaaa/rollet/internal/randomizer"
+	"github.com/gin-gonic/gin"
+)
+
+// probeRequest exercises the binding rules the API uses.
+type probeRequest struct {
+	Email  string        `json:"email" binding:"required,email"`
+	Name   string        `json:"name" binding:"omitempty,min=2,max=4"`
+	Count  int           `json:"count" binding:"omitempty,min=1,max=3"`
+	Tags   []string      `json:"tags" binding:"omitempty,min=1,max=2"`
+	Kind   string        `json:"kind" binding:"omitempty,oneof=a b"`
+	People []PersonInput `json:"people" binding:"omitempty,dive"`
+}
+
+func TestBindProblem(t *testing.T) {
+	const ok = `"email":"a@example.com"`
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantDetail string
+		want       []fieldError
+	}{
+		{name: "valid", body: `{` + ok + `}`, wantStatus: http.StatusNoContent},
+		{name: "required", body: `{}`, wantStatus: http.StatusUnprocessableEntity, want: []fieldError{
+			{Field: "email", Code: "required", Message: "is required"},
+		}},
+		{name: "email", body: `{"email":"nope"}`, wantStatus: http.StatusUnprocessableEntity, want: []fieldError{
+			{Field: "email", Code: "email", Message: "must be a valid email address"},
+		}},
+		{name: "string bounds", body: `{` + ok + `,"name":"a"}`, wantStatus: http.StatusUnprocessableEntity, want: []fieldError{
+			{Field: "name", Code: "min", Message: "must be at least 2 characters long"},
+		}},
+		{name: "string bounds count characters", body: `{` + ok + `,"name":"éééé"}`, wantStatus: http.StatusNoContent},
+		{name: "string too long", body: `{` + ok + `,"name":"abcde"}`, wantStatus: http.StatusUnprocessableEntity, want: []fieldError{
+			{Field: "name", Code: "max", Message: "must be at most 4 characters long"},
+		}},
+		{name: "number bounds", body: `{` + ok + `,"count":4}`, wantStatus: http.StatusUnprocessableEntity, want: []fieldError{
+			{Field: "count", Code: "max", Message: "must be at most 3"},
+		}},
+		{name: "array bounds", body: `{` + ok + `,"tags":["a","b","c"]}`, wantStatus: http.StatusUnprocessableEntity, want: []fieldError{
+			{Field: "tags", Code: "max", Message: "must have at most 2 items"},
+		}},
+		{name: "other rules", body: `{` + ok + `,"kind":"c"}`, wantStatus: http.StatusUnprocessableEntity, want: []fieldError{
+			{Field: "kind", Code: "oneof", Message: "failed the oneof rule"},
+		}},
+		{name: "nested paths", body: `{` + ok + `,"people":[{"name":"a","role":"b"},{"role":"b"}]}`, wantStatus: http.StatusUnprocessableEntity, want: []fieldError{
+			{Field: "people[1].name", Code: "required", Message: "is required"},
+		}},
+		{name: "several fields", body: `{"name":"a","count":9}`, wantStatus: http.StatusUnprocessableEntity, want: []fieldError{
+			{Field: "email", Code: "required", Message: "is required"},
+			{Field: "name", Code: "min", Message: "must be at least 2 characters long"},
+			{Field: "count", Code: "max", Message: "must be at most 3"},
+		}},
+		{name: "number type", body: `{` + ok + `,"count":"x"}`, wantStatus: http.StatusUnprocessableEntity, want: []fieldError{
+			{Field: "count", Code: "type", Message: "must be a number"},
+		}},
+		{name: "string type", body: `{"email":5}`, wantStatus: http.StatusUnprocessableEntity, want: []fieldError{
+			{Field: "email", Code: "type", Message: "must be a string"},
+		}},
+		{name: "array type", body: `{` + ok + `,"tags":"a"}`, wantStatus: http.StatusUnprocessableEntity, want: []fieldError{
+			{Field: "tags", Code: "type", Message: "must be an array"},
+		}},
+		{name: "object type", body: `{` + ok + `,"people":[{"name":"a","role":"b","attributes":[]}]}`, wantStatus: http.StatusUnprocessableEntity, want: []fieldError{
+			{Field: "people[0].attributes", Code: "type", Message: "must be an object"},
+		}},
+		{name: "empty body", body: ``, wantStatus: http.StatusBadRequest, wantDetail: "The request body is empty"},
+		{name: "malformed JSON", body: `{"email":`, wantStatus: http.StatusBadRequest, wantDetail: "The request body is not valid JSON"},
+		{name: "too large", body: `{"email":"` + strings.Repeat("a", 200) + `@example.com"}`, wantStatus: http.StatusRequestEntityTooLarge, wantDetail: "The request body must not exceed 128 bytes"},
+	}
+
+	app := newTestApp()
+	app.maxBodyBytes = 128
+	r := gin.New()
+	r.Use(app.BodyLimitMiddleware())
+	r.POST("/probe", func(c *gin.Context) {
+		var req probeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			bindProblem(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := strings.NewReader(tt.body)
+			// Hide the length so the limit is hit while reading
+			w := do(r, http.MethodPost, "/probe", "application/json", struct{ *strings.Reader }{body})
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d; want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if w.Code == http.StatusNoContent {
+				return
+			}
+
+			p := decodeProblem(t, w)
+			if tt.wantDetail != "" && p.Detail != tt.wantDetail {
+				t.Errorf("detail = %q; want %q", p.Detail, tt.wantDetail)
+			}
+			if !reflect.DeepEqual(p.Errors, tt.want) {
+				t.Errorf("errors = %+v; want %+v", p.Errors, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSONFieldPath(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"", ""},
+		{"count", "count"},
+		{"people.0.attributes", "people[0].attributes"},
+		{"people.12.attributes.shirt", "people[12].attributes.shirt"},
+		{"matrix.1.2", "matrix[1][2]"},
+	}
+	for _, tt := range tests {
+		if got := jsonFieldPath(tt.in); got != tt.want {
+			t.Errorf("jsonFieldPath(%q) = %q; want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestProblem(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), "req-1"))
+	})
+	r.GET("/v1/orgs/:org/rosters/:id", func(c *gin.Context) {
+		problem(c, http.StatusNotFound, codeNotFound, "Roster not found")
+		c.Status(http.StatusOK) // aborted responses stay as sent
+	})
+
+	w := do(r, http.MethodGet, "/v1/orgs/7/rosters/3", "", nil)
+	if got := w.Header().Get("Content-Type"); got != problemContentType {
+		t.Errorf("Content-Type = %q; want %q", got, problemContentType)
+	}
+	want := problemDetails{
+		Type:      "urn:rollet:problem:not_found",
+		Title:     "Not Found",
+		Status:    http.StatusNotFound,
+		Detail:    "Roster not found",
+		Instance:  "/v1/orgs/7/rosters/3",
+		Code:      codeNotFound,
+		RequestID: "req-1",
+	}
+	if got := decodeProblem(t, w); !reflect.DeepEqual(got, want) {
+		t.Errorf("problem = %+v; want %+v", got, want)
+	}
+}
+
+func TestDrawProblem(t *testing.T) {
+	tests := []struct {
+		err        error
+		wantStatus int
+		wantField  string
+	}{
+		{randomizer.ErrNoPeople, http.StatusUnprocessableEntity, "people"},
+		{randomizer.ErrInvalidTeamCount, http.StatusUnprocessableEntity, "team_count"},
+		{errors.New("something else"), http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		gin.SetMode(gin.TestMode)
+		r := gin.New()
+		r.GET("/", func(c *gin.Context) { drawProblem(c, tt.err) })
+
+		w := do(r, http.MethodGet, "/", "", nil)
+		p := decodeProblem(t, w)
+		var field string
+		if len(p.Errors) > 0 {
+			field = p.Errors[0].Field
+		}
+		if w.Code != tt.wantStatus || field != tt.wantField {
+			t.Errorf("drawProblem(%v) = %d %q; want %d %q", tt.err, w.Code, field, tt.wantStatus, tt.wantField)
+		}
+	}
+}
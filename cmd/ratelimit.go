@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Aergiaaa/rollet/internal/ratelimit"
+	"github.com/gin-gonic/gin"
+)
+
+const rateLimitStoreMemory = "memory"
+
+// rateLimitOptions holds the limit for each route, keyed "METHOD /path" by
+// the route pattern, and the default for routes without their own.
+type rateLimitOptions struct {
+	defaultLimit ratelimit.Limit
+	routes       map[string]ratelimit.Limit
+	store        ratelimit.Store
+}
+
+// unlimitedRoutes are probes and scrapes that must never be throttled.
+var unlimitedRoutes = map[string]bool{
+	"GET /healthz":   true,
+	"GET /readyz":    true,
+	"GET /metrics":   true,
+	"GET /v1/health": true,
+}
+
+// parseRouteLimits parses entries of the form "METHOD /path=LIMIT".
+func parseRouteLimits(entries []string) (map[string]ratelimit.Limit, error) {
+	routes := make(map[string]ratelimit.Limit, len(entries))
+	for _, entry := range entries {
+		route, limit, ok := strings.Cut(entry, "=")
+		method, path, hasPath := strings.Cut(strings.TrimSpace(route), " ")
+		if !ok || !hasPath || !corsMethods[method] || !strings.HasPrefix(path, "/") {
+			return nil, fmt.Errorf("invalid route limit %q: use METHOD /path=LIMIT", entry)
+		}
+
+		l, err := ratelimit.ParseLimit(limit)
+		if err != nil {
+			return nil, fmt.Errorf("route %s: %w", route, err)
+		}
+		routes[method+" "+path] = l
+	}
+
+	return routes, nil
+}
+
+// limit returns the limit for a route.
+func (o rateLimitOptions) limit(route string) ratelimit.Limit {
+	if unlimitedRoutes[route] {
+		return ratelimit.Limit{}
+	}
+	if l, ok := o.routes[route]; ok {
+		return l
+	}
+	return o.defaultLimit
+}
+
+func (app *app) RateLimitMiddleware() gin.HandlerFunc {
+	// Middleware to throttle each client per route with a token bucket,
+	// advertising the state in RateLimit-* headers
+	return func(c *gin.Context) {
+		if c.FullPath() == "" || app.rateLimit.store == nil {
+			c.Next()
+			return
+		}
+
+		route := c.Request.Method + " " + c.FullPath()
+		limit := app.rateLimit.limit(route)
+		if limit.Unlimited() {
+			c.Next()
+			return
+		}
+
+		// The client IP is held to the limit before any credential is
+		// looked up, so made-up credentials cannot make each request cost
+		// a query. Verified clients are then held to their own limit too.
+		res, err := app.rateLimit.store.Take(c.Request.Context(), route+"|ip:"+c.ClientIP(), limit)
+		if err == nil && res.Allowed {
+			if key := app.rateLimitKey(c); key != "" {
+				var own ratelimit.Result
+				own, err = app.rateLimit.store.Take(c.Request.Context(), route+"|"+key, limit)
+				if !own.Allowed || own.Remaining < res.Remaining {
+					res = own
+				}
+			}
+		}
+		if err != nil {
+			// Fail open: an unavailable store must not take the API down.
+			slog.WarnContext(c.Request.Context(), "rate limit store failed", "error", err)
+			c.Next()
+			return
+		}
+
+		c.Header("RateLimit-Limit", strconv.Itoa(res.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+		c.Header("RateLimit-Policy", fmt.Sprintf("%d;w=%d", limit.Requests, ceilSeconds(limit.Period)))
+
+		if !res.Allowed {
+			app.metrics.RateLimited(c.Request.Method, c.FullPath())
+			c.Header("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+			problem(c, http.StatusTooManyRequests, codeRateLimited, "Too many requests, retry later")
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// rateLimitKey identifies the client by a valid API key, else by the user
+// of a bearer token that AuthMiddleware would accept, and returns "" for
+// anonymous clients. Only verified credentials count, otherwise a client
+// could dodge its limit by sending made-up ones.
+func (app *app) rateLimitKey(c *gin.Context) string {
+	if requestAPIKey(c) != "" {
+		if k, err := app.verifiedAPIKey(c); err == nil && k != nil {
+			return "apikey:" + strconv.Itoa(k.Id)
+		}
+		return ""
+	}
+	if token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok {
+		userId, version, err := app.tokenClaims(token)
+		if err != nil {
+			return ""
+		}
+		if u, err := app.requestUser(c, userId); err == nil && u != nil && u.TokenVersion == version {
+			return "user:" + strconv.Itoa(userId)
+		}
+	}
+
+	return ""
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// warnUnknownRouteLimits logs configured route limits that match no route,
+// which are most likely typos.
+func (app *app) warnUnknownRouteLimits(routes gin.RoutesInfo) {
+	known := make(map[string]bool, len(routes))
+	for _, r := range routes {
+		known[r.Method+" "+r.Path] = true
+	}
+	for route := range app.rateLimit.routes {
+		if !known[route] {
+			slog.Warn("rate limit configured for unknown route", "route", route)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Aergiaaa/rollet/internal/apikey"
+	"github.com/Aergiaaa/rollet/internal/database"
+	"github.com/Aergiaaa/rollet/internal/ratelimit"
+	"github.com/gin-gonic/gin"
+)
+
+func TestRateLimitKey(t *testing.T) {
+	a := newTestApp()
+	a.jwtSecret = "test-secret"
+
+	key, _, hash, err := apikey.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	unknownKey, _, _, _ := apikey.Generate()
+
+	users := &stubUsers{users: map[int]*database.User{3: {Id: 3, TokenVersion: 2}}}
+	keys := &stubAPIKeys{keys: map[string]*database.APIKey{hash: {Id: 9, UserId: 3}}}
+	a.models = database.Models{Users: users, APIKeys: keys}
+
+	current, _ := a.issueToken(&database.User{Id: 3, TokenVersion: 2})
+	stale, _ := a.issueToken(&database.User{Id: 3, TokenVersion: 1})
+	missing, _ := a.issueToken(&database.User{Id: 4})
+	forged, _ := (&app{jwtSecret: "other-secret"}).issueToken(&database.User{Id: 3, TokenVersion: 2})
+
+	tests := []struct {
+		name   string
+		header string
+		value  string
+		want   string
+	}{
+		{"anonymous", "", "", ""},
+		{"api key", "X-API-Key", key, "apikey:9"},
+		{"api key in authorization", "Authorization", "ApiKey " + key, "apikey:9"},
+		{"unknown api key", "X-API-Key", unknownKey, ""},
+		{"malformed api key", "X-API-Key", "nope", ""},
+		{"current token", "Authorization", "Bearer " + current, "user:3"},
+		{"token from before a password change", "Authorization", "Bearer " + stale, ""},
+		{"token of a deleted user", "Authorization", "Bearer " + missing, ""},
+		{"token with another secret", "Authorization", "Bearer " + forged, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				c.Request.Header.Set(tt.header, tt.value)
+			}
+			if got := a.rateLimitKey(c); got != tt.want {
+				t.Errorf("rateLimitKey = %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimitMiddlewareThrottlesIPBeforeLookups(t *testing.T) {
+	app := newTestApp()
+	keys := &stubAPIKeys{}
+	app.models = database.Models{APIKeys: keys}
+	app.rateLimit = rateLimitOptions{
+		defaultLimit: ratelimit.Limit{Requests: 2, Period: time.Minute},
+		store:        ratelimit.NewMemoryStore(),
+	}
+
+	r := gin.New()
+	r.Use(app.RateLimitMiddleware())
+	r.GET("/v1/user/history", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	var codes []int
+	for range 4 {
+		// A new made-up key each time, as an attacker would send
+		key, _, _, _ := apikey.Generate()
+		req := httptest.NewRequest(http.MethodGet, "/v1/user/history", nil)
+		req.Header.Set("X-API-Key", key)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		codes = append(codes, w.Code)
+	}
+
+	want := []int{http.StatusNoContent, http.StatusNoContent, http.StatusTooManyRequests, http.StatusTooManyRequests}
+	for i := range want {
+		if codes[i] != want[i] {
+			t.Fatalf("statuses = %v; want %v", codes, want)
+		}
+	}
+	if keys.lookups != 2 {
+		t.Errorf("%d key lookups; want 2, none once the IP is throttled", keys.lookups)
+	}
+}
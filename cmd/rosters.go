@@ -0,0 +1,303 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/Aergiaaa/rollet/internal/database"
+	"github.com/gin-gonic/gin"
+)
+
+// maxRosterNameLength is the size of the rosters.name column.
+const maxRosterNameLength = 100
+
+type rosterRequest struct {
+	Name   string        `json:"name" binding:"required"`
+	People []PersonInput `json:"people" binding:"required,min=1,dive"`
+}
+
+type rosterListResponse struct {
+	Rosters []*database.Roster `json:"rosters"`
+}
+
+type rosterDrawRequest struct {
+	TeamCount int                  `json:"team_count" binding:"required,min=1"`
+	Opts      RandomizeRequestOpts `json:"options"`
+}
+
+// createRoster godoc
+// @Summary      Create a roster
+// @Description  Saves a list of people to the organisation, so every member can draw teams from it. Needs the member role.
+// @Tags         orgs
+// @Accept       json
+// @Produce      json
+// @Param        org   path      int            true  "Organisation ID"
+// @Param        body  body      rosterRequest  true  "Roster"
+// @Success      201   {object}  database.Roster
+// @Failure      400   {object}  problemDetails
+// @Failure      401   {object}  problemDetails
+// @Failure      403   {object}  problemDetails
+// @Failure      404   {object}  problemDetails
+// @Failure      413   {object}  problemDetails
+// @Failure      422   {object}  problemDetails
+// @Failure      500   {object}  problemDetails
+// @Router       /v1/orgs/{org}/rosters [post]
+func (app *app) createRoster(c *gin.Context) {
+	membership := c.MustGet("membership").(*database.Membership)
+	userObj := c.MustGet("user").(*database.User)
+
+	r, ok := app.bindRoster(c)
+	if !ok {
+		return
+	}
+	r.OrgId = membership.OrgId
+	r.CreatedBy = &userObj.Id
+
+	if err := app.models.Rosters.Insert(c.Request.Context(), r); err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to create roster", "error", err)
+		problem(c, http.StatusInternalServerError, codeInternal, "Failed to create roster")
+		return
+	}
+
+	app.audit(c, auditRoster(auditRosterCreated, r))
+	c.JSON(http.StatusCreated, r)
+}
+
+// listRosters godoc
+// @Summary      List rosters
+// @Description  Returns the organisation's rosters by name, with their people. Needs any role.
+// @Tags         orgs
+// @Produce      json
+// @Param        org  path      int  true  "Organisation ID"
+// @Success      200  {object}  rosterListResponse
+// @Failure      401  {object}  problemDetails
+// @Failure      404  {object}  problemDetails
+// @Failure      500  {object}  problemDetails
+// @Router       /v1/orgs/{org}/rosters [get]
+func (app *app) listRosters(c *gin.Context) {
+	membership := c.MustGet("membership").(*database.Membership)
+
+	rosters, err := app.models.Rosters.ListByOrgId(c.Request.Context(), membership.OrgId)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to list rosters", "error", err)
+		problem(c, http.StatusInternalServerError, codeInternal, "Failed to retrieve data")
+		return
+	}
+
+	c.JSON(http.StatusOK, rosterListResponse{Rosters: rosters})
+}
+
+// getRoster godoc
+// @Summary      Get a roster
+// @Description  Returns one of the organisation's rosters. Needs any role.
+// @Tags         orgs
+// @Produce      json
+// @Param        org  path      int  true  "Organisation ID"
+// @Param        id   path      int  true  "Roster ID"
+// @Success      200  {object}  database.Roster
+// @Failure      401  {object}  problemDetails
+// @Failure      404  {object}  problemDetails
+// @Failure      500  {object}  problemDetails
+// @Router       /v1/orgs/{org}/rosters/{id} [get]
+func (app *app) getRoster(c *gin.Context) {
+	r, ok := app.findRoster(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, r)
+}
+
+// updateRoster godoc
+// @Summary      Replace a roster
+// @Description  Replaces the name and people of one of the organisation's rosters. Draws already made from it are unchanged. Needs the member role.
+// @Tags         orgs
+// @Accept       json
+// @Produce      json
+// @Param        org   path      int            true  "Organisation ID"
+// @Param        id    path      int            true  "Roster ID"
+// @Param        body  body      rosterRequest  true  "Roster"
+// @Success      200   {object}  database.Roster
+// @Failure      400   {object}  problemDetails
+// @Failure      401   {object}  problemDetails
+// @Failure      403   {object}  problemDetails
+// @Failure      404   {object}  problemDetails
+// @Failure      413   {object}  problemDetails
+// @Failure      422   {object}  problemDetails
+// @Failure      500   {object}  problemDetails
+// @Router       /v1/orgs/{org}/rosters/{id} [put]
+func (app *app) updateRoster(c *gin.Context) {
+	membership := c.MustGet("membership").(*database.Membership)
+
+	id, ok := rosterID(c)
+	if !ok {
+		return
+	}
+	r, ok := app.bindRoster(c)
+	if !ok {
+		return
+	}
+	r.Id = id
+	r.OrgId = membership.OrgId
+
+	err := app.models.Rosters.Update(c.Request.Context(), r)
+	if errors.Is(err, sql.ErrNoRows) {
+		problem(c, http.StatusNotFound, codeNotFound, "Roster not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to update roster", "error", err)
+		problem(c, http.StatusInternalServerError, codeInternal, "Failed to update roster")
+		return
+	}
+
+	app.audit(c, auditRoster(auditRosterUpdated, r))
+	c.JSON(http.StatusOK, r)
+}
+
+// deleteRoster godoc
+// @Summary      Delete a roster
+// @Description  Removes one of the organisation's rosters. Draws made from it stay in the history. Needs the admin role.
+// @Tags         orgs
+// @Param        org  path  int  true  "Organisation ID"
+// @Param        id   path  int  true  "Roster ID"
+// @Success      204
+// @Failure      401  {object}  problemDetails
+// @Failure      403  {object}  problemDetails
+// @Failure      404  {object}  problemDetails
+// @Failure      500  {object}  problemDetails
+// @Router       /v1/orgs/{org}/rosters/{id} [delete]
+func (app *app) deleteRoster(c *gin.Context) {
+	membership := c.MustGet("membership").(*database.Membership)
+
+	id, ok := rosterID(c)
+	if !ok {
+		return
+	}
+
+	err := app.models.Rosters.Delete(c.Request.Context(), membership.OrgId, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		problem(c, http.StatusNotFound, codeNotFound, "Roster not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to delete roster", "error", err)
+		problem(c, http.StatusInternalServerError, codeInternal, "Failed to delete roster")
+		return
+	}
+
+	app.audit(c, auditRoster(auditRosterDeleted, &database.Roster{Id: id, OrgId: membership.OrgId}))
+	c.Status(http.StatusNoContent)
+}
+
+// drawRoster godoc
+// @Summary      Randomize a roster
+// @Description  Assigns the roster's people like /v1/orgs/{org}/random/custom and saves the draw to the organisation's history. Needs the member role.
+// @Tags         orgs
+// @Accept       json
+// @Produce      json,text/csv,application/vnd.openxmlformats-officedocument.spreadsheetml.sheet,text/markdown,application/pdf
+// @Param        org     path      int                true   "Organisation ID"
+// @Param        id      path      int                true   "Roster ID"
+// @Param        body    body      rosterDrawRequest  true   "Draw options"
+// @Param        format  query     string             false  "Response format, overriding the Accept header"  Enums(json, csv, xlsx, md, pdf)
+// @Success      200   {object}  RandomizeResponse
+// @Failure      400   {object}  problemDetails
+// @Failure      401   {object}  problemDetails
+// @Failure      403   {object}  problemDetails
+// @Failure      404   {object}  problemDetails
+// @Failure      406   {object}  problemDetails
+// @Failure      422   {object}  problemDetails
+// @Failure      500   {object}  problemDetails
+// @Router       /v1/orgs/{org}/rosters/{id}/random [post]
+func (app *app) drawRoster(c *gin.Context) {
+	var req rosterDrawRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindProblem(c, err)
+		return
+	}
+
+	r, ok := app.findRoster(c)
+	if !ok {
+		return
+	}
+
+	people := make([]PersonInput, len(r.People))
+	for i, p := range r.People {
+		people[i] = PersonInput{Name: p.Name, Role: p.Role, Attributes: p.Attributes}
+	}
+	app.runDraw(c, RandomizeRequest{People: people, TeamCount: req.TeamCount, Opts: req.Opts})
+}
+
+// bindRoster reads and checks a roster from the request body, responding
+// with a problem if it is invalid.
+func (app *app) bindRoster(c *gin.Context) (*database.Roster, bool) {
+	var req rosterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindProblem(c, err)
+		return nil, false
+	}
+
+	req.Name = normalizeText(req.Name)
+	errs := checkText("name", req.Name, maxRosterNameLength)
+	errs = append(errs, app.limits.checkPeople(req.People)...)
+	if len(errs) > 0 {
+		problem(c, http.StatusUnprocessableEntity, codeValidationFailed, "The request has invalid fields", errs...)
+		return nil, false
+	}
+
+	r := &database.Roster{Name: req.Name, People: make(database.RosterPeople, len(req.People))}
+	for i, p := range req.People {
+		r.People[i] = database.RosterPerson{Name: p.Name, Role: p.Role, Attributes: p.Attributes}
+	}
+	return r, true
+}
+
+// findRoster returns the roster named by the :id parameter, responding 404
+// if the organisation has no such roster.
+func (app *app) findRoster(c *gin.Context) (*database.Roster, bool) {
+	membership := c.MustGet("membership").(*database.Membership)
+
+	id, ok := rosterID(c)
+	if !ok {
+		return nil, false
+	}
+
+	r, err := app.models.Rosters.Get(c.Request.Context(), membership.OrgId, id)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to retrieve roster", "error", err)
+		problem(c, http.StatusInternalServerError, codeInternal, "Failed to retrieve data")
+		return nil, false
+	}
+	if r == nil {
+		problem(c, http.StatusNotFound, codeNotFound, "Roster not found")
+		return nil, false
+	}
+	return r, true
+}
+
+func rosterID(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		problem(c, http.StatusNotFound, codeNotFound, "Roster not found")
+		return 0, false
+	}
+	return id, true
+}
+
+// auditRoster is an audit entry about one of the organisation's rosters.
+func auditRoster(action string, r *database.Roster) database.AuditEntry {
+	var details map[string]any
+	if r.Name != "" {
+		details = map[string]any{"name": r.Name, "people": len(r.People)}
+	}
+	return database.AuditEntry{
+		Action:     action,
+		OrgId:      &r.OrgId,
+		TargetType: "roster",
+		TargetId:   strconv.Itoa(r.Id),
+		Details:    auditDetails(details),
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Aergiaaa/rollet/internal/database"
+	"github.com/gin-gonic/gin"
+)
+
+// stubRosters keeps rosters in memory.
+type stubRosters struct {
+	rosters map[int]*database.Roster
+}
+
+func (s *stubRosters) Insert(_ context.Context, r *database.Roster) error {
+	r.Id = len(s.rosters) + 1
+	s.rosters[r.Id] = r
+	return nil
+}
+
+func (s *stubRosters) Get(_ context.Context, orgId, id int) (*database.Roster, error) {
+	if r, ok := s.rosters[id]; ok && r.OrgId == orgId {
+		return r, nil
+	}
+	return nil, nil
+}
+
+func (s *stubRosters) ListByOrgId(_ context.Context, orgId int) ([]*database.Roster, error) {
+	rosters := []*database.Roster{}
+	for _, r := range s.rosters {
+		if r.OrgId == orgId {
+			rosters = append(rosters, r)
+		}
+	}
+	return rosters, nil
+}
+
+func (s *stubRosters) Update(_ context.Context, r *database.Roster) error {
+	old, ok := s.rosters[r.Id]
+	if !ok || old.OrgId != r.OrgId {
+		return sql.ErrNoRows
+	}
+	s.rosters[r.Id] = r
+	return nil
+}
+
+func (s *stubRosters) Delete(_ context.Context, orgId, id int) error {
+	if r, ok := s.rosters[id]; !ok || r.OrgId != orgId {
+		return sql.ErrNoRows
+	}
+	delete(s.rosters, id)
+	return nil
+}
+
+func newRosterRouter(t *testing.T, role string) (*gin.Engine, *stubRosters, *stubPeople, *stubAudit) {
+	t.Helper()
+	app := newTestApp()
+	rosters := &stubRosters{rosters: map[int]*database.Roster{
+		1: {Id: 1, OrgId: 7, Name: "Club", People: database.RosterPeople{
+			{Name: "Ann", Role: "dev"}, {Name: "Bob", Role: "qa", Attributes: database.Attributes{"shirt": "M"}},
+		}},
+		2: {Id: 2, OrgId: 8, Name: "Elsewhere", People: database.RosterPeople{{Name: "Cy", Role: "pm"}}},
+	}}
+	people := &stubPeople{}
+	audit := &stubAudit{}
+	app.models = database.Models{Rosters: rosters, People: people, Audit: audit}
+
+	r := gin.New()
+	g := r.Group("/v1/orgs/:org", withMember(&database.User{Id: 3}, 7, role))
+	g.POST("/rosters", app.RequireOrgRole(database.RoleMember), app.createRoster)
+	g.GET("/rosters", app.RequireOrgRole(database.RoleViewer), app.listRosters)
+	g.GET("/rosters/:id", app.RequireOrgRole(database.RoleViewer), app.getRoster)
+	g.PUT("/rosters/:id", app.RequireOrgRole(database.RoleMember), app.updateRoster)
+	g.DELETE("/rosters/:id", app.RequireOrgRole(database.RoleAdmin), app.deleteRoster)
+	g.POST("/rosters/:id/random", app.RequireOrgRole(database.RoleMember), app.drawRoster)
+	return r, rosters, people, audit
+}
+
+func TestRosterRoutes(t *testing.T) {
+	const valid = `{"name":" Club ","people":[{"name":"Ann","role":"dev"}]}`
+
+	tests := []struct {
+		name       string
+		role       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantField  string
+		wantAudit  string
+	}{
+		{name: "create", role: database.RoleMember, method: http.MethodPost, path: "/rosters", body: valid, wantStatus: http.StatusCreated, wantAudit: auditRosterCreated},
+		{name: "create as viewer", role: database.RoleViewer, method: http.MethodPost, path: "/rosters", body: valid, wantStatus: http.StatusForbidden},
+		{name: "create without people", role: database.RoleMember, method: http.MethodPost, path: "/rosters", body: `{"name":"Club","people":[]}`, wantStatus: http.StatusUnprocessableEntity, wantField: "people"},
+		{name: "create with a blank name", role: database.RoleMember, method: http.MethodPost, path: "/rosters", body: `{"name":"  ","people":[{"name":"Ann","role":"dev"}]}`, wantStatus: http.StatusUnprocessableEntity, wantField: "name"},
+		{name: "create with a long name", role: database.RoleMember, method: http.MethodPost, path: "/rosters", body: `{"name":"` + strings.Repeat("a", maxRosterNameLength+1) + `","people":[{"name":"Ann","role":"dev"}]}`, wantStatus: http.StatusUnprocessableEntity, wantField: "name"},
+		{name: "create with an invalid person", role: database.RoleMember, method: http.MethodPost, path: "/rosters", body: `{"name":"Club","people":[{"name":"Ann","role":"` + strings.Repeat("r", 21) + `"}]}`, wantStatus: http.StatusUnprocessableEntity, wantField: "people[0].role"},
+		{name: "list", role: database.RoleViewer, method: http.MethodGet, path: "/rosters", wantStatus: http.StatusOK},
+		{name: "get", role: database.RoleViewer, method: http.MethodGet, path: "/rosters/1", wantStatus: http.StatusOK},
+		{name: "get another organisation's roster", role: database.RoleOwner, method: http.MethodGet, path: "/rosters/2", wantStatus: http.StatusNotFound},
+		{name: "get a malformed id", role: database.RoleViewer, method: http.MethodGet, path: "/rosters/x", wantStatus: http.StatusNotFound},
+		{name: "update", role: database.RoleMember, method: http.MethodPut, path: "/rosters/1", body: valid, wantStatus: http.StatusOK, wantAudit: auditRosterUpdated},
+		{name: "update another organisation's roster", role: database.RoleMember, method: http.MethodPut, path: "/rosters/2", body: valid, wantStatus: http.StatusNotFound},
+		{name: "delete as member", role: database.RoleMember, method: http.MethodDelete, path: "/rosters/1", wantStatus: http.StatusForbidden},
+		{name: "delete", role: database.RoleAdmin, method: http.MethodDelete, path: "/rosters/1", wantStatus: http.StatusNoContent, wantAudit: auditRosterDeleted},
+		{name: "delete another organisation's roster", role: database.RoleAdmin, method: http.MethodDelete, path: "/rosters/2", wantStatus: http.StatusNotFound},
+		{name: "draw", role: database.RoleMember, method: http.MethodPost, path: "/rosters/1/random", body: `{"team_count":2}`, wantStatus: http.StatusOK, wantAudit: auditDrawSaved},
+		{name: "draw as viewer", role: database.RoleViewer, method: http.MethodPost, path: "/rosters/1/random", body: `{"team_count":2}`, wantStatus: http.StatusForbidden},
+		{name: "draw without a team count", role: database.RoleMember, method: http.MethodPost, path: "/rosters/1/random", body: `{}`, wantStatus: http.StatusUnprocessableEntity, wantField: "team_count"},
+		{name: "draw too many teams", role: database.RoleMember, method: http.MethodPost, path: "/rosters/1/random", body: `{"team_count":11}`, wantStatus: http.StatusUnprocessableEntity, wantField: "team_count"},
+		{name: "draw another organisation's roster", role: database.RoleMember, method: http.MethodPost, path: "/rosters/2/random", body: `{"team_count":1}`, wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _, _, audit := newRosterRouter(t, tt.role)
+
+			w := do(r, tt.method, "/v1/orgs/7"+tt.path, "application/json", strings.NewReader(tt.body))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d; want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+
+			if tt.wantField != "" {
+				p := decodeProblem(t, w)
+				if len(p.Errors) == 0 || p.Errors[0].Field != tt.wantField {
+					t.Errorf("errors = %+v; want %s first", p.Errors, tt.wantField)
+				}
+			}
+
+			var want []string
+			if tt.wantAudit != "" {
+				want = []string{tt.wantAudit}
+			}
+			if got := audit.actions(); !reflect.DeepEqual(got, want) {
+				t.Errorf("audit = %v; want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestCreateRosterStoresNormalizedPeople(t *testing.T) {
+	r, rosters, _, _ := newRosterRouter(t, database.RoleMember)
+
+	body := `{"name":" Club ","people":[{"name":" José ","role":"dev","attributes":{" shirt ":"M"}}]}`
+	w := do(r, http.MethodPost, "/v1/orgs/7/rosters", "application/json", strings.NewReader(body))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+
+	var got database.Roster
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	stored := rosters.rosters[got.Id]
+	if stored == nil || stored.OrgId != 7 || stored.CreatedBy == nil || *stored.CreatedBy != 3 {
+		t.Fatalf("stored roster = %+v; want one of organisation 7 created by user 3", stored)
+	}
+	want := database.RosterPeople{{Name: "José", Role: "dev", Attributes: database.Attributes{"shirt": "M"}}}
+	if stored.Name != "Club" || !reflect.DeepEqual(stored.People, want) {
+		t.Errorf("stored %q with %+v; want %q with %+v", stored.Name, stored.People, "Club", want)
+	}
+}
+
+func TestDrawRosterSavesToOrganisation(t *testing.T) {
+	r, _, people, _ := newRosterRouter(t, database.RoleMember)
+
+	w := do(r, http.MethodPost, "/v1/orgs/7/rosters/1/random", "application/json", strings.NewReader(`{"team_count":2}`))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+
+	if len(people.saved) != 1 {
+		t.Fatalf("%d draws saved; want 1", len(people.saved))
+	}
+	draw := people.saved[0]
+	if draw.OrgId == nil || *draw.OrgId != 7 {
+		t.Errorf("draw org = %v; want 7", draw.OrgId)
+	}
+	names := map[string]string{}
+	for _, p := range draw.People {
+		names[p.Name] = p.Attributes["shirt"]
+	}
+	if want := map[string]string{"Ann": "", "Bob": "M"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("drew %v; want %v", names, want)
+	}
+}
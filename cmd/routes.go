@@ -1,42 +1,65 @@
 package main
 
 import (
+	"log/slog"
 	"net/http"
-	"time"
 
+	"github.com/Aergiaaa/rollet/internal/database"
+	"github.com/Aergiaaa/rollet/internal/rbac"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 
 	_ "github.com/Aergiaaa/rollet/docs" // swagger docs
 )
 
 func (app *app) routes() http.Handler {
-	g := gin.Default()
-
-	config := cors.Config{
-		AllowOrigins:     []string{"*"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
-		AllowCredentials: true,
-		MaxAge:           12 * time.Hour,
+	gin.SetMode(gin.ReleaseMode)
+	useJSONFieldNames()
+	g := gin.New()
+	g.HandleMethodNotAllowed = true
+	if err := g.SetTrustedProxies(app.trustedProxies); err != nil {
+		slog.Error("invalid trusted proxies, trusting none", "error", err)
+		_ = g.SetTrustedProxies(nil)
 	}
-	g.Use(cors.New(config))
+	g.Use(
+		app.RequestIDMiddleware(),
+		otelgin.Middleware("rollet"),
+		app.LoggerMiddleware(),
+		app.RecoveryMiddleware(),
+		app.MetricsMiddleware(),
+	)
+
+	g.Use(cors.New(app.cors.config()))
+	g.Use(app.RateLimitMiddleware())
+	g.Use(app.BodyLimitMiddleware())
 
 	v1 := g.Group("/v1")
 	{
 		v1.POST("/random/default", app.createRandomize)
+		v1.POST("/random/import", app.importRandomize)
 
 		v1.POST("/auth/register", app.register)
 		v1.POST("/auth/login", app.login)
 		// TODO: Implement Google OAuth login
 		v1.POST("/auth/google", app.googleAuth)
 
+		v1.GET("/share/:slug", app.getShare)
+
+		v1.POST("/live", app.createLive)
+		v1.GET("/live/:id", app.getLive)
+		v1.GET("/live/:id/events", app.liveEvents)
+		v1.POST("/live/:id/reveal", app.revealLive)
+		v1.DELETE("/live/:id", app.endLive)
+
 		v1.GET("/health", func(c *gin.Context) {
-			c.JSON(http.StatusOK, gin.H{
-				"status": "ok",
-			})
+			if !app.lifecycle.ready() {
+				c.JSON(http.StatusServiceUnavailable, healthResponse{Status: app.lifecycle.String()})
+				return
+			}
+			c.JSON(http.StatusOK, healthResponse{Status: "ok"})
 		})
 	}
 
@@ -44,9 +67,71 @@ func (app *app) routes() http.Handler {
 	authGroup.Use(app.AuthMiddleware())
 	{
 		authGroup.POST("/user/random/custom", app.createCustomRandomize)
+		authGroup.POST("/user/random/import", app.importRandomize)
 		authGroup.GET("/user/history", app.getHistory)
+		authGroup.DELETE("/user/history/:id", app.deleteHistory)
+		authGroup.PUT("/user/password", app.changePassword)
+		authGroup.POST("/user/api-keys", app.createAPIKey)
+		authGroup.GET("/user/api-keys", app.listAPIKeys)
+		authGroup.DELETE("/user/api-keys/:id", app.revokeAPIKey)
+		authGroup.POST("/user/draws/:id/share", app.createShare)
+		authGroup.GET("/user/shares", app.listShares)
+		authGroup.DELETE("/user/shares/:slug", app.revokeShare)
+		authGroup.POST("/user/webhooks", app.createWebhook)
+		authGroup.GET("/user/webhooks", app.listWebhooks)
+		authGroup.DELETE("/user/webhooks/:id", app.deleteWebhook)
+		authGroup.GET("/user/webhooks/:id/deliveries", app.listDeliveries)
+		authGroup.POST("/user/webhooks/:id/deliveries/:delivery/redeliver", app.redeliverWebhook)
+
+		authGroup.POST("/orgs", app.createOrg)
+		authGroup.GET("/orgs", app.listOrgs)
+	}
+
+	// AuthMiddleware resolves the caller's membership from :org; each route
+	// then names the least role it needs.
+	orgGroup := authGroup.Group("/orgs/:org")
+	{
+		orgGroup.GET("", app.RequireOrgRole(database.RoleViewer), app.getOrg)
+		orgGroup.DELETE("", app.RequireOrgRole(database.RoleOwner), app.deleteOrg)
+		orgGroup.POST("/members", app.RequireOrgRole(database.RoleAdmin), app.addOrgMember)
+		orgGroup.PATCH("/members/:user", app.RequireOrgRole(database.RoleAdmin), app.updateOrgMember)
+		orgGroup.DELETE("/members/:user", app.RequireOrgRole(database.RoleViewer), app.removeOrgMember)
+		orgGroup.POST("/rosters", app.RequireOrgRole(database.RoleMember), app.createRoster)
+		orgGroup.GET("/rosters", app.RequireOrgRole(database.RoleViewer), app.listRosters)
+		orgGroup.GET("/rosters/:id", app.RequireOrgRole(database.RoleViewer), app.getRoster)
+		orgGroup.PUT("/rosters/:id", app.RequireOrgRole(database.RoleMember), app.updateRoster)
+		orgGroup.DELETE("/rosters/:id", app.RequireOrgRole(database.RoleAdmin), app.deleteRoster)
+		orgGroup.POST("/rosters/:id/random", app.RequireOrgRole(database.RoleMember), app.drawRoster)
+		orgGroup.POST("/random/custom", app.RequireOrgRole(database.RoleMember), app.createOrgRandomize)
+		orgGroup.POST("/random/import", app.RequireOrgRole(database.RoleMember), app.importOrgRandomize)
+		orgGroup.GET("/history", app.RequireOrgRole(database.RoleViewer), app.getOrgHistory)
+		orgGroup.DELETE("/history/:id", app.RequireOrgRole(database.RoleAdmin), app.deleteOrgHistory)
+		orgGroup.POST("/draws/:id/share", app.RequireOrgRole(database.RoleMember), app.createOrgShare)
+		orgGroup.GET("/audit", app.RequireOrgRole(database.RoleOwner), app.listOrgAudit)
+		orgGroup.POST("/api-keys", app.RequireOrgRole(database.RoleAdmin), app.createOrgAPIKey)
+		orgGroup.GET("/api-keys", app.RequireOrgRole(database.RoleAdmin), app.listOrgAPIKeys)
+		orgGroup.DELETE("/api-keys/:id", app.RequireOrgRole(database.RoleAdmin), app.revokeOrgAPIKey)
 	}
 
+	// Operator routes; each names the permission the caller's system-wide
+	// role must grant.
+	adminGroup := authGroup.Group("/admin")
+	{
+		adminGroup.GET("/users", app.RequirePermission(rbac.UsersRead), app.listUsers)
+		adminGroup.GET("/users/:id", app.RequirePermission(rbac.UsersRead), app.getUser)
+		adminGroup.POST("/users/:id/disable", app.RequirePermission(rbac.UsersWrite), app.disableUser)
+		adminGroup.POST("/users/:id/enable", app.RequirePermission(rbac.UsersWrite), app.enableUser)
+		adminGroup.POST("/users/:id/reset-password", app.RequirePermission(rbac.PasswordsReset), app.resetUserPassword)
+		adminGroup.PUT("/users/:id/role", app.RequirePermission(rbac.RolesWrite), app.setUserRole)
+		adminGroup.DELETE("/users/:id", app.RequirePermission(rbac.UsersDelete), app.deleteUser)
+		adminGroup.GET("/stats", app.RequirePermission(rbac.StatsRead), app.getStats)
+		adminGroup.GET("/audit", app.RequirePermission(rbac.AuditRead), app.listAudit)
+	}
+
+	g.GET("/healthz", app.healthz)
+	g.GET("/readyz", app.readyz)
+	g.GET("/metrics", app.metricsHandler())
+
 	{
 		g.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 		g.GET("/docs", func(ctx *gin.Context) {
@@ -54,5 +139,16 @@ func (app *app) routes() http.Handler {
 		})
 
 	}
+
+	g.NoRoute(func(c *gin.Context) {
+		problem(c, http.StatusNotFound, codeNotFound, "No route matches "+c.Request.URL.Path)
+	})
+	g.NoMethod(func(c *gin.Context) {
+		problem(c, http.StatusMethodNotAllowed, codeMethodNotAllowed, c.Request.Method+" is not allowed on "+c.Request.URL.Path)
+	})
+
+	app.warnUnknownRouteLimits(g.Routes())
+	warnUnknownScopedRoutes(g.Routes())
+
 	return g
 }
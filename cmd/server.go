@@ -1,22 +1,164 @@
 package main
 
 import (
-	"fmt"
-	"log"
+	"context"
+	"errors"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync/atomic"
+	"syscall"
 	"time"
 )
 
-func (app *app) serve() error {
+// Lifecycle states of the server, reported by the health endpoints.
+const (
+	stateStarting int32 = iota
+	stateReady
+	stateDraining
+	stateStopped
+)
+
+// lifecycle tracks whether the server is alive and whether it should
+// receive traffic. Readiness drops as soon as shutdown begins so load
+// balancers stop routing new requests while in-flight ones drain.
+type lifecycle struct {
+	state atomic.Int32
+}
+
+func (l *lifecycle) set(state int32) {
+	l.state.Store(state)
+}
+
+func (l *lifecycle) ready() bool {
+	return l.state.Load() == stateReady
+}
+
+func (l *lifecycle) alive() bool {
+	return l.state.Load() != stateStopped
+}
+
+func (l *lifecycle) String() string {
+	switch l.state.Load() {
+	case stateStarting:
+		return "starting"
+	case stateReady:
+		return "ready"
+	case stateDraining:
+		return "draining"
+	default:
+		return "stopped"
+	}
+}
+
+// serve runs the HTTP server for handler until it fails or the process
+// receives SIGINT or SIGTERM. On a signal it stops accepting connections and waits up to
+// shutdownTimeout for in-flight requests, such as draws being saved, to
+// finish. With TLS configured it serves HTTPS and HTTP/2, and optionally
+// redirects plain HTTP from a second port.
+func (app *app) serve(handler http.Handler) error {
 	s := &http.Server{
-		Addr:         fmt.Sprintf(":%d", app.port),
-		Handler:      app.routes(),
+		Addr:         net.JoinHostPort(app.host, strconv.Itoa(app.port)),
+		Handler:      handler,
 		IdleTimeout:  time.Minute,
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 30 * time.Second,
 	}
+	// Live event streams never finish on their own; end them so Shutdown
+	// does not wait for the timeout.
+	s.RegisterOnShutdown(app.live.Close)
+	servers := []*http.Server{s}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	log.Printf("Starting server on %s", s.Addr)
+	if app.tls.enabled() {
+		tlsConfig, err := app.tls.tlsConfig(ctx)
+		if err != nil {
+			return err
+		}
+		s.TLSConfig = tlsConfig
+
+		if app.tls.redirectPort != 0 {
+			servers = append(servers, &http.Server{
+				Addr:         net.JoinHostPort(app.host, strconv.Itoa(app.tls.redirectPort)),
+				Handler:      redirectHandler(app.port),
+				ReadTimeout:  5 * time.Second,
+				WriteTimeout: 5 * time.Second,
+			})
+		}
+	}
+
+	errCh := make(chan error, len(servers))
+	for _, srv := range servers {
+		ln, err := net.Listen("tcp", srv.Addr)
+		if err != nil {
+			for _, started := range servers {
+				started.Close()
+			}
+			return err
+		}
+
+		go func() {
+			if srv.TLSConfig != nil {
+				errCh <- srv.ServeTLS(ln, "", "")
+				return
+			}
+			errCh <- srv.Serve(ln)
+		}()
+	}
+
+	if app.tls.enabled() {
+		slog.Info("Starting server", "addr", s.Addr, "tls", true)
+		if len(servers) > 1 {
+			slog.Info("Redirecting plain HTTP to HTTPS", "addr", servers[1].Addr)
+		}
+	} else {
+		slog.Info("Starting server", "addr", s.Addr, "tls", false)
+	}
+	app.lifecycle.set(stateReady)
+
+	select {
+	case err := <-errCh:
+		app.lifecycle.set(stateStopped)
+		for _, srv := range servers {
+			srv.Close()
+		}
+		return err
+	case <-ctx.Done():
+	}
+	stop()
+
+	slog.Info("Shutting down, draining requests", "timeout", app.shutdownTimeout.String())
+	app.lifecycle.set(stateDraining)
+
+	// give load balancers a moment to see the failing readiness check
+	time.Sleep(app.shutdownDelay)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), app.shutdownTimeout)
+	defer cancel()
+
+	var shutdownErr error
+	for _, srv := range servers {
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			srv.Close()
+			shutdownErr = errors.Join(shutdownErr, err)
+		}
+	}
+	app.lifecycle.set(stateStopped)
+	if shutdownErr != nil {
+		return shutdownErr
+	}
+
+	for range servers {
+		if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+	}
 
-	return s.ListenAndServe()
+	slog.Info("Server stopped")
+	return nil
 }
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/Aergiaaa/rollet/internal/live"
+	"github.com/gin-gonic/gin"
+)
+
+// freePort returns a port nothing listens on right now.
+func freePort(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+// waitFor polls cond until it holds or a second has passed.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestServeDrainsOnSignal(t *testing.T) {
+	tests := []struct {
+		name            string
+		shutdownTimeout time.Duration
+		// hold is how long the in-flight request takes once shutdown
+		// begins.
+		hold       time.Duration
+		wantErr    error
+		wantStatus int
+	}{
+		{name: "finishes in-flight requests", shutdownTimeout: 5 * time.Second, hold: 50 * time.Millisecond, wantStatus: http.StatusOK},
+		{name: "gives up after the timeout", shutdownTimeout: 50 * time.Millisecond, hold: time.Second, wantErr: context.DeadlineExceeded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApp()
+			app.host = "127.0.0.1"
+			app.port = freePort(t)
+			app.shutdownTimeout = tt.shutdownTimeout
+			app.shutdownDelay = 200 * time.Millisecond
+			app.live = live.NewHub(time.Minute, 1)
+
+			started := make(chan struct{})
+			release := make(chan struct{})
+			r := gin.New()
+			r.GET("/readyz", app.readyz)
+			r.GET("/slow", func(c *gin.Context) {
+				close(started)
+				select {
+				case <-release:
+				case <-time.After(tt.hold + time.Second):
+				}
+				c.String(http.StatusOK, "done")
+			})
+
+			served := make(chan error, 1)
+			go func() { served <- app.serve(r) }()
+			waitFor(t, "the server to be ready", app.lifecycle.ready)
+
+			base := "http://" + net.JoinHostPort(app.host, strconv.Itoa(app.port))
+			slow := make(chan *http.Response, 1)
+			go func() {
+				res, err := http.Get(base + "/slow")
+				if err != nil {
+					slow <- nil
+					return
+				}
+				slow <- res
+			}()
+			<-started
+
+			self, _ := os.FindProcess(os.Getpid())
+			if err := self.Signal(syscall.SIGTERM); err != nil {
+				t.Fatal(err)
+			}
+			waitFor(t, "draining", func() bool { return app.lifecycle.String() == "draining" })
+
+			// Load balancers still reach the server during the delay and
+			// must see it is no longer ready.
+			res, err := http.Get(base + "/readyz")
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+			if res.StatusCode != http.StatusServiceUnavailable {
+				t.Errorf("readyz while draining = %d; want %d", res.StatusCode, http.StatusServiceUnavailable)
+			}
+
+			time.AfterFunc(app.shutdownDelay+tt.hold, func() { close(release) })
+
+			err = <-served
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("serve = %v; want %v", err, tt.wantErr)
+			}
+			if app.lifecycle.alive() {
+				t.Errorf("lifecycle = %s after serve returned; want stopped", &app.lifecycle)
+			}
+
+			got := <-slow
+			if tt.wantStatus == 0 {
+				if got != nil {
+					got.Body.Close()
+				}
+				return
+			}
+			if got == nil {
+				t.Fatal("the in-flight request failed")
+			}
+			defer got.Body.Close()
+			body, _ := io.ReadAll(got.Body)
+			if got.StatusCode != tt.wantStatus || string(body) != "done" {
+				t.Errorf("in-flight request = %d %q; want %d %q", got.StatusCode, body, tt.wantStatus, "done")
+			}
+		})
+	}
+}
+
+func TestServeFailsOnBusyPort(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	app := newTestApp()
+	app.host = "127.0.0.1"
+	app.port = ln.Addr().(*net.TCPAddr).Port
+	app.live = live.NewHub(time.Minute, 1)
+
+	if err := app.serve(http.NotFoundHandler()); err == nil {
+		t.Fatal("serve on a busy port succeeded")
+	}
+	if app.lifecycle.ready() {
+		t.Error("server is ready after failing to listen")
+	}
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Aergiaaa/rollet/internal/database"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// sharePasswordHeader carries the password of a protected share link, so
+// it stays out of URLs and access logs.
+const sharePasswordHeader = "X-Share-Password"
+
+// shareSlugBytes is the randomness in a slug: 128 bits, 22 characters.
+const shareSlugBytes = 16
+
+type shareRequest struct {
+	// ExpiresIn is the lifetime of the link in seconds; zero never expires.
+	ExpiresIn int    `json:"expires_in" binding:"omitempty,min=60,max=31536000"`
+	Password  string `json:"password" binding:"omitempty,min=4,max=72"`
+}
+
+type shareResponse struct {
+	Slug              string     `json:"slug"`
+	Path              string     `json:"path"`
+	DrawID            int        `json:"draw_id"`
+	PasswordProtected bool       `json:"password_protected"`
+	ExpiresAt         *time.Time `json:"expires_at,omitempty"`
+	RevokedAt         *time.Time `json:"revoked_at,omitempty"`
+	Expired           bool       `json:"expired"`
+	CreatedAt         time.Time  `json:"created_at"`
+}
+
+type shareListResponse struct {
+	Shares []shareResponse `json:"shares"`
+}
+
+// createShare godoc
+// @Summary      Share a saved draw
+// @Description  Creates a public link to one of the user's personal draws; draws saved to an organisation are shared through /v1/orgs/{org}/draws/{id}/share. The link can expire and can require a password, sent by viewers in the X-Share-Password header.
+// @Tags         share
+// @Accept       json
+// @Produce      json
+// @Param        id    path      int           true   "Draw ID"
+// @Param        body  body      shareRequest  false  "Share options"
+// @Success      201   {object}  shareResponse
+// @Failure      400   {object}  problemDetails
+// @Failure      401   {object}  problemDetails
+// @Failure      404   {object}  problemDetails
+// @Failure      422   {object}  problemDetails
+// @Failure      500   {object}  problemDetails
+// @Router       /v1/user/draws/{id}/share [post]
+func (app *app) createShare(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists || user == nil {
+		problem(c, http.StatusUnauthorized, codeUnauthorized, "Authentication is required")
+		return
+	}
+	userObj := user.(*database.User)
+
+	drawID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || drawID < 1 {
+		problem(c, http.StatusNotFound, codeNotFound, "Draw not found")
+		return
+	}
+
+	var req shareRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			bindProblem(c, err)
+			return
+		}
+	}
+
+	slug, err := newShareSlug()
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to generate share slug", "error", err)
+		problem(c, http.StatusInternalServerError, codeInternal, "Failed to create share link")
+		return
+	}
+
+	share := database.Share{Slug: slug, DrawId: drawID, UserId: userObj.Id}
+	if req.Password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "failed to hash password", "error", err)
+			problem(c, http.StatusInternalServerError, codeInternal, "Failed to hash password")
+			return
+		}
+		share.Password = string(hash)
+	}
+
+	var orgId *int
+	if membership, ok := c.Get("membership"); ok {
+		orgId = &membership.(*database.Membership).OrgId
+	}
+
+	err = app.models.Shares.Insert(c.Request.Context(), &share, orgId, time.Duration(req.ExpiresIn)*time.Second)
+	if errors.Is(err, sql.ErrNoRows) {
+		problem(c, http.StatusNotFound, codeNotFound, "Draw not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to create share link", "error", err)
+		problem(c, http.StatusInternalServerError, codeInternal, "Failed to create share link")
+		return
+	}
+
+	c.JSON(http.StatusCreated, newShareResponse(&share))
+}
+
+// listShares godoc
+// @Summary      List share links
+// @Description  Returns the user's share links, newest first, including revoked and expired ones
+// @Tags         share
+// @Produce      json
+// @Success      200   {object}  shareListResponse
+// @Failure      401   {object}  problemDetails
+// @Failure      500   {object}  problemDetails
+// @Router       /v1/user/shares [get]
+func (app *app) listShares(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists || user == nil {
+		problem(c, http.StatusUnauthorized, codeUnauthorized, "Authentication is required")
+		return
+	}
+	userObj := user.(*database.User)
+
+	shares, err := app.models.Shares.ListByUserId(c.Request.Context(), userObj.Id)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to list share links", "error", err)
+		problem(c, http.StatusInternalServerError, codeInternal, "Failed to retrieve data")
+		return
+	}
+
+	res := shareListResponse{Shares: make([]shareResponse, len(shares))}
+	for i, s := range shares {
+		res.Shares[i] = newShareResponse(s)
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+// revokeShare godoc
+// @Summary      Revoke a share link
+// @Description  Disables one of the user's share links; viewers get 404 afterwards
+// @Tags         share
+// @Param        slug  path  string  true  "Share slug"
+// @Success      204
+// @Failure      401   {object}  problemDetails
+// @Failure      404   {object}  problemDetails
+// @Failure      500   {object}  problemDetails
+// @Router       /v1/user/shares/{slug} [delete]
+func (app *app) revokeShare(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists || user == nil {
+		problem(c, http.StatusUnauthorized, codeUnauthorized, "Authentication is required")
+		return
+	}
+	userObj := user.(*database.User)
+
+	err := app.models.Shares.Revoke(c.Request.Context(), userObj.Id, c.Param("slug"))
+	if errors.Is(err, sql.ErrNoRows) {
+		problem(c, http.StatusNotFound, codeNotFound, "Share link not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to revoke share link", "error", err)
+		problem(c, http.StatusInternalServerError, codeInternal, "Failed to revoke share link")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// getShare godoc
+// @Summary      View a shared draw
+// @Description  Returns the teams of a shared draw without authentication. Password protected links need the password in the X-Share-Password header.
+// @Tags         share
+// @Produce      json,text/csv,application/vnd.openxmlformats-officedocument.spreadsheetml.sheet,text/markdown,application/pdf
+// @Param        slug              path    string  true   "Share slug"
+// @Param        X-Share-Password  header  string  false  "Password of a protected link"
+// @Param        format            query   string  false  "Response format, overriding the Accept header"  Enums(json, csv, xlsx, md, pdf)
+// @Success      200   {object}  RandomizeResponse
+// @Failure      401   {object}  problemDetails
+// @Failure      404   {object}  problemDetails
+// @Failure      406   {object}  problemDetails
+// @Failure      410   {object}  problemDetails
+// @Failure      500   {object}  problemDetails
+// @Router       /v1/share/{slug} [get]
+func (app *app) getShare(c *gin.Context) {
+	format, ok := exportFormat(c)
+	if !ok {
+		return
+	}
+
+	slug := c.Param("slug")
+	if len(slug) > 32 {
+		problem(c, http.StatusNotFound, codeNotFound, "Share link not found")
+		return
+	}
+
+	share, err := app.models.Shares.GetBySlug(c.Request.Context(), slug)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to retrieve share link", "error", err)
+		problem(c, http.StatusInternalServerError, codeInternal, "Failed to retrieve data")
+		return
+	}
+	if share == nil || share.RevokedAt != nil {
+		problem(c, http.StatusNotFound, codeNotFound, "Share link not found")
+		return
+	}
+	if share.Expired {
+		problem(c, http.StatusGone, codeShareExpired, "This share link has expired")
+		return
+	}
+
+	if share.Password != "" {
+		c.Header("Cache-Control", "no-store")
+		password := c.GetHeader(sharePasswordHeader)
+		if password == "" {
+			problem(c, http.StatusUnauthorized, codePasswordRequired, "This share link needs a password in the "+sharePasswordHeader+" header")
+			return
+		}
+		if bcrypt.CompareHashAndPassword([]byte(share.Password), []byte(password)) != nil {
+			problem(c, http.StatusUnauthorized, codeInvalidCredentials, "Invalid share password")
+			return
+		}
+	}
+
+	draw, err := app.models.People.GetDraw(c.Request.Context(), share.DrawId)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to retrieve shared draw", "error", err)
+		problem(c, http.StatusInternalServerError, codeInternal, "Failed to retrieve data")
+		return
+	}
+	if draw == nil {
+		problem(c, http.StatusNotFound, codeNotFound, "Share link not found")
+		return
+	}
+
+	if format != nil {
+		writeExport(c, *format, drawFilename(draw), []*database.Draw{draw})
+		return
+	}
+	c.JSON(http.StatusOK, drawResponse(draw))
+}
+
+// newShareSlug returns a random URL-safe slug.
+func newShareSlug() (string, error) {
+	b := make([]byte, shareSlugBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func newShareResponse(s *database.Share) shareResponse {
+	return shareResponse{
+		Slug:              s.Slug,
+		Path:              "/v1/share/" + s.Slug,
+		DrawID:            s.DrawId,
+		PasswordProtected: s.Password != "",
+		ExpiresAt:         s.ExpiresAt,
+		RevokedAt:         s.RevokedAt,
+		Expired:           s.Expired,
+		CreatedAt:         s.CreatedAt,
+	}
+}
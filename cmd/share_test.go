@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Aergiaaa/rollet/internal/database"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// stubShares keeps links in a map keyed by slug and revokes them like the
+// database does.
+type stubShares struct {
+	database.ShareStore
+	shares map[string]*database.Share
+}
+
+func (s *stubShares) GetBySlug(_ context.Context, slug string) (*database.Share, error) {
+	return s.shares[slug], nil
+}
+
+func (s *stubShares) Insert(_ context.Context, share *database.Share, _ *int, ttl time.Duration) error {
+	if ttl > 0 {
+		expires := time.Now().Add(ttl)
+		share.ExpiresAt = &expires
+	}
+	s.shares[share.Slug] = share
+	return nil
+}
+
+func (s *stubShares) Revoke(_ context.Context, userId int, slug string) error {
+	share := s.shares[slug]
+	if share == nil || share.UserId != userId || share.RevokedAt != nil {
+		return sql.ErrNoRows
+	}
+	now := time.Now()
+	share.RevokedAt = &now
+	return nil
+}
+
+// shareApp serves the share routes with a draw 1 of user 1 shared as
+// "open", "expired" and "locked", the last with the password "letmein".
+func shareApp(t *testing.T) (*gin.Engine, *stubShares) {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte("letmein"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shares := &stubShares{shares: map[string]*database.Share{
+		"open":    {Slug: "open", DrawId: 1, UserId: 1},
+		"expired": {Slug: "expired", DrawId: 1, UserId: 1, Expired: true},
+		"locked":  {Slug: "locked", DrawId: 1, UserId: 1, Password: string(hash)},
+	}}
+	people := &stubPeople{draws: map[int]*database.Draw{1: {
+		Id:        1,
+		TeamCount: 1,
+		Source:    "deterministic",
+		People:    []*database.People{{Name: "Ada", Role: "dev", Team: 1}},
+	}}}
+
+	a := newTestApp()
+	a.models = database.Models{Shares: shares, People: people}
+
+	users := map[string]*database.User{"1": {Id: 1}, "2": {Id: 2}}
+	asUser := func(c *gin.Context) {
+		if u, ok := users[c.GetHeader("X-User")]; ok {
+			c.Set("user", u)
+		}
+		c.Next()
+	}
+
+	r := gin.New()
+	r.GET("/v1/share/:slug", a.getShare)
+	r.POST("/v1/user/draws/:id/share", asUser, a.createShare)
+	r.DELETE("/v1/user/shares/:slug", asUser, a.revokeShare)
+	return r, shares
+}
+
+// record sends req through h and returns the recorded response.
+func record(h http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestGetShare(t *testing.T) {
+	r, _ := shareApp(t)
+
+	tests := []struct {
+		name       string
+		slug       string
+		password   string
+		wantStatus int
+		wantCode   string
+	}{
+		{name: "open", slug: "open", wantStatus: http.StatusOK},
+		{name: "unknown", slug: "nope", wantStatus: http.StatusNotFound, wantCode: codeNotFound},
+		{name: "slug too long", slug: strings.Repeat("a", 33), wantStatus: http.StatusNotFound, wantCode: codeNotFound},
+		{name: "expired", slug: "expired", wantStatus: http.StatusGone, wantCode: codeShareExpired},
+		{name: "no password", slug: "locked", wantStatus: http.StatusUnauthorized, wantCode: codePasswordRequired},
+		{name: "wrong password", slug: "locked", password: "letmeout", wantStatus: http.StatusUnauthorized, wantCode: codeInvalidCredentials},
+		{name: "password", slug: "locked", password: "letmein", wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/v1/share/"+tt.slug, nil)
+			if tt.password != "" {
+				req.Header.Set(sharePasswordHeader, tt.password)
+			}
+			w := record(r, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d; want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.slug == "locked" && w.Header().Get("Cache-Control") != "no-store" {
+				t.Errorf("Cache-Control = %q; want no-store", w.Header().Get("Cache-Control"))
+			}
+			if tt.wantCode == "" {
+				if !strings.Contains(w.Body.String(), `"Ada"`) {
+					t.Errorf("body = %s; want the shared draw", w.Body)
+				}
+				return
+			}
+			if p := decodeProblem(t, w); p.Code != tt.wantCode {
+				t.Errorf("code = %q; want %q", p.Code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestCreateShare(t *testing.T) {
+	r, shares := shareApp(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/user/draws/1/share", strings.NewReader(`{"expires_in":3600,"password":"letmein"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User", "1")
+	w := record(r, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d; want 201: %s", w.Code, w.Body)
+	}
+
+	var created *database.Share
+	for slug, s := range shares.shares {
+		if len(slug) == 22 {
+			created = s
+		}
+	}
+	if created == nil {
+		t.Fatal("no share stored with a generated slug")
+	}
+	if created.ExpiresAt == nil || time.Until(*created.ExpiresAt) < 59*time.Minute {
+		t.Errorf("expires at %v; want in an hour", created.ExpiresAt)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(created.Password), []byte("letmein")) != nil {
+		t.Errorf("stored password %q is not a hash of the password", created.Password)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/v1/user/draws/1/share", strings.NewReader(`{"expires_in":30}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User", "1")
+	if w := record(r, req); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("30 second lifetime: status = %d; want 422", w.Code)
+	}
+}
+
+func TestRevokeShare(t *testing.T) {
+	r, _ := shareApp(t)
+
+	revoke := func(user string) int {
+		req := httptest.NewRequest(http.MethodDelete, "/v1/user/shares/open", nil)
+		if user != "" {
+			req.Header.Set("X-User", user)
+		}
+		return record(r, req).Code
+	}
+	view := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/v1/share/open", nil)
+		return record(r, req).Code
+	}
+
+	if got := revoke(""); got != http.StatusUnauthorized {
+		t.Errorf("anonymous revoke = %d; want 401", got)
+	}
+	if got := revoke("2"); got != http.StatusNotFound {
+		t.Errorf("revoke by another user = %d; want 404", got)
+	}
+	if got := view(); got != http.StatusOK {
+		t.Fatalf("view before revoking = %d; want 200", got)
+	}
+	if got := revoke("1"); got != http.StatusNoContent {
+		t.Fatalf("revoke by the owner = %d; want 204", got)
+	}
+	if got := view(); got != http.StatusNotFound {
+		t.Errorf("view after revoking = %d; want 404", got)
+	}
+	if got := revoke("1"); got != http.StatusNotFound {
+		t.Errorf("second revoke = %d; want 404", got)
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tlsOptions configures native TLS. TLS is enabled when certFile is set.
+type tlsOptions struct {
+	certFile       string
+	keyFile        string
+	clientCAFile   string
+	clientAuth     string
+	redirectPort   int
+	reloadInterval time.Duration
+}
+
+func (o tlsOptions) enabled() bool {
+	return o.certFile != ""
+}
+
+// clientAuthTypes maps TLS_CLIENT_AUTH values to the crypto/tls policy.
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"none":    tls.NoClientCert,
+	"request": tls.RequestClientCert,
+	"verify":  tls.VerifyClientCertIfGiven,
+	"require": tls.RequireAndVerifyClientCert,
+}
+
+// certReloader hands out the certificate loaded from certFile and keyFile
+// and reloads it when either file changes, so renewed certificates are
+// picked up without a restart.
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// latestModTime returns the newer modification time of the two files.
+func (r *certReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, path := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+func (r *certReloader) reload() error {
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return fmt.Errorf("could not stat certificate: %w", err)
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("could not load certificate: %w", err)
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.modTime = modTime
+	r.mu.Unlock()
+	return nil
+}
+
+// watch polls the certificate files every interval until ctx is done. A
+// certificate that fails to load is logged and the previous one kept.
+func (r *certReloader) watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		modTime, err := r.latestModTime()
+		if err != nil {
+			slog.Warn("TLS certificate check failed", "error", err)
+			continue
+		}
+
+		r.mu.RLock()
+		changed := modTime.After(r.modTime)
+		r.mu.RUnlock()
+		if !changed {
+			continue
+		}
+
+		if err := r.reload(); err != nil {
+			slog.Error("TLS certificate reload failed, keeping the current one", "error", err)
+			continue
+		}
+		slog.Info("TLS certificate reloaded")
+	}
+}
+
+// tlsConfig builds the server TLS configuration, advertising HTTP/2, and
+// starts watching the certificate for changes until ctx is done.
+func (o tlsOptions) tlsConfig(ctx context.Context) (*tls.Config, error) {
+	reloader, err := newCertReloader(o.certFile, o.keyFile)
+	if err != nil {
+		return nil, err
+	}
+	go reloader.watch(ctx, o.reloadInterval)
+
+	cfg := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		NextProtos:     []string{"h2", "http/1.1"},
+		GetCertificate: reloader.GetCertificate,
+		ClientAuth:     clientAuthTypes[o.clientAuth],
+	}
+
+	if o.clientCAFile != "" {
+		pem, err := os.ReadFile(o.clientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read client CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("client CA file contains no certificates")
+		}
+		cfg.ClientCAs = pool
+	}
+
+	return cfg, nil
+}
+
+// redirectHandler sends plain HTTP requests to the same host and path on
+// the HTTPS port.
+func redirectHandler(httpsPort int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = strings.TrimSuffix(strings.TrimPrefix(r.Host, "["), "]")
+		}
+		if httpsPort != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(httpsPort))
+		} else if strings.Contains(host, ":") {
+			// IPv6 literals keep their brackets in URLs
+			host = "[" + host + "]"
+		}
+
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeCert writes a self-signed certificate for name and its key to the
+// files, dated modTime so reloads can be told apart.
+func writeCert(t *testing.T, certFile, keyFile, name string, modTime time.Time) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	for path, data := range map[string][]byte{certFile: certPEM, keyFile: keyPEM} {
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// servedName returns the common name of the certificate r hands out.
+func servedName(t *testing.T, r *certReloader) string {
+	t.Helper()
+	cert, err := r.GetCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestCertReloaderPicksUpNewCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	start := time.Now().Add(-time.Minute)
+	writeCert(t, certFile, keyFile, "old.example.com", start)
+
+	r, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := servedName(t, r); got != "old.example.com" {
+		t.Fatalf("serving %s; want old.example.com", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.watch(ctx, 5*time.Millisecond)
+
+	// A broken renewal keeps the current certificate
+	if err := os.WriteFile(certFile, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(certFile, start.Add(time.Second), start.Add(time.Second))
+	time.Sleep(50 * time.Millisecond)
+	if got := servedName(t, r); got != "old.example.com" {
+		t.Fatalf("serving %s after a broken renewal; want old.example.com", got)
+	}
+
+	writeCert(t, certFile, keyFile, "new.example.com", start.Add(2*time.Second))
+	waitFor(t, "the new certificate", func() bool { return servedName(t, r) == "new.example.com" })
+}
+
+func TestNewCertReloaderErrors(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+
+	if _, err := newCertReloader(certFile, keyFile); err == nil || !strings.Contains(err.Error(), "could not stat") {
+		t.Errorf("missing files: err = %v; want a stat error", err)
+	}
+
+	os.WriteFile(certFile, []byte("junk"), 0o600)
+	os.WriteFile(keyFile, []byte("junk"), 0o600)
+	if _, err := newCertReloader(certFile, keyFile); err == nil || !strings.Contains(err.Error(), "could not load") {
+		t.Errorf("junk files: err = %v; want a load error", err)
+	}
+}
+
+func TestRedirectHandler(t *testing.T) {
+	tests := []struct {
+		port   int
+		host   string
+		target string
+		want   string
+	}{
+		{443, "example.com", "/", "https://example.com/"},
+		{443, "example.com:80", "/v1/health?x=1", "https://example.com/v1/health?x=1"},
+		{8443, "example.com", "/a", "https://example.com:8443/a"},
+		{8443, "example.com:8080", "/a?b=c", "https://example.com:8443/a?b=c"},
+		{443, "[::1]:80", "/", "https://[::1]/"},
+		{443, "[::1]", "/", "https://[::1]/"},
+		{8443, "[::1]:80", "/", "https://[::1]:8443/"},
+		{8443, "[::1]", "/", "https://[::1]:8443/"},
+		{8443, "127.0.0.1:80", "/", "https://127.0.0.1:8443/"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, tt.target, nil)
+		req.Host = tt.host
+		w := httptest.NewRecorder()
+		redirectHandler(tt.port).ServeHTTP(w, req)
+
+		if w.Code != http.StatusPermanentRedirect {
+			t.Errorf("%s%s on port %d: status = %d; want %d", tt.host, tt.target, tt.port, w.Code, http.StatusPermanentRedirect)
+		}
+		if got := w.Header().Get("Location"); got != tt.want {
+			t.Errorf("%s%s on port %d: Location = %q; want %q", tt.host, tt.target, tt.port, got, tt.want)
+		}
+	}
+}
+
+func TestValidateTLS(t *testing.T) {
+	valid := func() config {
+		return config{
+			Port:              8080,
+			TLSCertFile:       "cert.pem",
+			TLSKeyFile:        "key.pem",
+			TLSClientAuth:     "none",
+			TLSReloadInterval: time.Minute,
+		}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*config)
+		want   []string
+	}{
+		{"valid", func(*config) {}, nil},
+		{"tls disabled", func(c *config) { c.TLSCertFile, c.TLSKeyFile = "", "" }, nil},
+		{"client certificates verified", func(c *config) { c.TLSClientAuth, c.TLSClientCAFile = "require", "ca.pem" }, nil},
+		{"redirect", func(c *config) { c.TLSRedirectPort = 8081 }, nil},
+		{"cert without key", func(c *config) { c.TLSKeyFile = "" }, []string{"TLS_CERT_FILE and TLS_KEY_FILE must be set together"}},
+		{"key without cert", func(c *config) { c.TLSCertFile = "" }, []string{
+			"TLS_CERT_FILE and TLS_KEY_FILE must be set together",
+		}},
+		{"unknown client auth", func(c *config) { c.TLSClientAuth = "maybe" }, []string{"TLS_CLIENT_AUTH must be one of none, request, verify or require"}},
+		{"tls options without tls", func(c *config) { c.TLSCertFile, c.TLSKeyFile, c.TLSRedirectPort = "", "", 8081 }, []string{
+			"TLS_CLIENT_CA_FILE, TLS_CLIENT_AUTH and TLS_REDIRECT_PORT require TLS_CERT_FILE",
+		}},
+		{"client auth without tls", func(c *config) { c.TLSCertFile, c.TLSKeyFile, c.TLSClientAuth = "", "", "request" }, []string{
+			"TLS_CLIENT_CA_FILE, TLS_CLIENT_AUTH and TLS_REDIRECT_PORT require TLS_CERT_FILE",
+		}},
+		{"verify without a CA", func(c *config) { c.TLSClientAuth = "verify" }, []string{"TLS_CLIENT_AUTH verify and require need TLS_CLIENT_CA_FILE"}},
+		{"redirect to the same port", func(c *config) { c.TLSRedirectPort = 8080 }, []string{"TLS_REDIRECT_PORT must be a free port other than PORT"}},
+		{"redirect port out of range", func(c *config) { c.TLSRedirectPort = 70000 }, []string{"TLS_REDIRECT_PORT must be a free port other than PORT"}},
+		{"no reload interval", func(c *config) { c.TLSReloadInterval = 0 }, []string{"TLS_RELOAD_INTERVAL must be positive"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid()
+			tt.modify(&cfg)
+
+			var got []string
+			for _, err := range cfg.validateTLS() {
+				got = append(got, err.Error())
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("validateTLS = %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Aergiaaa/rollet/internal/database"
+	"github.com/Aergiaaa/rollet/internal/webhook"
+	"github.com/gin-gonic/gin"
+)
+
+// deliveryLogLimit is how many deliveries the delivery log returns.
+const deliveryLogLimit = 50
+
+type webhookRequest struct {
+	URL string `json:"url" binding:"required,max=2048"`
+	// Secret signs the payloads; one is generated when empty.
+	Secret string   `json:"secret" binding:"omitempty,min=16,max=255"`
+	Events []string `json:"events" binding:"required,min=1"`
+}
+
+type webhookResponse struct {
+	database.Webhook
+	// Secret is only returned when the webhook is created.
+	Secret string `json:"secret,omitempty"`
+}
+
+type webhookListResponse struct {
+	Webhooks []*database.Webhook `json:"webhooks"`
+}
+
+type deliveryListResponse struct {
+	Deliveries []*database.WebhookDelivery `json:"deliveries"`
+}
+
+// webhookPayload is the body posted to subscribers.
+type webhookPayload struct {
+	Event     string            `json:"event"`
+	CreatedAt time.Time         `json:"created_at"`
+	Data      RandomizeResponse `json:"data"`
+}
+
+// createWebhook godoc
+// @Summary      Subscribe to events
+// @Description  Registers a URL to receive events, such as draw.saved whenever one of the user's personal draws is saved; draws saved to an organisation are not sent. Each delivery is a POST signed in the Rollet-Signature header as t=<unix time>,v1=<hex HMAC-SHA256 of "<time>.<body>" under the secret>. Failed deliveries are retried with exponential backoff.
+// @Tags         webhooks
+// @Accept       json
+// @Produce      json
+// @Param        body  body      webhookRequest  true  "Subscription"
+// @Success      201   {object}  webhookResponse
+// @Failure      400   {object}  problemDetails
+// @Failure      401   {object}  problemDetails
+// @Failure      422   {object}  problemDetails
+// @Failure      500   {object}  problemDetails
+// @Router       /v1/user/webhooks [post]
+func (app *app) createWebhook(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists || user == nil {
+		problem(c, http.StatusUnauthorized, codeUnauthorized, "Authentication is required")
+		return
+	}
+	userObj := user.(*database.User)
+
+	var req webhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindProblem(c, err)
+		return
+	}
+
+	var errs []fieldError
+	if err := webhook.CheckURL(req.URL); err != nil {
+		errs = append(errs, fieldError{Field: "url", Code: "url", Message: err.Error()})
+	}
+	for i, e := range req.Events {
+		if !slices.Contains(webhook.Events, e) {
+			errs = append(errs, fieldError{
+				Field:   fmt.Sprintf("events[%d]", i),
+				Code:    "oneof",
+				Message: "must be one of " + strings.Join(webhook.Events, ", "),
+			})
+		}
+	}
+	if len(errs) > 0 {
+		problem(c, http.StatusUnprocessableEntity, codeValidationFailed, "The request has invalid fields", errs...)
+		return
+	}
+
+	if req.Secret == "" {
+		secret, err := newWebhookSecret()
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "failed to generate webhook secret", "error", err)
+			problem(c, http.StatusInternalServerError, codeInternal, "Failed to create webhook")
+			return
+		}
+		req.Secret = secret
+	}
+
+	slices.Sort(req.Events)
+	w := database.Webhook{
+		UserId: userObj.Id,
+		URL:    req.URL,
+		Secret: req.Secret,
+		Events: slices.Compact(req.Events),
+	}
+	if err := app.models.Webhooks.Insert(c.Request.Context(), &w); err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to create webhook", "error", err)
+		problem(c, http.StatusInternalServerError, codeInternal, "Failed to create webhook")
+		return
+	}
+
+	c.JSON(http.StatusCreated, webhookResponse{Webhook: w, Secret: w.Secret})
+}
+
+// listWebhooks godoc
+// @Summary      List webhooks
+// @Description  Returns the user's webhook subscriptions, without their secrets
+// @Tags         webhooks
+// @Produce      json
+// @Success      200   {object}  webhookListResponse
+// @Failure      401   {object}  problemDetails
+// @Failure      500   {object}  problemDetails
+// @Router       /v1/user/webhooks [get]
+func (app *app) listWebhooks(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists || user == nil {
+		problem(c, http.StatusUnauthorized, codeUnauthorized, "Authentication is required")
+		return
+	}
+	userObj := user.(*database.User)
+
+	webhooks, err := app.models.Webhooks.ListByUserId(c.Request.Context(), userObj.Id)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to list webhooks", "error", err)
+		problem(c, http.StatusInternalServerError, codeInternal, "Failed to retrieve data")
+		return
+	}
+
+	c.JSON(http.StatusOK, webhookListResponse{Webhooks: webhooks})
+}
+
+// deleteWebhook godoc
+// @Summary      Delete a webhook
+// @Description  Removes a subscription together with its delivery log
+// @Tags         webhooks
+// @Param        id   path  int  true  "Webhook ID"
+// @Success      204
+// @Failure      401  {object}  problemDetails
+// @Failure      404  {object}  problemDetails
+// @Failure      500  {object}  problemDetails
+// @Router       /v1/user/webhooks/{id} [delete]
+func (app *app) deleteWebhook(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists || user == nil {
+		problem(c, http.StatusUnauthorized, codeUnauthorized, "Authentication is required")
+		return
+	}
+	userObj := user.(*database.User)
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		problem(c, http.StatusNotFound, codeNotFound, "Webhook not found")
+		return
+	}
+
+	err = app.models.Webhooks.Delete(c.Request.Context(), userObj.Id, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		problem(c, http.StatusNotFound, codeNotFound, "Webhook not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to delete webhook", "error", err)
+		problem(c, http.StatusInternalServerError, codeInternal, "Failed to delete webhook")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// listDeliveries godoc
+// @Summary      Webhook delivery log
+// @Description  Returns the latest deliveries of a webhook, newest first, with their payloads and the outcome of the last attempt
+// @Tags         webhooks
+// @Produce      json
+// @Param        id   path      int  true  "Webhook ID"
+// @Success      200  {object}  deliveryListResponse
+// @Failure      401  {object}  problemDetails
+// @Failure      404  {object}  problemDetails
+// @Failure      500  {object}  problemDetails
+// @Router       /v1/user/webhooks/{id}/deliveries [get]
+func (app *app) listDeliveries(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists || user == nil {
+		problem(c, http.StatusUnauthorized, codeUnauthorized, "Authentication is required")
+		return
+	}
+	userObj := user.(*database.User)
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		problem(c, http.StatusNotFound, codeNotFound, "Webhook not found")
+		return
+	}
+
+	deliveries, err := app.models.Webhooks.ListDeliveries(c.Request.Context(), userObj.Id, id, deliveryLogLimit)
+	if errors.Is(err, sql.ErrNoRows) {
+		problem(c, http.StatusNotFound, codeNotFound, "Webhook not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to list webhook deliveries", "error", err)
+		problem(c, http.StatusInternalServerError, codeInternal, "Failed to retrieve data")
+		return
+	}
+
+	c.JSON(http.StatusOK, deliveryListResponse{Deliveries: deliveries})
+}
+
+// redeliverWebhook godoc
+// @Summary      Redeliver a payload
+// @Description  Queues a new delivery with the payload of an earlier one; both stay in the log
+// @Tags         webhooks
+// @Produce      json
+// @Param        id        path      int  true  "Webhook ID"
+// @Param        delivery  path      int  true  "Delivery ID"
+// @Success      202       {object}  database.WebhookDelivery
+// @Failure      401       {object}  problemDetails
+// @Failure      404       {object}  problemDetails
+// @Failure      500       {object}  problemDetails
+// @Router       /v1/user/webhooks/{id}/deliveries/{delivery}/redeliver [post]
+func (app *app) redeliverWebhook(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists || user == nil {
+		problem(c, http.StatusUnauthorized, codeUnauthorized, "Authentication is required")
+		return
+	}
+	userObj := user.(*database.User)
+
+	id, err := strconv.Atoi(c.Param("id"))
+	deliveryID, err2 := strconv.Atoi(c.Param("delivery"))
+	if err != nil || err2 != nil {
+		problem(c, http.StatusNotFound, codeNotFound, "Delivery not found")
+		return
+	}
+
+	d, err := app.models.Webhooks.Redeliver(c.Request.Context(), userObj.Id, id, deliveryID)
+	if errors.Is(err, sql.ErrNoRows) {
+		problem(c, http.StatusNotFound, codeNotFound, "Delivery not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to redeliver webhook", "error", err)
+		problem(c, http.StatusInternalServerError, codeInternal, "Failed to redeliver webhook")
+		return
+	}
+	app.webhooks.Notify()
+
+	c.JSON(http.StatusAccepted, d)
+}
+
+// queueWebhooks queues the draw.saved event for the user's webhooks. They
+// are personal, so it is only called for personal draws; a draw saved to an
+// organisation would otherwise reach whoever its member subscribed. A
+// failure is logged but does not fail the draw, which is already saved.
+func (app *app) queueWebhooks(ctx context.Context, userID int, draw *database.Draw) {
+	payload, err := json.Marshal(webhookPayload{
+		Event:     webhook.EventDrawSaved,
+		CreatedAt: draw.CreatedAt,
+		Data:      drawResponse(draw),
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to encode webhook payload", "error", err)
+		return
+	}
+
+	n, err := app.models.Webhooks.Enqueue(ctx, userID, webhook.EventDrawSaved, payload)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to queue webhooks", "draw_id", draw.Id, "error", err)
+		return
+	}
+	if n > 0 {
+		app.webhooks.Notify()
+	}
+}
+
+// newWebhookSecret returns a random signing secret.
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + base64.RawURLEncoding.EncodeToString(b), nil
+}
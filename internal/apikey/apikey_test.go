@@ -0,0 +1,56 @@
+package apikey
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	key, display, hash, err := Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !Looks(key) {
+		t.Errorf("Looks(%q) = false", key)
+	}
+	if !strings.HasPrefix(key, display) || len(display) != displayLength {
+		t.Errorf("display %q is not the start of %q", display, key)
+	}
+	if hash != Hash(key) || len(hash) != 64 {
+		t.Errorf("hash = %q; want Hash(key)", hash)
+	}
+
+	other, _, _, _ := Generate()
+	if other == key {
+		t.Error("Generate returned the same key twice")
+	}
+}
+
+func TestLooks(t *testing.T) {
+	for _, s := range []string{"", "rk_", "rk_short", "sk_0123456789abcdef", "rk_" + strings.Repeat("a", 200)} {
+		if Looks(s) {
+			t.Errorf("Looks(%q) = true", s)
+		}
+	}
+}
+
+func TestAllows(t *testing.T) {
+	tests := []struct {
+		granted []string
+		scope   string
+		want    bool
+	}{
+		{[]string{DrawsRead}, DrawsRead, true},
+		{[]string{DrawsRead}, DrawsWrite, false},
+		{[]string{DrawsWrite}, DrawsRead, true},
+		{[]string{DrawsWrite}, WebhooksRead, false},
+		{[]string{WebhooksWrite, DrawsRead}, WebhooksRead, true},
+		{nil, DrawsRead, false},
+	}
+	for _, tt := range tests {
+		if got := Allows(tt.granted, tt.scope); got != tt.want {
+			t.Errorf("Allows(%v, %s) = %v; want %v", tt.granted, tt.scope, got, tt.want)
+		}
+	}
+}
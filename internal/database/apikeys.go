@@ -0,0 +1,193 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type APIKeyStore interface {
+	Insert(ctx context.Context, k *APIKey) error
+	ListByUserId(ctx context.Context, userId int) ([]*APIKey, error)
+	ListByOrgId(ctx context.Context, orgId int) ([]*APIKey, error)
+	Revoke(ctx context.Context, userId, id int) error
+	RevokeForOrg(ctx context.Context, orgId, id int) error
+	GetByHash(ctx context.Context, hash string) (*APIKey, error)
+	Touch(ctx context.Context, id int) error
+}
+
+type APIKeyModel struct {
+	DB *sql.DB
+}
+
+// APIKey lets a machine client act as UserId, who created it. Keys with an
+// OrgId are the organisation's and only work within it. Hash is the
+// SHA-256 of the key, which is never stored.
+type APIKey struct {
+	Id         int        `json:"id"`
+	UserId     int        `json:"created_by"`
+	OrgId      *int       `json:"org_id,omitempty"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Hash       string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+var _ APIKeyStore = (*APIKeyModel)(nil)
+
+func (km *APIKeyModel) Insert(ctx context.Context, k *APIKey) (err error) {
+	ctx, span := startSpan(ctx, "APIKeyModel.Insert")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	query := `INSERT INTO api_keys (user_id, org_id, name, prefix, key_hash, scopes)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at`
+	return km.DB.QueryRowContext(ctx, query, k.UserId, k.OrgId, k.Name, k.Prefix, k.Hash, pq.Array(k.Scopes)).
+		Scan(&k.Id, &k.CreatedAt)
+}
+
+// ListByUserId returns the user's personal keys, newest first, including
+// revoked ones.
+func (km *APIKeyModel) ListByUserId(ctx context.Context, userId int) ([]*APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE user_id = $1 AND org_id IS NULL ORDER BY created_at DESC, id DESC`
+	return km.list(ctx, "APIKeyModel.ListByUserId", query, userId)
+}
+
+// ListByOrgId returns the organisation's keys, newest first, including
+// revoked ones.
+func (km *APIKeyModel) ListByOrgId(ctx context.Context, orgId int) ([]*APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE org_id = $1 ORDER BY created_at DESC, id DESC`
+	return km.list(ctx, "APIKeyModel.ListByOrgId", query, orgId)
+}
+
+// Revoke disables one of the user's personal keys. It returns
+// sql.ErrNoRows if the user has no such key or it is already revoked.
+func (km *APIKeyModel) Revoke(ctx context.Context, userId, id int) (err error) {
+	ctx, span := startSpan(ctx, "APIKeyModel.Revoke")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	query := `UPDATE api_keys SET revoked_at = current_timestamp
+		WHERE id = $1 AND user_id = $2 AND org_id IS NULL AND revoked_at IS NULL`
+	return km.execOne(ctx, query, id, userId)
+}
+
+// RevokeForOrg disables one of the organisation's keys. It returns
+// sql.ErrNoRows if the organisation has no such key or it is already
+// revoked.
+func (km *APIKeyModel) RevokeForOrg(ctx context.Context, orgId, id int) (err error) {
+	ctx, span := startSpan(ctx, "APIKeyModel.RevokeForOrg")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	query := `UPDATE api_keys SET revoked_at = current_timestamp WHERE id = $1 AND org_id = $2 AND revoked_at IS NULL`
+	return km.execOne(ctx, query, id, orgId)
+}
+
+// GetByHash returns the unrevoked key with the given hash, or nil if there
+// is none.
+func (km *APIKeyModel) GetByHash(ctx context.Context, hash string) (_ *APIKey, err error) {
+	ctx, span := startSpan(ctx, "APIKeyModel.GetByHash")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE key_hash = $1 AND revoked_at IS NULL`
+	k, err := scanAPIKey(km.DB.QueryRowContext(ctx, query, hash))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return k, nil
+}
+
+// Touch records that the key was just used. Uses within a minute of the
+// last recorded one are not written, so busy clients cost no extra writes.
+func (km *APIKeyModel) Touch(ctx context.Context, id int) (err error) {
+	ctx, span := startSpan(ctx, "APIKeyModel.Touch")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	query := `UPDATE api_keys SET last_used_at = current_timestamp
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < current_timestamp - interval '1 minute')`
+	_, err = km.DB.ExecContext(ctx, query, id)
+	return err
+}
+
+func (km *APIKeyModel) list(ctx context.Context, spanName, query string, args ...any) (_ []*APIKey, err error) {
+	ctx, span := startSpan(ctx, spanName)
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	rows, err := km.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []*APIKey{}
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// execOne runs a statement that must affect exactly one row and reports
+// sql.ErrNoRows otherwise.
+func (km *APIKeyModel) execOne(ctx context.Context, query string, args ...any) error {
+	res, err := km.DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+const apiKeyColumns = `id, user_id, org_id, name, prefix, key_hash, scopes, last_used_at, revoked_at, created_at`
+
+func scanAPIKey(row interface{ Scan(...any) error }) (*APIKey, error) {
+	var k APIKey
+	err := row.Scan(&k.Id, &k.UserId, &k.OrgId, &k.Name, &k.Prefix, &k.Hash, pq.Array(&k.Scopes),
+		&k.LastUsedAt, &k.RevokedAt, &k.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
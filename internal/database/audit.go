@@ -0,0 +1,131 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// Audit outcomes.
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+type AuditStore interface {
+	Insert(ctx context.Context, e *AuditEntry) error
+	List(ctx context.Context, f AuditFilter) ([]*AuditEntry, int, error)
+}
+
+type AuditModel struct {
+	DB *sql.DB
+}
+
+// AuditEntry is one event of the audit log. ActorId is the user who acted,
+// if known; for failed logins ActorEmail holds the email that was tried.
+type AuditEntry struct {
+	Id         int64           `json:"id"`
+	Action     string          `json:"action"`
+	Outcome    string          `json:"outcome"`
+	ActorId    *int            `json:"actor_id,omitempty"`
+	ActorEmail string          `json:"actor_email,omitempty"`
+	APIKeyId   *int            `json:"api_key_id,omitempty"`
+	OrgId      *int            `json:"org_id,omitempty"`
+	TargetType string          `json:"target_type,omitempty"`
+	TargetId   string          `json:"target_id,omitempty"`
+	IP         string          `json:"ip,omitempty"`
+	UserAgent  string          `json:"user_agent,omitempty"`
+	RequestId  string          `json:"request_id,omitempty"`
+	Details    json.RawMessage `json:"details,omitempty" swaggertype:"object"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// AuditFilter selects entries for List. Zero fields match every entry.
+type AuditFilter struct {
+	Action  string
+	Outcome string
+	ActorId int
+	OrgId   int
+	Since   *time.Time
+	Until   *time.Time
+	Limit   int
+	Offset  int
+}
+
+var _ AuditStore = (*AuditModel)(nil)
+
+func (am *AuditModel) Insert(ctx context.Context, e *AuditEntry) (err error) {
+	ctx, span := startSpan(ctx, "AuditModel.Insert")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var details any
+	if len(e.Details) > 0 {
+		details = string(e.Details)
+	}
+
+	query := `INSERT INTO audit_log (action, outcome, actor_id, actor_email, api_key_id, org_id, target_type, target_id,
+			ip, user_agent, request_id, details)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, NULLIF($7, ''), NULLIF($8, ''),
+			NULLIF($9, ''), NULLIF($10, ''), NULLIF($11, ''), $12)
+		RETURNING id, created_at`
+	return am.DB.QueryRowContext(ctx, query, e.Action, e.Outcome, e.ActorId, e.ActorEmail, e.APIKeyId, e.OrgId,
+		e.TargetType, e.TargetId, e.IP, e.UserAgent, e.RequestId, details).Scan(&e.Id, &e.CreatedAt)
+}
+
+// List returns a page of the entries matching f, newest first, together
+// with how many entries match in total.
+func (am *AuditModel) List(ctx context.Context, f AuditFilter) (_ []*AuditEntry, _ int, err error) {
+	ctx, span := startSpan(ctx, "AuditModel.List")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	where := `WHERE ($1::text = '' OR action = $1::text)
+		AND ($2::text = '' OR outcome = $2::text)
+		AND ($3::integer = 0 OR actor_id = $3::integer)
+		AND ($4::integer = 0 OR org_id = $4::integer)
+		AND ($5::timestamptz IS NULL OR created_at >= $5::timestamptz)
+		AND ($6::timestamptz IS NULL OR created_at < $6::timestamptz)`
+	args := []any{f.Action, f.Outcome, f.ActorId, f.OrgId, f.Since, f.Until}
+
+	var total int
+	if err = am.DB.QueryRowContext(ctx, `SELECT count(*) FROM audit_log `+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT id, action, outcome, actor_id, COALESCE(actor_email, ''), api_key_id, org_id,
+			COALESCE(target_type, ''), COALESCE(target_id, ''), COALESCE(ip, ''), COALESCE(user_agent, ''),
+			COALESCE(request_id, ''), details, created_at
+		FROM audit_log ` + where + `
+		ORDER BY created_at DESC, id DESC
+		LIMIT $7 OFFSET $8`
+	rows, err := am.DB.QueryContext(ctx, query, append(args, f.Limit, f.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	entries := []*AuditEntry{}
+	for rows.Next() {
+		var e AuditEntry
+		var details []byte
+		err := rows.Scan(&e.Id, &e.Action, &e.Outcome, &e.ActorId, &e.ActorEmail, &e.APIKeyId, &e.OrgId,
+			&e.TargetType, &e.TargetId, &e.IP, &e.UserAgent, &e.RequestId, &details, &e.CreatedAt)
+		if err != nil {
+			return nil, 0, err
+		}
+		e.Details = details
+		entries = append(entries, &e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return entries, total, nil
+}
@@ -0,0 +1,106 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+var auditColumns = []string{"id", "action", "outcome", "actor_id", "actor_email", "api_key_id", "org_id",
+	"target_type", "target_id", "ip", "user_agent", "request_id", "details", "created_at"}
+
+func TestAuditListFilter(t *testing.T) {
+	since := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	until := since.Add(time.Hour)
+
+	tests := []struct {
+		name   string
+		filter AuditFilter
+		want   []driver.Value
+	}{
+		{
+			name:   "zero filter matches everything",
+			filter: AuditFilter{Limit: 50},
+			want:   []driver.Value{"", "", int64(0), int64(0), nil, nil},
+		},
+		{
+			name:   "action and outcome",
+			filter: AuditFilter{Action: "auth.login", Outcome: OutcomeFailure, Limit: 10},
+			want:   []driver.Value{"auth.login", OutcomeFailure, int64(0), int64(0), nil, nil},
+		},
+		{
+			name:   "actor and organisation",
+			filter: AuditFilter{ActorId: 3, OrgId: 9, Limit: 10, Offset: 20},
+			want:   []driver.Value{"", "", int64(3), int64(9), nil, nil},
+		},
+		{
+			name:   "time range",
+			filter: AuditFilter{Since: &since, Until: &until, Limit: 10},
+			want:   []driver.Value{"", "", int64(0), int64(0), since, until},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, f := newFakeDB(t, func(query string, _ []driver.Value) fakeResult {
+				if strings.Contains(query, "count(*)") {
+					return fakeResult{columns: []string{"count"}, rows: [][]driver.Value{{int64(1)}}}
+				}
+				return fakeResult{columns: auditColumns, rows: [][]driver.Value{{
+					int64(1), "auth.login", OutcomeSuccess, int64(3), "a@example.com", nil, nil,
+					"", "", "127.0.0.1", "", "", []byte(`{"method":"password"}`), since,
+				}}}
+			})
+
+			entries, total, err := (&AuditModel{DB: db}).List(context.Background(), tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if total != 1 || len(entries) != 1 {
+				t.Fatalf("got %d entries of %d; want 1 of 1", len(entries), total)
+			}
+			if e := entries[0]; e.ActorId == nil || *e.ActorId != 3 || e.APIKeyId != nil || string(e.Details) != `{"method":"password"}` {
+				t.Errorf("entry = %+v", e)
+			}
+
+			count := f.queries("count(*)")
+			if len(count) != 1 || !slices.Equal(count[0].args, tt.want) {
+				t.Errorf("count args = %v; want %v", count, tt.want)
+			}
+			list := f.queries("ORDER BY created_at DESC")
+			wantList := append(slices.Clone(tt.want), int64(tt.filter.Limit), int64(tt.filter.Offset))
+			if len(list) != 1 || !slices.Equal(list[0].args, wantList) {
+				t.Errorf("list args = %v; want %v", list, wantList)
+			}
+		})
+	}
+}
+
+func TestAuditInsert(t *testing.T) {
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	db, f := newFakeDB(t, func(string, []driver.Value) fakeResult {
+		return fakeResult{columns: []string{"id", "created_at"}, rows: [][]driver.Value{{int64(42), created}}}
+	})
+
+	org := 9
+	e := AuditEntry{Action: "org.deleted", Outcome: OutcomeSuccess, OrgId: &org}
+	if err := (&AuditModel{DB: db}).Insert(context.Background(), &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Id != 42 || !e.CreatedAt.Equal(created) {
+		t.Errorf("entry = %+v; want id 42 created %v", e, created)
+	}
+
+	calls := f.queries("INSERT INTO audit_log")
+	if len(calls) != 1 {
+		t.Fatalf("%d inserts; want 1", len(calls))
+	}
+	// No details are stored as NULL rather than an empty JSON document
+	want := []driver.Value{"org.deleted", OutcomeSuccess, nil, "", nil, int64(9), "", "", "", "", "", nil}
+	if !slices.Equal(calls[0].args, want) {
+		t.Errorf("args = %v; want %v", calls[0].args, want)
+	}
+}
@@ -0,0 +1,138 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeDB is a database/sql driver that records the statements a store
+// runs and answers them from respond, so the arguments a store passes can
+// be checked without Postgres. The SQL itself is only exercised against a
+// real database.
+type fakeDB struct {
+	mu      sync.Mutex
+	calls   []fakeCall
+	respond func(query string, args []driver.Value) fakeResult
+}
+
+type fakeCall struct {
+	query string
+	args  []driver.Value
+}
+
+// fakeResult answers one statement: rows for a query, affected for an
+// exec, or err for either.
+type fakeResult struct {
+	columns  []string
+	rows     [][]driver.Value
+	affected int64
+	err      error
+}
+
+func newFakeDB(t *testing.T, respond func(query string, args []driver.Value) fakeResult) (*sql.DB, *fakeDB) {
+	t.Helper()
+	f := &fakeDB{respond: respond}
+	db := sql.OpenDB(f)
+	t.Cleanup(func() { db.Close() })
+	return db, f
+}
+
+// queries returns the recorded statements containing substr.
+func (f *fakeDB) queries(substr string) []fakeCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var calls []fakeCall
+	for _, c := range f.calls {
+		if strings.Contains(c.query, substr) {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+func (f *fakeDB) run(query string, named []driver.NamedValue) fakeResult {
+	args := make([]driver.Value, len(named))
+	for i, a := range named {
+		args[i] = a.Value
+	}
+
+	f.mu.Lock()
+	f.calls = append(f.calls, fakeCall{query: query, args: args})
+	f.mu.Unlock()
+
+	if f.respond == nil {
+		return fakeResult{}
+	}
+	return f.respond(query, args)
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) { return fakeConn{f}, nil }
+func (f *fakeDB) Driver() driver.Driver                        { return fakeDriver{f} }
+
+type fakeDriver struct{ f *fakeDB }
+
+func (d fakeDriver) Open(string) (driver.Conn, error) { return fakeConn{d.f}, nil }
+
+type fakeConn struct{ f *fakeDB }
+
+func (c fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("fakedb: prepare") }
+func (c fakeConn) Close() error                        { return nil }
+func (c fakeConn) Begin() (driver.Tx, error)           { return fakeTx{}, nil }
+
+func (c fakeConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	return fakeTx{}, nil
+}
+
+func (c fakeConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	r := c.f.run(query, args)
+	if r.err != nil {
+		return nil, r.err
+	}
+	return &fakeRows{columns: r.columns, rows: r.rows}, nil
+}
+
+func (c fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	r := c.f.run(query, args)
+	if r.err != nil {
+		return nil, r.err
+	}
+	return driver.RowsAffected(r.affected), nil
+}
+
+// CheckNamedValue converts arguments like database/sql would, but keeps
+// values it refuses, such as arrays, as they are.
+func (c fakeConn) CheckNamedValue(v *driver.NamedValue) error {
+	if converted, err := driver.DefaultParameterConverter.ConvertValue(v.Value); err == nil {
+		v.Value = converted
+	}
+	return nil
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
@@ -1,14 +1,27 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
 
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/postgres"
 )
 
+// MigrationsDir is the directory holding the SQL migration files, relative
+// to the repository root.
+const MigrationsDir = "internal/database/migrations"
+
+var migrationName = regexp.MustCompile(`^[a-z0-9_]+$`)
+
 func MigrationUp(db *sql.DB) error {
 	m, err := migrating(db)
 	if err != nil {
@@ -18,23 +31,153 @@ func MigrationUp(db *sql.DB) error {
 		return err
 	}
 
-	log.Println("Migrations completed successfully")
+	slog.Info("Migrations completed successfully")
 	return nil
 }
 
+// MigrationDown rolls back the most recently applied migration only.
 func MigrationDown(db *sql.DB) error {
+	return MigrationSteps(db, -1)
+}
+
+// MigrationSteps applies n migrations forward, or rolls back -n migrations
+// when n is negative.
+func MigrationSteps(db *sql.DB, n int) error {
+	if n == 0 {
+		return errors.New("steps must not be zero")
+	}
+
+	m, err := migrating(db)
+	if err != nil {
+		return fmt.Errorf("could not initialize migration: %w", err)
+	}
+	if err := m.Steps(n); err != nil && err != migrate.ErrNoChange {
+		return fmt.Errorf("could not run migrations: %w", err)
+	}
+
+	return nil
+}
+
+// MigrationGoto migrates up or down until the given version is reached.
+func MigrationGoto(db *sql.DB, version uint) error {
 	m, err := migrating(db)
 	if err != nil {
 		return fmt.Errorf("could not initialize migration: %w", err)
 	}
-	if err := m.Down(); err != nil && err != migrate.ErrNoChange {
+	if err := m.Migrate(version); err != nil && err != migrate.ErrNoChange {
 		return fmt.Errorf("could not run migrations: %w", err)
 	}
 
-	log.Println("Migrations completed successfully")
 	return nil
 }
 
+// MigrationForce sets the recorded version and clears the dirty flag without
+// running any migration. Use it to recover from a failed migration after
+// fixing the schema by hand. A version of -1 means no migration applied.
+func MigrationForce(db *sql.DB, version int) error {
+	m, err := migrating(db)
+	if err != nil {
+		return fmt.Errorf("could not initialize migration: %w", err)
+	}
+	if err := m.Force(version); err != nil {
+		return fmt.Errorf("could not force version: %w", err)
+	}
+
+	return nil
+}
+
+// MigrationStatus returns the currently applied version and whether the last
+// migration failed half-way. A version of 0 means nothing has been applied.
+func MigrationStatus(db *sql.DB) (uint, bool, error) {
+	m, err := migrating(db)
+	if err != nil {
+		return 0, false, fmt.Errorf("could not initialize migration: %w", err)
+	}
+
+	version, dirty, err := m.Version()
+	if err == migrate.ErrNilVersion {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("could not read version: %w", err)
+	}
+
+	return version, dirty, nil
+}
+
+// MigrationCreate writes an empty up/down migration pair named after name,
+// numbered one past the highest existing migration in dir.
+func MigrationCreate(dir, name string) (string, string, error) {
+	name = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(name), " ", "_"))
+	if !migrationName.MatchString(name) {
+		return "", "", fmt.Errorf("invalid migration name %q: use letters, digits and underscores", name)
+	}
+
+	latest, err := LatestMigration(dir)
+	if err != nil {
+		return "", "", err
+	}
+
+	base := fmt.Sprintf("%06d_%s", latest+1, name)
+	up := filepath.Join(dir, base+".up.sql")
+	down := filepath.Join(dir, base+".down.sql")
+	for _, path := range []string{up, down} {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err != nil {
+			return "", "", fmt.Errorf("could not create migration file: %w", err)
+		}
+		f.Close()
+	}
+
+	return up, down, nil
+}
+
+// LatestMigration returns the highest migration version found in dir, which
+// is the version a fully migrated database reports.
+func LatestMigration(dir string) (uint, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, fmt.Errorf("could not read migrations directory: %w", err)
+	}
+
+	var latest uint64
+	for _, e := range entries {
+		prefix, _, ok := strings.Cut(e.Name(), "_")
+		if !ok {
+			continue
+		}
+		v, err := strconv.ParseUint(prefix, 10, 64)
+		if err != nil {
+			continue
+		}
+		latest = max(latest, v)
+	}
+
+	return uint(latest), nil
+}
+
+// SchemaVersion reads the applied version straight from the schema
+// migrations table. Unlike MigrationStatus it takes no lock, so it is cheap
+// enough for health checks.
+func SchemaVersion(ctx context.Context, db *sql.DB) (uint, bool, error) {
+	var version int64
+	var dirty bool
+
+	err := db.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).
+		Scan(&version, &dirty)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	if version < 0 {
+		return 0, dirty, nil
+	}
+
+	return uint(version), dirty, nil
+}
+
 func migrating(db *sql.DB) (*migrate.Migrate, error) {
 
 	config := &postgres.Config{}
@@ -44,7 +187,7 @@ func migrating(db *sql.DB) (*migrate.Migrate, error) {
 	}
 
 	m, err := migrate.NewWithDatabaseInstance(
-		"file://internal/database/migrations",
+		"file://"+MigrationsDir,
 		"postgres", driver)
 	if err != nil {
 		return nil, fmt.Errorf("could not create migrate instance: %w", err)
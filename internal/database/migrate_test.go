@@ -0,0 +1,62 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrationCreate(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"000001_create_users.up.sql", "000009_add_roles.down.sql", "README.md", "draft_notes.sql"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	up, down, err := MigrationCreate(dir, " Add Things ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(dir, "000010_add_things.up.sql"); up != want {
+		t.Errorf("up = %q; want %q", up, want)
+	}
+	if want := filepath.Join(dir, "000010_add_things.down.sql"); down != want {
+		t.Errorf("down = %q; want %q", down, want)
+	}
+	for _, path := range []string{up, down} {
+		if info, err := os.Stat(path); err != nil || info.Size() != 0 {
+			t.Errorf("%s: %v; want an empty file", path, err)
+		}
+	}
+
+	if up, _, err := MigrationCreate(dir, "add_more"); err != nil || filepath.Base(up) != "000011_add_more.up.sql" {
+		t.Errorf("second migration = %q, %v; want 000011_add_more.up.sql", up, err)
+	}
+	if v, err := LatestMigration(dir); err != nil || v != 11 {
+		t.Errorf("LatestMigration = %d, %v; want 11", v, err)
+	}
+}
+
+func TestMigrationCreateFirst(t *testing.T) {
+	up, _, err := MigrationCreate(t.TempDir(), "init")
+	if err != nil || filepath.Base(up) != "000001_init.up.sql" {
+		t.Errorf("first migration = %q, %v; want 000001_init.up.sql", up, err)
+	}
+}
+
+func TestMigrationCreateInvalid(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"", "drop;table", "../escape", "naïve"} {
+		if _, _, err := MigrationCreate(dir, name); err == nil {
+			t.Errorf("MigrationCreate(%q) error = nil; want error", name)
+		}
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("invalid names created %d files", len(entries))
+	}
+
+	if _, _, err := MigrationCreate(filepath.Join(dir, "missing"), "init"); err == nil {
+		t.Error("MigrationCreate in a missing directory error = nil; want error")
+	}
+}
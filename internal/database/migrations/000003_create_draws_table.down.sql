@@ -0,0 +1,2 @@
+alter table people drop column if exists draw_id;
+drop table if exists draws;
@@ -0,0 +1,25 @@
+create table if not exists draws (
+  id serial primary key,
+  user_id integer references users(id) on delete cascade,
+  team_count integer not null,
+  source varchar(32) not null,
+  seed bigint,
+  created_at timestamp default current_timestamp
+);
+
+create index idx_draws_user_id on draws(user_id);
+
+alter table people add column draw_id integer references draws(id) on delete cascade;
+
+create index idx_people_draw_id on people(draw_id);
+
+-- people saved before draws existed become one legacy draw per user
+insert into draws (user_id, team_count, source, created_at)
+select user_id, max(team), 'legacy', min(created_at)
+from people
+where user_id is not null
+group by user_id;
+
+update people p set draw_id = d.id
+from draws d
+where d.user_id = p.user_id and p.draw_id is null;
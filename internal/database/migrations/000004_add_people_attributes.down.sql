@@ -0,0 +1 @@
+alter table people drop column if exists attributes;
@@ -0,0 +1 @@
+alter table people add column if not exists attributes jsonb;
@@ -0,0 +1,12 @@
+create table if not exists shares (
+  id serial primary key,
+  slug varchar(32) not null unique,
+  draw_id integer not null references draws(id) on delete cascade,
+  user_id integer not null references users(id) on delete cascade,
+  password varchar(255),
+  expires_at timestamp,
+  revoked_at timestamp,
+  created_at timestamp default current_timestamp
+);
+
+create index idx_shares_draw_id on shares(draw_id);
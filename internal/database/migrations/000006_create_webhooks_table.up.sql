@@ -0,0 +1,29 @@
+create table if not exists webhooks (
+  id serial primary key,
+  user_id integer not null references users(id) on delete cascade,
+  url text not null,
+  secret varchar(255) not null,
+  events text[] not null,
+  created_at timestamp default current_timestamp
+);
+
+create index idx_webhooks_user_id on webhooks(user_id);
+
+create table if not exists webhook_deliveries (
+  id serial primary key,
+  webhook_id integer not null references webhooks(id) on delete cascade,
+  event varchar(64) not null,
+  payload jsonb not null,
+  status varchar(16) not null default 'pending',
+  attempts integer not null default 0,
+  response_status integer,
+  error text,
+  duration_ms integer,
+  next_attempt_at timestamp default current_timestamp,
+  last_attempt_at timestamp,
+  delivered_at timestamp,
+  created_at timestamp default current_timestamp
+);
+
+create index idx_webhook_deliveries_webhook_id on webhook_deliveries(webhook_id);
+create index idx_webhook_deliveries_due on webhook_deliveries(next_attempt_at) where status = 'pending';
@@ -0,0 +1,3 @@
+alter table draws drop column if exists org_id;
+drop table if exists organization_members;
+drop table if exists organizations;
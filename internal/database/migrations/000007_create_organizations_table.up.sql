@@ -0,0 +1,21 @@
+create table if not exists organizations (
+  id serial primary key,
+  name varchar(100) not null,
+  created_at timestamp default current_timestamp
+);
+
+create table if not exists organization_members (
+  org_id integer not null references organizations(id) on delete cascade,
+  user_id integer not null references users(id) on delete cascade,
+  role varchar(16) not null check (role in ('owner', 'admin', 'member', 'viewer')),
+  created_at timestamp default current_timestamp,
+  primary key (org_id, user_id)
+);
+
+create index idx_organization_members_user_id on organization_members(user_id);
+
+-- draws of an organisation are visible to all of its members; draws
+-- without one stay personal to user_id
+alter table draws add column if not exists org_id integer references organizations(id) on delete cascade;
+
+create index idx_draws_org_id on draws(org_id);
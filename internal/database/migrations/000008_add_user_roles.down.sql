@@ -0,0 +1,4 @@
+drop index if exists idx_users_role;
+alter table users drop column if exists password_reset_required;
+alter table users drop column if exists disabled_at;
+alter table users drop column if exists role;
@@ -0,0 +1,12 @@
+-- system-wide role of the user; organisation roles are separate
+alter table users add column if not exists role varchar(16) not null default 'user'
+  check (role in ('user', 'support', 'admin'));
+
+-- disabled users cannot log in and their tokens are refused
+alter table users add column if not exists disabled_at timestamp;
+
+-- set when an operator resets the password; the user must choose a new one
+-- before doing anything else
+alter table users add column if not exists password_reset_required boolean not null default false;
+
+create index idx_users_role on users(role) where role <> 'user';
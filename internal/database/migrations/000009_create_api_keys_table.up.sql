@@ -0,0 +1,17 @@
+-- keys act as user_id, who created them; keys with an org_id only work
+-- within that organisation
+create table if not exists api_keys (
+  id serial primary key,
+  user_id integer not null references users(id) on delete cascade,
+  org_id integer references organizations(id) on delete cascade,
+  name varchar(100) not null,
+  prefix varchar(16) not null,
+  key_hash char(64) not null unique,
+  scopes text[] not null,
+  last_used_at timestamp,
+  revoked_at timestamp,
+  created_at timestamp default current_timestamp
+);
+
+create index idx_api_keys_user_id on api_keys(user_id);
+create index idx_api_keys_org_id on api_keys(org_id);
@@ -0,0 +1,35 @@
+-- append-only record of security and data events; ids are kept without
+-- foreign keys so entries outlive the users, organisations and keys they
+-- mention
+create table if not exists audit_log (
+  id bigserial primary key,
+  action varchar(64) not null,
+  outcome varchar(16) not null check (outcome in ('success', 'failure')),
+  actor_id integer,
+  actor_email varchar(255),
+  api_key_id integer,
+  org_id integer,
+  target_type varchar(32),
+  target_id varchar(64),
+  ip varchar(64),
+  user_agent varchar(512),
+  request_id varchar(128),
+  details jsonb,
+  created_at timestamp not null default current_timestamp
+);
+
+create index idx_audit_log_created_at on audit_log(created_at);
+create index idx_audit_log_actor_id on audit_log(actor_id, created_at);
+create index idx_audit_log_org_id on audit_log(org_id, created_at) where org_id is not null;
+
+create or replace function audit_log_append_only() returns trigger as $$
+begin
+  raise exception 'audit_log is append-only';
+end;
+$$ language plpgsql;
+
+create trigger audit_log_no_change before update or delete on audit_log
+  for each row execute function audit_log_append_only();
+
+create trigger audit_log_no_truncate before truncate on audit_log
+  for each statement execute function audit_log_append_only();
@@ -0,0 +1,9 @@
+-- draws saved to an organisation outlive the member who saved them;
+-- UserModel.Delete removes the user's personal draws itself
+alter table draws drop constraint if exists draws_user_id_fkey;
+alter table draws add constraint draws_user_id_fkey
+  foreign key (user_id) references users(id) on delete set null;
+
+alter table people drop constraint if exists people_user_id_fkey;
+alter table people add constraint people_user_id_fkey
+  foreign key (user_id) references users(id) on delete set null;
@@ -0,0 +1 @@
+alter table users drop column if exists token_version;
@@ -0,0 +1,3 @@
+-- tokens carry the version they were issued at; changing or resetting the
+-- password bumps it so earlier tokens are refused
+alter table users add column if not exists token_version integer not null default 0;
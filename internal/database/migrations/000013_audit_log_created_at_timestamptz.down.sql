@@ -0,0 +1,3 @@
+alter table audit_log
+  alter column created_at type timestamp using created_at at time zone current_setting('TimeZone'),
+  alter column created_at set default current_timestamp;
@@ -0,0 +1,5 @@
+-- since/until filters are absolute instants; existing entries were written
+-- in the session time zone, which the conversion assumes
+alter table audit_log
+  alter column created_at type timestamptz using created_at at time zone current_setting('TimeZone'),
+  alter column created_at set default current_timestamp;
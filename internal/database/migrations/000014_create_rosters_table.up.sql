@@ -0,0 +1,13 @@
+-- a roster is an organisation's saved list of people to draw teams from;
+-- people is a JSON array of {name, role, attributes}
+create table if not exists rosters (
+  id serial primary key,
+  org_id integer not null references organizations(id) on delete cascade,
+  name varchar(100) not null,
+  people jsonb not null,
+  created_by integer references users(id) on delete set null,
+  created_at timestamp default current_timestamp,
+  updated_at timestamp default current_timestamp
+);
+
+create index idx_rosters_org_id on rosters(org_id);
@@ -3,13 +3,27 @@ package database
 import "database/sql"
 
 type Models struct {
-	Users  UserStore
-	People PeopleStore
+	Users    UserStore
+	People   PeopleStore
+	Shares   ShareStore
+	Webhooks WebhookStore
+	Orgs     OrgStore
+	Stats    StatsStore
+	APIKeys  APIKeyStore
+	Audit    AuditStore
+	Rosters  RosterStore
 }
 
 func NewModels(db *sql.DB) Models {
 	return Models{
-		Users:  &UserModel{DB: db},
-		People: &PeopleModel{DB: db},
+		Users:    &UserModel{DB: db},
+		People:   &PeopleModel{DB: db},
+		Shares:   &ShareModel{DB: db},
+		Webhooks: &WebhookModel{DB: db},
+		Orgs:     &OrgModel{DB: db},
+		Stats:    &StatsModel{DB: db},
+		APIKeys:  &APIKeyModel{DB: db},
+		Audit:    &AuditModel{DB: db},
+		Rosters:  &RosterModel{DB: db},
 	}
 }
@@ -0,0 +1,416 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Organisation roles, from most to least privileged.
+const (
+	RoleOwner  = "owner"
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+	RoleViewer = "viewer"
+)
+
+// ErrDuplicateMember is returned by AddMember when the user already belongs
+// to the organisation.
+var ErrDuplicateMember = errors.New("database: duplicate member")
+
+// ErrLastOwner is returned by UpdateMember and RemoveMember when the change
+// would leave the organisation without an owner.
+var ErrLastOwner = errors.New("database: last owner")
+
+// LastOwnerError is returned by UserModel.Delete when the user is the only
+// owner of an organisation. It matches ErrLastOwner.
+type LastOwnerError struct {
+	OrgId   int
+	OrgName string
+}
+
+func (e *LastOwnerError) Error() string {
+	return fmt.Sprintf("database: last owner of organisation %d", e.OrgId)
+}
+
+func (e *LastOwnerError) Is(target error) bool { return target == ErrLastOwner }
+
+type OrgStore interface {
+	Create(ctx context.Context, o *Org, ownerId int) error
+	Get(ctx context.Context, id int) (*Org, error)
+	Delete(ctx context.Context, id int) error
+	ListByUserId(ctx context.Context, userId int) ([]*Membership, error)
+	GetMembership(ctx context.Context, orgId, userId int) (*Membership, error)
+	ListMembers(ctx context.Context, orgId int) ([]*Member, error)
+	AddMember(ctx context.Context, orgId, userId int, role string) error
+	UpdateMember(ctx context.Context, orgId, userId int, role string) error
+	RemoveMember(ctx context.Context, orgId, userId int) error
+}
+
+type OrgModel struct {
+	DB *sql.DB
+}
+
+// Org is an organisation whose members share draws.
+type Org struct {
+	Id        int       `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Membership is a user's role in an organisation.
+type Membership struct {
+	OrgId   int    `json:"org_id"`
+	OrgName string `json:"org_name"`
+	UserId  int    `json:"-"`
+	Role    string `json:"role"`
+}
+
+// Member is a user as listed in an organisation.
+type Member struct {
+	UserId    int       `json:"user_id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+var _ OrgStore = (*OrgModel)(nil)
+
+// Create stores the organisation with ownerId as its first owner.
+func (om *OrgModel) Create(ctx context.Context, o *Org, ownerId int) (err error) {
+	ctx, span := startSpan(ctx, "OrgModel.Create")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	tx, err := om.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `INSERT INTO organizations (name) VALUES ($1) RETURNING id, created_at`, o.Name).
+		Scan(&o.Id, &o.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert organization: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO organization_members (org_id, user_id, role) VALUES ($1, $2, $3)`,
+		o.Id, ownerId, RoleOwner)
+	if err != nil {
+		return fmt.Errorf("failed to insert owner: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// Get returns the organisation, or nil if there is none.
+func (om *OrgModel) Get(ctx context.Context, id int) (_ *Org, err error) {
+	ctx, span := startSpan(ctx, "OrgModel.Get")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var o Org
+	err = om.DB.QueryRowContext(ctx, `SELECT id, name, created_at FROM organizations WHERE id = $1`, id).
+		Scan(&o.Id, &o.Name, &o.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &o, nil
+}
+
+// Delete removes the organisation with its members, rosters and draws.
+func (om *OrgModel) Delete(ctx context.Context, id int) (err error) {
+	ctx, span := startSpan(ctx, "OrgModel.Delete")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	return om.execOne(ctx, `DELETE FROM organizations WHERE id = $1`, id)
+}
+
+// ListByUserId returns the organisations the user belongs to, by name.
+func (om *OrgModel) ListByUserId(ctx context.Context, userId int) (_ []*Membership, err error) {
+	ctx, span := startSpan(ctx, "OrgModel.ListByUserId")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	query := `SELECT o.id, o.name, m.user_id, m.role
+		FROM organization_members m
+		JOIN organizations o ON o.id = m.org_id
+		WHERE m.user_id = $1
+		ORDER BY o.name, o.id`
+	rows, err := om.DB.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	memberships := []*Membership{}
+	for rows.Next() {
+		var m Membership
+		if err := rows.Scan(&m.OrgId, &m.OrgName, &m.UserId, &m.Role); err != nil {
+			return nil, err
+		}
+		memberships = append(memberships, &m)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return memberships, nil
+}
+
+// GetMembership returns the user's role in the organisation, or nil if the
+// user is not a member.
+func (om *OrgModel) GetMembership(ctx context.Context, orgId, userId int) (_ *Membership, err error) {
+	ctx, span := startSpan(ctx, "OrgModel.GetMembership")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	query := `SELECT o.id, o.name, m.user_id, m.role
+		FROM organization_members m
+		JOIN organizations o ON o.id = m.org_id
+		WHERE m.org_id = $1 AND m.user_id = $2`
+	var m Membership
+	err = om.DB.QueryRowContext(ctx, query, orgId, userId).Scan(&m.OrgId, &m.OrgName, &m.UserId, &m.Role)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+// ListMembers returns the organisation's members, by name.
+func (om *OrgModel) ListMembers(ctx context.Context, orgId int) (_ []*Member, err error) {
+	ctx, span := startSpan(ctx, "OrgModel.ListMembers")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	query := `SELECT u.id, u.name, u.email, m.role, m.created_at
+		FROM organization_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.org_id = $1
+		ORDER BY u.name, u.id`
+	rows, err := om.DB.QueryContext(ctx, query, orgId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []*Member{}
+	for rows.Next() {
+		var m Member
+		if err := rows.Scan(&m.UserId, &m.Name, &m.Email, &m.Role, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, &m)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
+
+// AddMember adds the user to the organisation with role.
+func (om *OrgModel) AddMember(ctx context.Context, orgId, userId int, role string) (err error) {
+	ctx, span := startSpan(ctx, "OrgModel.AddMember")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	query := `INSERT INTO organization_members (org_id, user_id, role) VALUES ($1, $2, $3)`
+	_, err = om.DB.ExecContext(ctx, query, orgId, userId, role)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+		return ErrDuplicateMember
+	}
+	return err
+}
+
+// UpdateMember changes a member's role. A member who drops below admin can
+// no longer create the organisation's API keys, so the keys they created
+// are revoked. It returns ErrLastOwner if the member is the only owner and
+// role is not owner, and sql.ErrNoRows if the user is not a member.
+func (om *OrgModel) UpdateMember(ctx context.Context, orgId, userId int, role string) (err error) {
+	ctx, span := startSpan(ctx, "OrgModel.UpdateMember")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	tx, err := om.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if role != RoleOwner {
+		if err := checkOtherOwners(ctx, tx, orgId, userId); err != nil {
+			return err
+		}
+	}
+
+	res, err := tx.ExecContext(ctx, `UPDATE organization_members SET role = $3 WHERE org_id = $1 AND user_id = $2`, orgId, userId, role)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	if role != RoleOwner && role != RoleAdmin {
+		if err := revokeOrgAPIKeys(ctx, tx, orgId, userId); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// RemoveMember removes the user from the organisation and revokes the
+// links they made to its draws and the API keys they created for it. Draws the user saved stay with the
+// organisation. It returns ErrLastOwner if the user is the only owner, and
+// sql.ErrNoRows if the user is not a member.
+func (om *OrgModel) RemoveMember(ctx context.Context, orgId, userId int) (err error) {
+	ctx, span := startSpan(ctx, "OrgModel.RemoveMember")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	tx, err := om.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := checkOtherOwners(ctx, tx, orgId, userId); err != nil {
+		return err
+	}
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM organization_members WHERE org_id = $1 AND user_id = $2`, orgId, userId)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE shares s SET revoked_at = current_timestamp
+		FROM draws d
+		WHERE d.id = s.draw_id AND d.org_id = $1 AND s.user_id = $2 AND s.revoked_at IS NULL`, orgId, userId)
+	if err != nil {
+		return fmt.Errorf("failed to revoke shares: %w", err)
+	}
+
+	if err := revokeOrgAPIKeys(ctx, tx, orgId, userId); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// revokeOrgAPIKeys revokes the organisation's API keys that userId created.
+func revokeOrgAPIKeys(ctx context.Context, tx *sql.Tx, orgId, userId int) error {
+	_, err := tx.ExecContext(ctx, `UPDATE api_keys SET revoked_at = current_timestamp
+		WHERE org_id = $1 AND user_id = $2 AND revoked_at IS NULL`, orgId, userId)
+	if err != nil {
+		return fmt.Errorf("failed to revoke API keys: %w", err)
+	}
+	return nil
+}
+
+// checkOtherOwners returns ErrLastOwner if userId is the organisation's only
+// owner. It locks the owners until tx ends, so concurrent changes cannot
+// both see another owner and remove the last two.
+func checkOtherOwners(ctx context.Context, tx *sql.Tx, orgId, userId int) error {
+	query := `SELECT user_id FROM organization_members WHERE org_id = $1 AND role = $2 FOR UPDATE`
+	rows, err := tx.QueryContext(ctx, query, orgId, RoleOwner)
+	if err != nil {
+		return fmt.Errorf("failed to lock owners: %w", err)
+	}
+	defer rows.Close()
+
+	owner, others := false, 0
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		if id == userId {
+			owner = true
+		} else {
+			others++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if owner && others == 0 {
+		return ErrLastOwner
+	}
+	return nil
+}
+
+// execOne runs a statement that must affect exactly one row and reports
+// sql.ErrNoRows otherwise.
+func (om *OrgModel) execOne(ctx context.Context, query string, args ...any) error {
+	res, err := om.DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// memberDB answers the owner lock with owners and every other statement
+// with one affected row.
+func memberDB(t *testing.T, owners ...int64) (*OrgModel, *fakeDB) {
+	db, f := newFakeDB(t, func(query string, _ []driver.Value) fakeResult {
+		if strings.Contains(query, "FOR UPDATE") {
+			var rows [][]driver.Value
+			for _, id := range owners {
+				rows = append(rows, []driver.Value{id})
+			}
+			return fakeResult{columns: []string{"user_id"}, rows: rows}
+		}
+		return fakeResult{affected: 1}
+	})
+	return &OrgModel{DB: db}, f
+}
+
+func TestRemoveMemberRevokesAPIKeys(t *testing.T) {
+	om, f := memberDB(t, 1)
+
+	if err := om.RemoveMember(context.Background(), 7, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	revoked := f.queries("UPDATE api_keys")
+	if len(revoked) != 1 {
+		t.Fatalf("%d key revocations; want 1", len(revoked))
+	}
+	if args := revoked[0].args; args[0] != int64(7) || args[1] != int64(3) {
+		t.Errorf("revoked keys of org %v, user %v; want org 7, user 3", args[0], args[1])
+	}
+}
+
+func TestRemoveMemberLastOwnerKeepsAPIKeys(t *testing.T) {
+	om, f := memberDB(t, 3)
+
+	if err := om.RemoveMember(context.Background(), 7, 3); !errors.Is(err, ErrLastOwner) {
+		t.Fatalf("err = %v; want ErrLastOwner", err)
+	}
+	if n := len(f.queries("UPDATE api_keys")); n != 0 {
+		t.Errorf("%d key revocations; want none", n)
+	}
+}
+
+func TestUpdateMemberRevokesAPIKeys(t *testing.T) {
+	tests := []struct {
+		role string
+		want int
+	}{
+		{RoleOwner, 0},
+		{RoleAdmin, 0},
+		{RoleMember, 1},
+		{RoleViewer, 1},
+	}
+	for _, tt := range tests {
+		om, f := memberDB(t, 1)
+
+		if err := om.UpdateMember(context.Background(), 7, 3, tt.role); err != nil {
+			t.Fatalf("UpdateMember(%q): %v", tt.role, err)
+		}
+		if got := len(f.queries("UPDATE api_keys")); got != tt.want {
+			t.Errorf("UpdateMember(%q) revoked keys %d times; want %d", tt.role, got, tt.want)
+		}
+	}
+}
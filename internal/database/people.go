@@ -3,13 +3,21 @@ package database
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 )
 
 type PeopleStore interface {
-	GetAllbyUserId(userId int) ([]*People, error)
-	Save(userId int, people []*People) error
+	GetAllbyUserId(ctx context.Context, userId int) ([]*People, error)
+	GetDrawsByUserId(ctx context.Context, userId int) ([]*Draw, error)
+	GetDrawsByOrgId(ctx context.Context, orgId int) ([]*Draw, error)
+	GetDraw(ctx context.Context, id int) (*Draw, error)
+	Save(ctx context.Context, userId int, draw *Draw) error
+	DeleteDraw(ctx context.Context, userId, id int) error
+	DeleteOrgDraw(ctx context.Context, orgId, id int) error
 }
 
 type PeopleModel struct {
@@ -17,10 +25,38 @@ type PeopleModel struct {
 }
 
 type People struct {
-	Id   int    `json:"id"`
-	Name string `json:"name"`
-	Role string `json:"role"`
-	Team int    `json:"team"`
+	Id         int        `json:"id"`
+	Name       string     `json:"name"`
+	Role       string     `json:"role"`
+	Team       int        `json:"team"`
+	Attributes Attributes `json:"attributes,omitempty"`
+}
+
+// Attributes are extra details about a person, such as a shirt size from an
+// imported roster. They are stored as a JSON object, or NULL when empty.
+type Attributes map[string]string
+
+func (a Attributes) Value() (driver.Value, error) {
+	if len(a) == 0 {
+		return nil, nil
+	}
+	// As a string, since lib/pq sends []byte as bytea.
+	b, err := json.Marshal(a)
+	return string(b), err
+}
+
+func (a *Attributes) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*a = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, a)
+	case string:
+		return json.Unmarshal([]byte(v), a)
+	default:
+		return errors.New("database: attributes must be a JSON object")
+	}
 }
 
 type PeopleData struct {
@@ -28,13 +64,29 @@ type PeopleData struct {
 	People []*People
 }
 
+// Draw is one saved randomization together with the people it assigned.
+// Source names the randomness used; Seed is set for seeded draws so they
+// can be reproduced. OrgId is set for draws owned by an organisation.
+type Draw struct {
+	Id        int       `json:"id"`
+	OrgId     *int      `json:"org_id,omitempty"`
+	TeamCount int       `json:"team_count"`
+	Source    string    `json:"source"`
+	Seed      *int64    `json:"seed,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	People    []*People `json:"people"`
+}
+
 var _ PeopleStore = (*PeopleModel)(nil)
 
-func (pm *PeopleModel) GetAllbyUserId(userId int) ([]*People, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+func (pm *PeopleModel) GetAllbyUserId(ctx context.Context, userId int) (_ []*People, err error) {
+	ctx, span := startSpan(ctx, "PeopleModel.GetAllbyUserId")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
 
-	query := `SELECT id, name, role, team FROM people WHERE user_id = $1 ORDER BY role, name`
+	query := `SELECT id, name, role, team, attributes FROM people WHERE user_id = $1 ORDER BY role, name`
 	rows, err := pm.DB.QueryContext(ctx, query, userId)
 	if err != nil {
 		return nil, err
@@ -46,7 +98,7 @@ func (pm *PeopleModel) GetAllbyUserId(userId int) ([]*People, error) {
 	for rows.Next() {
 		var p People
 
-		err := rows.Scan(&p.Id, &p.Name, &p.Role, &p.Team)
+		err := rows.Scan(&p.Id, &p.Name, &p.Role, &p.Team, &p.Attributes)
 		if err != nil {
 			return nil, err
 		}
@@ -61,8 +113,154 @@ func (pm *PeopleModel) GetAllbyUserId(userId int) ([]*People, error) {
 	return peoples, nil
 }
 
-func (pm *PeopleModel) Save(userId int, people []*People) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+// GetDrawsByUserId returns the user's personal draws, newest first, each with its
+// people ordered by team.
+func (pm *PeopleModel) GetDrawsByUserId(ctx context.Context, userId int) (_ []*Draw, err error) {
+	ctx, span := startSpan(ctx, "PeopleModel.GetDrawsByUserId")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	query := `SELECT d.id, d.org_id, d.team_count, d.source, d.seed, d.created_at, p.id, p.name, p.role, p.team, p.attributes
+		FROM draws d
+		JOIN people p ON p.draw_id = d.id
+		WHERE d.user_id = $1 AND d.org_id IS NULL
+		ORDER BY d.created_at DESC, d.id DESC, p.team, p.id`
+	return pm.queryDraws(ctx, query, userId)
+}
+
+// GetDrawsByOrgId returns the organisation's draws, newest first, each with
+// its people ordered by team.
+func (pm *PeopleModel) GetDrawsByOrgId(ctx context.Context, orgId int) (_ []*Draw, err error) {
+	ctx, span := startSpan(ctx, "PeopleModel.GetDrawsByOrgId")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	query := `SELECT d.id, d.org_id, d.team_count, d.source, d.seed, d.created_at, p.id, p.name, p.role, p.team, p.attributes
+		FROM draws d
+		JOIN people p ON p.draw_id = d.id
+		WHERE d.org_id = $1
+		ORDER BY d.created_at DESC, d.id DESC, p.team, p.id`
+	return pm.queryDraws(ctx, query, orgId)
+}
+
+// GetDraw returns the draw with its people ordered by team, or nil if there
+// is no such draw.
+func (pm *PeopleModel) GetDraw(ctx context.Context, id int) (_ *Draw, err error) {
+	ctx, span := startSpan(ctx, "PeopleModel.GetDraw")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	query := `SELECT d.id, d.org_id, d.team_count, d.source, d.seed, d.created_at, p.id, p.name, p.role, p.team, p.attributes
+		FROM draws d
+		JOIN people p ON p.draw_id = d.id
+		WHERE d.id = $1
+		ORDER BY p.team, p.id`
+	draws, err := pm.queryDraws(ctx, query, id)
+	if err != nil || len(draws) == 0 {
+		return nil, err
+	}
+
+	return draws[0], nil
+}
+
+// DeleteDraw removes one of the user's personal draws with its people and
+// share links. It returns sql.ErrNoRows if the user has no such draw.
+func (pm *PeopleModel) DeleteDraw(ctx context.Context, userId, id int) (err error) {
+	ctx, span := startSpan(ctx, "PeopleModel.DeleteDraw")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	return pm.execOne(ctx, `DELETE FROM draws WHERE id = $1 AND user_id = $2 AND org_id IS NULL`, id, userId)
+}
+
+// DeleteOrgDraw removes one of the organisation's draws with its people
+// and share links. It returns sql.ErrNoRows if the organisation has no
+// such draw.
+func (pm *PeopleModel) DeleteOrgDraw(ctx context.Context, orgId, id int) (err error) {
+	ctx, span := startSpan(ctx, "PeopleModel.DeleteOrgDraw")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	return pm.execOne(ctx, `DELETE FROM draws WHERE id = $1 AND org_id = $2`, id, orgId)
+}
+
+// execOne runs a statement that must affect exactly one row and reports
+// sql.ErrNoRows otherwise.
+func (pm *PeopleModel) execOne(ctx context.Context, query string, args ...any) error {
+	res, err := pm.DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// queryDraws runs a query joining draws with their people, one row per
+// person with the rows of a draw together, and groups the rows into draws.
+func (pm *PeopleModel) queryDraws(ctx context.Context, query string, args ...any) ([]*Draw, error) {
+	rows, err := pm.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	draws := []*Draw{}
+	var current *Draw
+
+	for rows.Next() {
+		var d Draw
+		var seed sql.NullInt64
+		var p People
+
+		err := rows.Scan(&d.Id, &d.OrgId, &d.TeamCount, &d.Source, &seed, &d.CreatedAt,
+			&p.Id, &p.Name, &p.Role, &p.Team, &p.Attributes)
+		if err != nil {
+			return nil, err
+		}
+
+		if current == nil || current.Id != d.Id {
+			if seed.Valid {
+				d.Seed = &seed.Int64
+			}
+			current = &d
+			draws = append(draws, current)
+		}
+		current.People = append(current.People, &p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return draws, nil
+}
+
+// Save stores the draw and its people in one transaction, filling in the
+// generated ids and creation time. A draw with OrgId set is saved to the
+// organisation, with userId recorded as its creator.
+func (pm *PeopleModel) Save(ctx context.Context, userId int, draw *Draw) (err error) {
+	ctx, span := startSpan(ctx, "PeopleModel.Save")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
 	tx, err := pm.DB.BeginTx(ctx, nil)
@@ -71,15 +269,22 @@ func (pm *PeopleModel) Save(userId int, people []*People) error {
 	}
 	defer tx.Rollback()
 
-	query := `INSERT INTO people (name, role, team, user_id) VALUES ($1, $2, $3, $4) RETURNING id`
+	drawQuery := `INSERT INTO draws (user_id, org_id, team_count, source, seed) VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at`
+	err = tx.QueryRowContext(ctx, drawQuery, userId, draw.OrgId, draw.TeamCount, draw.Source, draw.Seed).
+		Scan(&draw.Id, &draw.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert draw: %w", err)
+	}
+
+	query := `INSERT INTO people (name, role, team, attributes, user_id, draw_id) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
 	stmt, err := tx.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("failed to prepare statement: %w", err)
 	}
 	defer stmt.Close()
 
-	for _, p := range people {
-		err = stmt.QueryRowContext(ctx, p.Name, p.Role, p.Team, userId).
+	for _, p := range draw.People {
+		err = stmt.QueryRowContext(ctx, p.Name, p.Role, p.Team, p.Attributes, userId, draw.Id).
 			Scan(&p.Id)
 		if err != nil {
 			return fmt.Errorf("failed to insert people: %w", err)
@@ -0,0 +1,184 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+type RosterStore interface {
+	Insert(ctx context.Context, r *Roster) error
+	Get(ctx context.Context, orgId, id int) (*Roster, error)
+	ListByOrgId(ctx context.Context, orgId int) ([]*Roster, error)
+	Update(ctx context.Context, r *Roster) error
+	Delete(ctx context.Context, orgId, id int) error
+}
+
+type RosterModel struct {
+	DB *sql.DB
+}
+
+// Roster is an organisation's saved list of people, so members can draw
+// teams from the same people without sending them each time. CreatedBy is
+// nil once the member who created it is deleted.
+type Roster struct {
+	Id        int          `json:"id"`
+	OrgId     int          `json:"org_id"`
+	Name      string       `json:"name"`
+	People    RosterPeople `json:"people"`
+	CreatedBy *int         `json:"created_by,omitempty"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+// RosterPerson is one person on a roster.
+type RosterPerson struct {
+	Name       string     `json:"name"`
+	Role       string     `json:"role"`
+	Attributes Attributes `json:"attributes,omitempty"`
+}
+
+// RosterPeople are stored as a JSON array.
+type RosterPeople []RosterPerson
+
+func (p RosterPeople) Value() (driver.Value, error) {
+	if p == nil {
+		p = RosterPeople{}
+	}
+	// As a string, since lib/pq sends []byte as bytea.
+	b, err := json.Marshal(p)
+	return string(b), err
+}
+
+func (p *RosterPeople) Scan(src any) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, p)
+	case string:
+		return json.Unmarshal([]byte(v), p)
+	default:
+		return errors.New("database: roster people must be a JSON array")
+	}
+}
+
+var _ RosterStore = (*RosterModel)(nil)
+
+const rosterColumns = `id, org_id, name, people, created_by, created_at, updated_at`
+
+func (rm *RosterModel) Insert(ctx context.Context, r *Roster) (err error) {
+	ctx, span := startSpan(ctx, "RosterModel.Insert")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	query := `INSERT INTO rosters (org_id, name, people, created_by) VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, updated_at`
+	return rm.DB.QueryRowContext(ctx, query, r.OrgId, r.Name, r.People, r.CreatedBy).
+		Scan(&r.Id, &r.CreatedAt, &r.UpdatedAt)
+}
+
+// Get returns one of the organisation's rosters, or nil if it has no such
+// roster.
+func (rm *RosterModel) Get(ctx context.Context, orgId, id int) (_ *Roster, err error) {
+	ctx, span := startSpan(ctx, "RosterModel.Get")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	query := `SELECT ` + rosterColumns + ` FROM rosters WHERE id = $1 AND org_id = $2`
+	r, err := scanRoster(rm.DB.QueryRowContext(ctx, query, id, orgId))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// ListByOrgId returns the organisation's rosters by name.
+func (rm *RosterModel) ListByOrgId(ctx context.Context, orgId int) (_ []*Roster, err error) {
+	ctx, span := startSpan(ctx, "RosterModel.ListByOrgId")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	query := `SELECT ` + rosterColumns + ` FROM rosters WHERE org_id = $1 ORDER BY name, id`
+	rows, err := rm.DB.QueryContext(ctx, query, orgId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rosters := []*Roster{}
+	for rows.Next() {
+		r, err := scanRoster(rows)
+		if err != nil {
+			return nil, err
+		}
+		rosters = append(rosters, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return rosters, nil
+}
+
+// Update replaces the name and people of one of the organisation's
+// rosters. It returns sql.ErrNoRows if the organisation has no such roster.
+func (rm *RosterModel) Update(ctx context.Context, r *Roster) (err error) {
+	ctx, span := startSpan(ctx, "RosterModel.Update")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	query := `UPDATE rosters SET name = $3, people = $4, updated_at = current_timestamp
+		WHERE id = $1 AND org_id = $2
+		RETURNING created_by, created_at, updated_at`
+	return rm.DB.QueryRowContext(ctx, query, r.Id, r.OrgId, r.Name, r.People).
+		Scan(&r.CreatedBy, &r.CreatedAt, &r.UpdatedAt)
+}
+
+// Delete removes one of the organisation's rosters. Draws made from it are
+// kept. It returns sql.ErrNoRows if the organisation has no such roster.
+func (rm *RosterModel) Delete(ctx context.Context, orgId, id int) (err error) {
+	ctx, span := startSpan(ctx, "RosterModel.Delete")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	res, err := rm.DB.ExecContext(ctx, `DELETE FROM rosters WHERE id = $1 AND org_id = $2`, id, orgId)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func scanRoster(row interface{ Scan(...any) error }) (*Roster, error) {
+	var r Roster
+	err := row.Scan(&r.Id, &r.OrgId, &r.Name, &r.People, &r.CreatedBy, &r.CreatedAt, &r.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
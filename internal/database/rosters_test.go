@@ -0,0 +1,44 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRosterPeopleRoundTrip(t *testing.T) {
+	tests := []struct {
+		people RosterPeople
+		want   string
+	}{
+		{nil, `[]`},
+		{RosterPeople{{Name: "Ann", Role: "dev"}}, `[{"name":"Ann","role":"dev"}]`},
+		{
+			RosterPeople{{Name: "Bob", Role: "qa", Attributes: Attributes{"shirt": "M"}}},
+			`[{"name":"Bob","role":"qa","attributes":{"shirt":"M"}}]`,
+		},
+	}
+	for _, tt := range tests {
+		v, err := tt.people.Value()
+		if err != nil || v != tt.want {
+			t.Errorf("%+v.Value() = %v, %v; want %s", tt.people, v, err, tt.want)
+			continue
+		}
+
+		var got RosterPeople
+		if err := got.Scan([]byte(tt.want)); err != nil {
+			t.Fatalf("Scan(%s): %v", tt.want, err)
+		}
+		want := tt.people
+		if want == nil {
+			want = RosterPeople{}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Scan(%s) = %+v; want %+v", tt.want, got, want)
+		}
+	}
+
+	var p RosterPeople
+	if err := p.Scan(nil); err == nil {
+		t.Error("Scan(nil) succeeded; want an error for the not null column")
+	}
+}
@@ -0,0 +1,143 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type ShareStore interface {
+	Insert(ctx context.Context, s *Share, orgId *int, ttl time.Duration) error
+	GetBySlug(ctx context.Context, slug string) (*Share, error)
+	ListByUserId(ctx context.Context, userId int) ([]*Share, error)
+	Revoke(ctx context.Context, userId int, slug string) error
+}
+
+type ShareModel struct {
+	DB *sql.DB
+}
+
+// Share is a public link to a saved draw. Password is the bcrypt hash, or
+// empty for links without one. Expired is computed by the database so the
+// comparison uses its clock.
+type Share struct {
+	Id        int        `json:"-"`
+	Slug      string     `json:"slug"`
+	DrawId    int        `json:"draw_id"`
+	UserId    int        `json:"-"`
+	Password  string     `json:"-"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	Expired   bool       `json:"expired"`
+}
+
+var _ ShareStore = (*ShareModel)(nil)
+
+// Insert stores a link to one of the user's personal draws, or to one of
+// the organisation's draws when orgId is set, expiring after ttl or never
+// when ttl is zero. It returns sql.ErrNoRows if there is no such draw.
+func (sm *ShareModel) Insert(ctx context.Context, s *Share, orgId *int, ttl time.Duration) (err error) {
+	ctx, span := startSpan(ctx, "ShareModel.Insert")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	query := `INSERT INTO shares (slug, draw_id, user_id, password, expires_at)
+		SELECT $1, d.id, $3, NULLIF($4, ''),
+			CASE WHEN $5::float8 > 0 THEN current_timestamp + make_interval(secs => $5::float8) END
+		FROM draws d
+		WHERE d.id = $2 AND CASE WHEN $6::integer IS NULL
+			THEN d.user_id = $3 AND d.org_id IS NULL
+			ELSE d.org_id = $6::integer END
+		RETURNING id, expires_at, created_at`
+	return sm.DB.QueryRowContext(ctx, query, s.Slug, s.DrawId, s.UserId, s.Password, ttl.Seconds(), orgId).
+		Scan(&s.Id, &s.ExpiresAt, &s.CreatedAt)
+}
+
+// GetBySlug returns the link with the given slug, revoked or not, or nil if
+// there is none.
+func (sm *ShareModel) GetBySlug(ctx context.Context, slug string) (_ *Share, err error) {
+	ctx, span := startSpan(ctx, "ShareModel.GetBySlug")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	query := `SELECT ` + shareColumns + ` FROM shares WHERE slug = $1`
+	s, err := scanShare(sm.DB.QueryRowContext(ctx, query, slug))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return s, err
+}
+
+// ListByUserId returns the user's links, newest first.
+func (sm *ShareModel) ListByUserId(ctx context.Context, userId int) (_ []*Share, err error) {
+	ctx, span := startSpan(ctx, "ShareModel.ListByUserId")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	query := `SELECT ` + shareColumns + ` FROM shares WHERE user_id = $1 ORDER BY created_at DESC, id DESC`
+	rows, err := sm.DB.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shares := []*Share{}
+	for rows.Next() {
+		s, err := scanShare(rows)
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return shares, nil
+}
+
+// Revoke disables one of the user's links. It returns sql.ErrNoRows if the
+// user has no such link or it is already revoked.
+func (sm *ShareModel) Revoke(ctx context.Context, userId int, slug string) (err error) {
+	ctx, span := startSpan(ctx, "ShareModel.Revoke")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	query := `UPDATE shares SET revoked_at = current_timestamp WHERE slug = $1 AND user_id = $2 AND revoked_at IS NULL`
+	res, err := sm.DB.ExecContext(ctx, query, slug, userId)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+const shareColumns = `id, slug, draw_id, user_id, COALESCE(password, ''), expires_at, revoked_at, created_at,
+	expires_at IS NOT NULL AND expires_at <= current_timestamp`
+
+func scanShare(row interface{ Scan(...any) error }) (*Share, error) {
+	var s Share
+	err := row.Scan(&s.Id, &s.Slug, &s.DrawId, &s.UserId, &s.Password, &s.ExpiresAt, &s.RevokedAt, &s.CreatedAt, &s.Expired)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
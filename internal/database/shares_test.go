@@ -0,0 +1,81 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestShareInsertArgs(t *testing.T) {
+	db, f := newFakeDB(t, func(string, []driver.Value) fakeResult {
+		return fakeResult{columns: []string{"id", "expires_at", "created_at"}, rows: [][]driver.Value{{int64(5), nil, time.Now()}}}
+	})
+	sm := &ShareModel{DB: db}
+
+	orgId := 7
+	tests := []struct {
+		orgId   *int
+		ttl     time.Duration
+		wantTTL float64
+		wantOrg driver.Value
+	}{
+		{nil, 0, 0, nil},
+		{nil, time.Hour, 3600, nil},
+		{&orgId, 90 * time.Second, 90, int64(7)},
+	}
+	for _, tt := range tests {
+		s := Share{Slug: "abc", DrawId: 3, UserId: 1}
+		if err := sm.Insert(context.Background(), &s, tt.orgId, tt.ttl); err != nil {
+			t.Fatal(err)
+		}
+		if s.Id != 5 {
+			t.Errorf("id = %d; want 5 from RETURNING", s.Id)
+		}
+
+		calls := f.queries("INSERT INTO shares")
+		args := calls[len(calls)-1].args
+		if args[4] != tt.wantTTL || args[5] != tt.wantOrg {
+			t.Errorf("Insert(ttl %v, org %v) sent ttl %v, org %v; want %v, %v",
+				tt.ttl, tt.orgId, args[4], args[5], tt.wantTTL, tt.wantOrg)
+		}
+	}
+}
+
+func TestShareGetBySlugNotFound(t *testing.T) {
+	db, _ := newFakeDB(t, func(string, []driver.Value) fakeResult {
+		return fakeResult{columns: []string{"id"}}
+	})
+	sm := &ShareModel{DB: db}
+
+	s, err := sm.GetBySlug(context.Background(), "nope")
+	if s != nil || err != nil {
+		t.Errorf("GetBySlug = %v, %v; want nil, nil", s, err)
+	}
+}
+
+func TestShareRevoke(t *testing.T) {
+	tests := []struct {
+		affected int64
+		want     error
+	}{
+		{1, nil},
+		{0, sql.ErrNoRows},
+	}
+	for _, tt := range tests {
+		db, f := newFakeDB(t, func(string, []driver.Value) fakeResult {
+			return fakeResult{affected: tt.affected}
+		})
+		sm := &ShareModel{DB: db}
+
+		if err := sm.Revoke(context.Background(), 1, "abc"); !errors.Is(err, tt.want) {
+			t.Errorf("Revoke with %d rows affected = %v; want %v", tt.affected, err, tt.want)
+		}
+		calls := f.queries("UPDATE shares")
+		if len(calls) != 1 || calls[0].args[0] != "abc" || calls[0].args[1] != int64(1) {
+			t.Errorf("calls = %+v; want one update of slug abc for user 1", calls)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type StatsStore interface {
+	Get(ctx context.Context) (*Stats, error)
+}
+
+type StatsModel struct {
+	DB *sql.DB
+}
+
+// Stats are system-wide counts for operators.
+type Stats struct {
+	Users             int `json:"users"`
+	DisabledUsers     int `json:"disabled_users"`
+	Admins            int `json:"admins"`
+	UsersLast7Days    int `json:"users_last_7_days"`
+	Draws             int `json:"draws"`
+	DrawsLast24Hours  int `json:"draws_last_24_hours"`
+	Organizations     int `json:"organizations"`
+	ActiveShares      int `json:"active_shares"`
+	Webhooks          int `json:"webhooks"`
+	PendingDeliveries int `json:"pending_deliveries"`
+	FailedDeliveries  int `json:"failed_deliveries"`
+}
+
+var _ StatsStore = (*StatsModel)(nil)
+
+// Get counts users, draws and the other stored resources in one snapshot.
+func (sm *StatsModel) Get(ctx context.Context) (_ *Stats, err error) {
+	ctx, span := startSpan(ctx, "StatsModel.Get")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `SELECT
+		(SELECT count(*) FROM users),
+		(SELECT count(*) FROM users WHERE disabled_at IS NOT NULL),
+		(SELECT count(*) FROM users WHERE role = 'admin'),
+		(SELECT count(*) FROM users WHERE created_at > current_timestamp - interval '7 days'),
+		(SELECT count(*) FROM draws),
+		(SELECT count(*) FROM draws WHERE created_at > current_timestamp - interval '24 hours'),
+		(SELECT count(*) FROM organizations),
+		(SELECT count(*) FROM shares WHERE revoked_at IS NULL AND (expires_at IS NULL OR expires_at > current_timestamp)),
+		(SELECT count(*) FROM webhooks),
+		(SELECT count(*) FROM webhook_deliveries WHERE status = 'pending'),
+		(SELECT count(*) FROM webhook_deliveries WHERE status = 'failed')`
+	var s Stats
+	err = sm.DB.QueryRowContext(ctx, query).Scan(&s.Users, &s.DisabledUsers, &s.Admins, &s.UsersLast7Days,
+		&s.Draws, &s.DrawsLast24Hours, &s.Organizations, &s.ActiveShares,
+		&s.Webhooks, &s.PendingDeliveries, &s.FailedDeliveries)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
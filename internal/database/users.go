@@ -1,17 +1,35 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
-	"context"
+	"github.com/lib/pq"
 )
 
+// ErrDuplicateEmail is returned by Insert when the email is already taken.
+var ErrDuplicateEmail = errors.New("database: duplicate email")
+
+// uniqueViolation is the Postgres error code for a unique constraint
+// violation.
+const uniqueViolation = "23505"
+
 type UserStore interface {
-	Insert(u *User) error
-	Get(id int) (*User, error)
-	GetByEmail(Email string) (*User, error)
-	GetByName(name string) (*User, error)
+	Insert(ctx context.Context, u *User) error
+	Get(ctx context.Context, id int) (*User, error)
+	GetByEmail(ctx context.Context, Email string) (*User, error)
+	GetByName(ctx context.Context, name string) (*User, error)
+	List(ctx context.Context) ([]*User, error)
+	Search(ctx context.Context, f UserFilter) ([]*User, int, error)
+	UpdatePassword(ctx context.Context, id int, password string) error
+	ForcePasswordReset(ctx context.Context, id int, password string) error
+	SetRole(ctx context.Context, id int, role string) error
+	SetDisabled(ctx context.Context, id int, disabled bool) error
+	Delete(ctx context.Context, id int) error
 }
 
 type UserModel struct {
@@ -24,41 +42,308 @@ type User struct {
 	GoogleID string `json:"google_id,omitempty"`
 	Name     string `json:"name"`
 	Password string `json:"-"`
+	// Role is the system-wide role, one of rbac.Roles.
+	Role                  string     `json:"role"`
+	DisabledAt            *time.Time `json:"disabled_at,omitempty"`
+	PasswordResetRequired bool       `json:"password_reset_required,omitempty"`
+	// TokenVersion changes with the password; tokens issued for an
+	// earlier version are refused.
+	TokenVersion int       `json:"-"`
+	CreatedAt    time.Time `json:"created_at"`
 }
 
+// UserFilter selects users for Search. Zero fields match every user.
+type UserFilter struct {
+	// Query matches part of the email or name, ignoring case.
+	Query    string
+	Role     string
+	Disabled *bool
+	Limit    int
+	Offset   int
+}
+
+const userColumns = `id, email, google_id, name, password, role, disabled_at, password_reset_required, token_version, created_at`
+
 var _ UserStore = (*UserModel)(nil)
 
-func (um *UserModel) Insert(u *User) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+func (um *UserModel) Insert(ctx context.Context, u *User) (err error) {
+	ctx, span := startSpan(ctx, "UserModel.Insert")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
 
-	query := `INSERT INTO users (email, google_id, name, password) VALUES ($1, $2, $3, $4) RETURNING id`
+	query := `INSERT INTO users (email, google_id, name, password) VALUES ($1, NULLIF($2, ''), $3, $4)
+		RETURNING id, role, created_at`
 
-	return um.DB.QueryRowContext(ctx, query, u.Email, u.GoogleID, u.Name, u.Password).Scan(&u.Id)
+	err = um.DB.QueryRowContext(ctx, query, u.Email, u.GoogleID, u.Name, u.Password).Scan(&u.Id, &u.Role, &u.CreatedAt)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation && pqErr.Constraint == "users_email_key" {
+		return ErrDuplicateEmail
+	}
+	return err
 }
 
-func (um *UserModel) Get(id int) (*User, error) {
-	query := `SELECT id, email, google_id, name, password FROM users WHERE id = $1`
-	return um.getUser(query, id)
+func (um *UserModel) Get(ctx context.Context, id int) (*User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = $1`
+	return um.getUser(ctx, "UserModel.Get", query, id)
 }
 
-func (um *UserModel) GetByEmail(email string) (*User, error) {
-	query := `SELECT id, email, google_id, name, password FROM users WHERE email = $1`
-	return um.getUser(query, email)
+func (um *UserModel) GetByEmail(ctx context.Context, email string) (*User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE email = $1`
+	return um.getUser(ctx, "UserModel.GetByEmail", query, email)
 }
 
-func (um *UserModel) GetByName(name string) (*User, error) {
-	query := `SELECT id, email, google_id, name, password FROM users WHERE name = $1`
-	return um.getUser(query, name)
+func (um *UserModel) GetByName(ctx context.Context, name string) (*User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE name = $1`
+	return um.getUser(ctx, "UserModel.GetByName", query, name)
 }
 
-func (um *UserModel) getUser(query string, args ...any) (*User, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+func (um *UserModel) List(ctx context.Context) (_ []*User, err error) {
+	ctx, span := startSpan(ctx, "UserModel.List")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
 
-	var u User
-	err := um.DB.QueryRowContext(ctx, query, args...).
-		Scan(&u.Id, &u.Email, &u.Name, &u.Password)
+	query := `SELECT ` + userColumns + ` FROM users ORDER BY id`
+	rows, err := um.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []*User{}
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// Search returns a page of the users matching f, by id, together with how
+// many users match in total.
+func (um *UserModel) Search(ctx context.Context, f UserFilter) (_ []*User, _ int, err error) {
+	ctx, span := startSpan(ctx, "UserModel.Search")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	where := `WHERE ($1::text = '' OR email ILIKE '%' || $1::text || '%' ESCAPE '\' OR name ILIKE '%' || $1::text || '%' ESCAPE '\')
+		AND ($2::text = '' OR role = $2::text)
+		AND ($3::boolean IS NULL OR (disabled_at IS NOT NULL) = $3::boolean)`
+	args := []any{escapeLike(f.Query), f.Role, f.Disabled}
+
+	var total int
+	if err = um.DB.QueryRowContext(ctx, `SELECT count(*) FROM users `+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT ` + userColumns + ` FROM users ` + where + ` ORDER BY id LIMIT $4 OFFSET $5`
+	rows, err := um.DB.QueryContext(ctx, query, append(args, f.Limit, f.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	users := []*User{}
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		users = append(users, u)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return users, total, nil
+}
+
+// UpdatePassword sets a password the user chose, which also satisfies a
+// forced reset. Tokens issued before stop working.
+func (um *UserModel) UpdatePassword(ctx context.Context, id int, password string) (err error) {
+	ctx, span := startSpan(ctx, "UserModel.UpdatePassword")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	query := `UPDATE users SET password = $1, password_reset_required = false,
+		token_version = token_version + 1, updated_at = current_timestamp WHERE id = $2`
+	return um.execOne(ctx, query, password, id)
+}
+
+// ForcePasswordReset sets a temporary password that the user must replace
+// before doing anything else. Tokens issued before stop working.
+func (um *UserModel) ForcePasswordReset(ctx context.Context, id int, password string) (err error) {
+	ctx, span := startSpan(ctx, "UserModel.ForcePasswordReset")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	query := `UPDATE users SET password = $1, password_reset_required = true,
+		token_version = token_version + 1, updated_at = current_timestamp WHERE id = $2`
+	return um.execOne(ctx, query, password, id)
+}
+
+func (um *UserModel) SetRole(ctx context.Context, id int, role string) (err error) {
+	ctx, span := startSpan(ctx, "UserModel.SetRole")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	query := `UPDATE users SET role = $1, updated_at = current_timestamp WHERE id = $2`
+	return um.execOne(ctx, query, role, id)
+}
+
+// SetDisabled disables or re-enables the user. Disabling an already
+// disabled user keeps the original time.
+func (um *UserModel) SetDisabled(ctx context.Context, id int, disabled bool) (err error) {
+	ctx, span := startSpan(ctx, "UserModel.SetDisabled")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	query := `UPDATE users SET
+			disabled_at = CASE WHEN $1::boolean THEN coalesce(disabled_at, current_timestamp) END,
+			updated_at = current_timestamp
+		WHERE id = $2`
+	return um.execOne(ctx, query, disabled, id)
+}
+
+// Delete removes the user with their personal draws. Draws they saved to an
+// organisation stay with it. It returns a *LastOwnerError if the user is the
+// only owner of an organisation, and sql.ErrNoRows if there is no such user.
+func (um *UserModel) Delete(ctx context.Context, id int) (err error) {
+	ctx, span := startSpan(ctx, "UserModel.Delete")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	tx, err := um.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := checkSoleOwned(ctx, tx, id); err != nil {
+		return err
+	}
+
+	// People go with their draws
+	if _, err := tx.ExecContext(ctx, `DELETE FROM draws WHERE user_id = $1 AND org_id IS NULL`, id); err != nil {
+		return fmt.Errorf("failed to delete draws: %w", err)
+	}
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM users WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// checkSoleOwned returns a *LastOwnerError for the first organisation, by
+// name, of which the user is the only owner. Like checkOtherOwners it locks
+// the owners of the user's organisations until tx ends.
+func checkSoleOwned(ctx context.Context, tx *sql.Tx, userId int) error {
+	query := `SELECT m.org_id, o.name, m.user_id
+		FROM organization_members m
+		JOIN organizations o ON o.id = m.org_id
+		WHERE m.role = $2 AND m.org_id IN (
+			SELECT org_id FROM organization_members WHERE user_id = $1 AND role = $2)
+		ORDER BY m.org_id
+		FOR UPDATE OF m`
+	rows, err := tx.QueryContext(ctx, query, userId, RoleOwner)
+	if err != nil {
+		return fmt.Errorf("failed to lock owners: %w", err)
+	}
+	defer rows.Close()
+
+	names := map[int]string{}
+	others := map[int]int{}
+	for rows.Next() {
+		var orgId, ownerId int
+		var name string
+		if err := rows.Scan(&orgId, &name, &ownerId); err != nil {
+			return err
+		}
+		names[orgId] = name
+		if ownerId != userId {
+			others[orgId]++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	var sole *LastOwnerError
+	for orgId, name := range names {
+		if others[orgId] > 0 {
+			continue
+		}
+		if sole == nil || name < sole.OrgName || (name == sole.OrgName && orgId < sole.OrgId) {
+			sole = &LastOwnerError{OrgId: orgId, OrgName: name}
+		}
+	}
+	if sole != nil {
+		return sole
+	}
+	return nil
+}
+
+// execOne runs a statement that must affect exactly one user row and reports
+// sql.ErrNoRows otherwise.
+func (um *UserModel) execOne(ctx context.Context, query string, args ...any) error {
+	res, err := um.DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (um *UserModel) getUser(ctx context.Context, spanName, query string, args ...any) (_ *User, err error) {
+	ctx, span := startSpan(ctx, spanName)
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	u, err := scanUser(um.DB.QueryRowContext(ctx, query, args...))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -66,5 +351,25 @@ func (um *UserModel) getUser(query string, args ...any) (*User, error) {
 		return nil, err
 	}
 
+	return u, nil
+}
+
+func scanUser(row interface{ Scan(...any) error }) (*User, error) {
+	var u User
+	var googleID, password sql.NullString
+
+	err := row.Scan(&u.Id, &u.Email, &googleID, &u.Name, &password, &u.Role, &u.DisabledAt,
+		&u.PasswordResetRequired, &u.TokenVersion, &u.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	u.GoogleID = googleID.String
+	u.Password = password.String
 	return &u, nil
 }
+
+// escapeLike escapes the LIKE wildcards in s so it matches literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,90 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestUserDeleteLastOwner(t *testing.T) {
+	const userId = 3
+
+	tests := []struct {
+		name    string
+		owners  [][]driver.Value // org_id, name, user_id
+		deleted int64
+		want    *LastOwnerError
+		wantErr error
+	}{
+		{
+			name:    "no organisations",
+			deleted: 1,
+		},
+		{
+			name: "organisations with other owners",
+			owners: [][]driver.Value{
+				{int64(1), "Alpha", int64(userId)},
+				{int64(1), "Alpha", int64(4)},
+			},
+			deleted: 1,
+		},
+		{
+			name: "sole owner",
+			owners: [][]driver.Value{
+				{int64(1), "Alpha", int64(userId)},
+				{int64(1), "Alpha", int64(4)},
+				{int64(2), "Zulu", int64(userId)},
+				{int64(5), "Bravo", int64(userId)},
+			},
+			want: &LastOwnerError{OrgId: 5, OrgName: "Bravo"},
+		},
+		{
+			name:    "no such user",
+			wantErr: sql.ErrNoRows,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, f := newFakeDB(t, func(query string, _ []driver.Value) fakeResult {
+				if strings.Contains(query, "FOR UPDATE") {
+					return fakeResult{columns: []string{"org_id", "name", "user_id"}, rows: tt.owners}
+				}
+				if strings.HasPrefix(query, "DELETE FROM users") {
+					return fakeResult{affected: tt.deleted}
+				}
+				return fakeResult{}
+			})
+
+			err := (&UserModel{DB: db}).Delete(context.Background(), userId)
+
+			locks := f.queries("FOR UPDATE")
+			if len(locks) != 1 || locks[0].args[0] != int64(userId) || locks[0].args[1] != RoleOwner {
+				t.Errorf("owner lock = %+v; want one for user %d", locks, userId)
+			}
+
+			if tt.want != nil {
+				var got *LastOwnerError
+				if !errors.As(err, &got) || *got != *tt.want {
+					t.Fatalf("err = %v; want %+v", err, tt.want)
+				}
+				if !errors.Is(err, ErrLastOwner) {
+					t.Errorf("err does not match ErrLastOwner")
+				}
+				if n := len(f.queries("DELETE")); n != 0 {
+					t.Errorf("%d deletes; want none", n)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v; want %v", err, tt.wantErr)
+			}
+			if n := len(f.queries("DELETE FROM users")); n != 1 {
+				t.Errorf("%d user deletes; want 1", n)
+			}
+		})
+	}
+}
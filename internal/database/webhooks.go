@@ -0,0 +1,286 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/Aergiaaa/rollet/internal/webhook"
+	"github.com/lib/pq"
+)
+
+type WebhookStore interface {
+	webhook.Store
+	Insert(ctx context.Context, w *Webhook) error
+	ListByUserId(ctx context.Context, userId int) ([]*Webhook, error)
+	Delete(ctx context.Context, userId, id int) error
+	Enqueue(ctx context.Context, userId int, event string, payload []byte) (int, error)
+	ListDeliveries(ctx context.Context, userId, webhookId, limit int) ([]*WebhookDelivery, error)
+	Redeliver(ctx context.Context, userId, webhookId, deliveryId int) (*WebhookDelivery, error)
+}
+
+type WebhookModel struct {
+	DB *sql.DB
+}
+
+// Webhook is a user's subscription to events. Secret signs the payloads.
+type Webhook struct {
+	Id        int       `json:"id"`
+	UserId    int       `json:"-"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"-"`
+	Events    []string  `json:"events"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WebhookDelivery is one entry of a webhook's delivery log.
+type WebhookDelivery struct {
+	Id             int             `json:"id"`
+	WebhookId      int             `json:"webhook_id"`
+	Event          string          `json:"event"`
+	Payload        json.RawMessage `json:"payload" swaggertype:"object"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	ResponseStatus *int            `json:"response_status,omitempty"`
+	Error          *string         `json:"error,omitempty"`
+	DurationMs     *int            `json:"duration_ms,omitempty"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty"`
+	LastAttemptAt  *time.Time      `json:"last_attempt_at,omitempty"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+var _ WebhookStore = (*WebhookModel)(nil)
+
+func (wm *WebhookModel) Insert(ctx context.Context, w *Webhook) (err error) {
+	ctx, span := startSpan(ctx, "WebhookModel.Insert")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	query := `INSERT INTO webhooks (user_id, url, secret, events) VALUES ($1, $2, $3, $4) RETURNING id, created_at`
+	return wm.DB.QueryRowContext(ctx, query, w.UserId, w.URL, w.Secret, pq.Array(w.Events)).
+		Scan(&w.Id, &w.CreatedAt)
+}
+
+// ListByUserId returns the user's webhooks, oldest first.
+func (wm *WebhookModel) ListByUserId(ctx context.Context, userId int) (_ []*Webhook, err error) {
+	ctx, span := startSpan(ctx, "WebhookModel.ListByUserId")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	query := `SELECT id, user_id, url, secret, events, created_at FROM webhooks WHERE user_id = $1 ORDER BY id`
+	rows, err := wm.DB.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []*Webhook{}
+	for rows.Next() {
+		var w Webhook
+		err := rows.Scan(&w.Id, &w.UserId, &w.URL, &w.Secret, pq.Array(&w.Events), &w.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, &w)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+// Delete removes one of the user's webhooks with its delivery log. It
+// returns sql.ErrNoRows if the user has no such webhook.
+func (wm *WebhookModel) Delete(ctx context.Context, userId, id int) (err error) {
+	ctx, span := startSpan(ctx, "WebhookModel.Delete")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	res, err := wm.DB.ExecContext(ctx, `DELETE FROM webhooks WHERE id = $1 AND user_id = $2`, id, userId)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// Enqueue queues payload for every webhook of the user subscribed to event
+// and returns how many deliveries were queued.
+func (wm *WebhookModel) Enqueue(ctx context.Context, userId int, event string, payload []byte) (_ int, err error) {
+	ctx, span := startSpan(ctx, "WebhookModel.Enqueue")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	query := `INSERT INTO webhook_deliveries (webhook_id, event, payload)
+		SELECT id, $2::text, $3 FROM webhooks WHERE user_id = $1 AND $2::text = ANY(events)`
+	res, err := wm.DB.ExecContext(ctx, query, userId, event, string(payload))
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// ListDeliveries returns the latest deliveries of one of the user's
+// webhooks, newest first. It returns sql.ErrNoRows if the user has no such
+// webhook.
+func (wm *WebhookModel) ListDeliveries(ctx context.Context, userId, webhookId, limit int) (_ []*WebhookDelivery, err error) {
+	ctx, span := startSpan(ctx, "WebhookModel.ListDeliveries")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var exists bool
+	err = wm.DB.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM webhooks WHERE id = $1 AND user_id = $2)`, webhookId, userId).
+		Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, sql.ErrNoRows
+	}
+
+	query := `SELECT ` + deliveryColumns + ` FROM webhook_deliveries
+		WHERE webhook_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2`
+	rows, err := wm.DB.QueryContext(ctx, query, webhookId, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []*WebhookDelivery{}
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// Redeliver queues a new delivery with the payload of an earlier one, so
+// the log keeps both. It returns sql.ErrNoRows if the user has no such
+// delivery.
+func (wm *WebhookModel) Redeliver(ctx context.Context, userId, webhookId, deliveryId int) (_ *WebhookDelivery, err error) {
+	ctx, span := startSpan(ctx, "WebhookModel.Redeliver")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	query := `INSERT INTO webhook_deliveries (webhook_id, event, payload)
+		SELECT d.webhook_id, d.event, d.payload
+		FROM webhook_deliveries d
+		JOIN webhooks w ON w.id = d.webhook_id
+		WHERE d.id = $1 AND d.webhook_id = $2 AND w.user_id = $3
+		RETURNING ` + deliveryColumns
+	return scanDelivery(wm.DB.QueryRowContext(ctx, query, deliveryId, webhookId, userId))
+}
+
+// Claim implements webhook.Store. Rows locked by another worker's claim
+// are skipped.
+func (wm *WebhookModel) Claim(ctx context.Context, limit int, lease time.Duration) (_ []webhook.Delivery, err error) {
+	ctx, span := startSpan(ctx, "WebhookModel.Claim")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `UPDATE webhook_deliveries d
+		SET next_attempt_at = current_timestamp + make_interval(secs => $2::float8)
+		FROM webhooks w
+		WHERE w.id = d.webhook_id AND d.id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= current_timestamp
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED)
+		RETURNING d.id, w.url, w.secret, d.event, d.payload, d.attempts`
+	rows, err := wm.DB.QueryContext(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []webhook.Delivery
+	for rows.Next() {
+		var d webhook.Delivery
+		if err := rows.Scan(&d.ID, &d.URL, &d.Secret, &d.Event, &d.Payload, &d.Attempts); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// Finish implements webhook.Store.
+func (wm *WebhookModel) Finish(ctx context.Context, id int, a webhook.Attempt, status string, retryIn time.Duration) (err error) {
+	ctx, span := startSpan(ctx, "WebhookModel.Finish")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	query := `UPDATE webhook_deliveries SET
+			status = $2::text,
+			attempts = attempts + 1,
+			response_status = NULLIF($3, 0),
+			error = NULLIF($4, ''),
+			duration_ms = $5,
+			last_attempt_at = current_timestamp,
+			next_attempt_at = CASE WHEN $2::text = 'pending' THEN current_timestamp + make_interval(secs => $6::float8) END,
+			delivered_at = CASE WHEN $2::text = 'succeeded' THEN current_timestamp END
+		WHERE id = $1`
+	_, err = wm.DB.ExecContext(ctx, query, id, status, a.StatusCode, a.Error, a.Duration.Milliseconds(), retryIn.Seconds())
+	return err
+}
+
+const deliveryColumns = `id, webhook_id, event, payload, status, attempts, response_status, error, duration_ms,
+	next_attempt_at, last_attempt_at, delivered_at, created_at`
+
+func scanDelivery(row interface{ Scan(...any) error }) (*WebhookDelivery, error) {
+	var d WebhookDelivery
+	var payload []byte
+	err := row.Scan(&d.Id, &d.WebhookId, &d.Event, &payload, &d.Status, &d.Attempts, &d.ResponseStatus,
+		&d.Error, &d.DurationMs, &d.NextAttemptAt, &d.LastAttemptAt, &d.DeliveredAt, &d.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	d.Payload = payload
+	return &d, nil
+}
@@ -0,0 +1,388 @@
+package env
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Source supplies raw configuration values by key.
+type Source interface {
+	// Lookup returns the value for key and whether it was set.
+	Lookup(key string) (string, bool, error)
+}
+
+// Environ reads values from environment variables. When KEY is not set but
+// KEY_FILE is, the value is read from that file instead, which is how
+// container platforms usually hand out secrets.
+func Environ() Source {
+	return environ{}
+}
+
+type environ struct{}
+
+func (environ) Lookup(key string) (string, bool, error) {
+	if v, ok := os.LookupEnv(key); ok {
+		return v, true, nil
+	}
+
+	path, ok := os.LookupEnv(key + "_FILE")
+	if !ok {
+		return "", false, nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", false, fmt.Errorf("%s_FILE: %w", key, err)
+	}
+
+	return strings.TrimRight(string(b), "\r\n"), true, nil
+}
+
+// Map serves values from a fixed map, for example values given on the
+// command line.
+type Map map[string]string
+
+func (m Map) Lookup(key string) (string, bool, error) {
+	v, ok := m[key]
+	return v, ok, nil
+}
+
+// Validator is implemented by configuration structs that check their
+// fields once every value has been loaded.
+type Validator interface {
+	Validate() error
+}
+
+// Load fills the struct pointed to by cfg. Each field with an `env` tag is
+// looked up in sources, later sources taking precedence; with no sources
+// the environment is used. Further tags control each field:
+//
+//	default:"8080"   value used when no source sets the key
+//	required:"true"  fail when neither a source nor a default sets the key
+//	secret:"true"    never include the value in errors or output
+//	usage:"..."      help text for the flag registered by Flags
+//
+// Supported field types are string, bool, int, int64, float64,
+// time.Duration, *url.URL, []string (comma-separated) and pointers to the
+// scalar types, which stay nil when unset. Nested structs are walked.
+//
+// Load reports every problem at once and then calls Validate if cfg
+// implements Validator.
+func Load(cfg any, sources ...Source) error {
+	if len(sources) == 0 {
+		sources = []Source{Environ()}
+	}
+
+	fields, err := fieldsOf(cfg)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	errs = append(errs, unknownKeys(fields, sources)...)
+
+	for _, f := range fields {
+		raw, ok, err := lookup(f.Key, sources)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !ok {
+			raw, ok = f.Default, f.HasDefault
+		}
+		if !ok {
+			if f.Required {
+				errs = append(errs, fmt.Errorf("%s is required", f.Key))
+			}
+			continue
+		}
+
+		if err := setValue(f.value, raw); err != nil {
+			if f.Secret {
+				errs = append(errs, fmt.Errorf("%s: invalid %s value", f.Key, f.value.Type()))
+			} else {
+				errs = append(errs, fmt.Errorf("%s: invalid value %q: %w", f.Key, raw, err))
+			}
+			continue
+		}
+		if f.Required && f.value.IsZero() {
+			errs = append(errs, fmt.Errorf("%s must not be empty", f.Key))
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	if v, ok := cfg.(Validator); ok {
+		return v.Validate()
+	}
+
+	return nil
+}
+
+func lookup(key string, sources []Source) (string, bool, error) {
+	for i := len(sources) - 1; i >= 0; i-- {
+		v, ok, err := sources[i].Lookup(key)
+		if err != nil || ok {
+			return v, ok, err
+		}
+	}
+
+	return "", false, nil
+}
+
+// unknownKeys reports keys offered by sources that list their keys, such as
+// config files, which match no field.
+func unknownKeys(fields []field, sources []Source) []error {
+	known := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		known[f.Key] = true
+	}
+
+	var errs []error
+	for _, src := range sources {
+		lister, ok := src.(interface{ Keys() []string })
+		if !ok {
+			continue
+		}
+		for _, key := range lister.Keys() {
+			if !known[key] {
+				errs = append(errs, fmt.Errorf("unknown configuration key %s in %v", key, src))
+			}
+		}
+	}
+
+	return errs
+}
+
+// Flags registers a flag on fs for every field of cfg, named after its key
+// in lower case with dashes, so DATABASE_URL becomes -database-url. The
+// returned source reports only the flags set on the command line; pass it
+// last to Load so flags override everything else.
+func Flags(fs *flag.FlagSet, cfg any) (Source, error) {
+	fields, err := fieldsOf(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]string, len(fields))
+	for _, f := range fields {
+		name := strings.ToLower(strings.ReplaceAll(f.Key, "_", "-"))
+		names[f.Key] = name
+
+		usage := f.Usage
+		if f.HasDefault && f.Default != "" && !f.Secret {
+			usage += fmt.Sprintf(" (default %q)", f.Default)
+		}
+		fs.String(name, "", strings.TrimSpace(usage+" (env "+f.Key+")"))
+	}
+
+	return flagSource{fs: fs, names: names}, nil
+}
+
+type flagSource struct {
+	fs    *flag.FlagSet
+	names map[string]string
+}
+
+func (s flagSource) Lookup(key string) (string, bool, error) {
+	name, ok := s.names[key]
+	if !ok {
+		return "", false, nil
+	}
+
+	set := false
+	s.fs.Visit(func(f *flag.Flag) {
+		set = set || f.Name == name
+	})
+	if !set {
+		return "", false, nil
+	}
+
+	return s.fs.Lookup(name).Value.String(), true, nil
+}
+
+// Print writes the current values of cfg as KEY=value lines in declaration
+// order. Secret values that are set are printed as [redacted].
+func Print(w io.Writer, cfg any) error {
+	fields, err := fieldsOf(cfg)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range fields {
+		v := formatValue(f.value)
+		if f.Secret && v != "" {
+			v = "[redacted]"
+		}
+		if _, err := fmt.Fprintf(w, "%s=%s\n", f.Key, v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// field is one configuration field of a struct passed to Load.
+type field struct {
+	Key        string
+	Default    string
+	HasDefault bool
+	Required   bool
+	Secret     bool
+	Usage      string
+
+	value reflect.Value
+}
+
+var (
+	durationType = reflect.TypeFor[time.Duration]()
+	urlType      = reflect.TypeFor[*url.URL]()
+)
+
+func fieldsOf(cfg any) ([]field, error) {
+	v := reflect.ValueOf(cfg)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("env: config must be a pointer to a struct, got %T", cfg)
+	}
+
+	var fields []field
+	var walk func(v reflect.Value)
+	walk = func(v reflect.Value) {
+		t := v.Type()
+		for i := range t.NumField() {
+			sf := t.Field(i)
+			if !sf.IsExported() && !sf.Anonymous {
+				continue
+			}
+
+			key, ok := sf.Tag.Lookup("env")
+			if !ok {
+				if sf.Type.Kind() == reflect.Struct {
+					walk(v.Field(i))
+				}
+				continue
+			}
+
+			def, hasDef := sf.Tag.Lookup("default")
+			fields = append(fields, field{
+				Key:        key,
+				Default:    def,
+				HasDefault: hasDef,
+				Required:   sf.Tag.Get("required") == "true",
+				Secret:     sf.Tag.Get("secret") == "true",
+				Usage:      sf.Tag.Get("usage"),
+				value:      v.Field(i),
+			})
+		}
+	}
+	walk(v.Elem())
+
+	return fields, nil
+}
+
+func setValue(v reflect.Value, raw string) error {
+	switch v.Type() {
+	case durationType:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	case urlType:
+		if raw == "" {
+			v.SetZero()
+			return nil
+		}
+		u, err := url.Parse(raw)
+		if err != nil {
+			return err
+		}
+		if u.Scheme == "" || u.Host == "" {
+			return errors.New("URL must be absolute")
+		}
+		v.Set(reflect.ValueOf(u))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", v.Type())
+		}
+		var items []string
+		for item := range strings.SplitSeq(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	case reflect.Pointer:
+		if raw == "" {
+			v.SetZero()
+			return nil
+		}
+		p := reflect.New(v.Type().Elem())
+		if err := setValue(p.Elem(), raw); err != nil {
+			return err
+		}
+		v.Set(p)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+
+	return nil
+}
+
+func formatValue(v reflect.Value) string {
+	switch v.Type() {
+	case durationType:
+		return time.Duration(v.Int()).String()
+	case urlType:
+		if v.IsNil() {
+			return ""
+		}
+		return v.Interface().(*url.URL).String()
+	}
+
+	switch v.Kind() {
+	case reflect.Slice:
+		return strings.Join(v.Interface().([]string), ",")
+	case reflect.Pointer:
+		if v.IsNil() {
+			return ""
+		}
+		return formatValue(v.Elem())
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
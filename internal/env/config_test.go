@@ -0,0 +1,182 @@
+package env
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testConfig struct {
+	Name     string        `env:"TEST_CFG_NAME" default:"rollet"`
+	Port     int           `env:"TEST_CFG_PORT" default:"8080"`
+	Debug    bool          `env:"TEST_CFG_DEBUG"`
+	Timeout  time.Duration `env:"TEST_CFG_TIMEOUT" default:"5s"`
+	Endpoint *url.URL      `env:"TEST_CFG_ENDPOINT"`
+	Origins  []string      `env:"TEST_CFG_ORIGINS"`
+	Seed     *int64        `env:"TEST_CFG_SEED"`
+	Secret   string        `env:"TEST_CFG_SECRET" required:"true" secret:"true"`
+
+	nested
+}
+
+type nested struct {
+	Ratio float64 `env:"TEST_CFG_RATIO" default:"0.5"`
+}
+
+func (c *testConfig) Validate() error {
+	if c.Port == 1 {
+		return errors.New("port 1 is reserved")
+	}
+	return nil
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	secretFile := filepath.Join(dir, "secret")
+	if err := os.WriteFile(secretFile, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		check   func(t *testing.T, cfg *testConfig)
+		wantErr string
+	}{
+		{
+			name: "applies defaults",
+			env:  map[string]string{"TEST_CFG_SECRET": "s3cret"},
+			check: func(t *testing.T, cfg *testConfig) {
+				if cfg.Name != "rollet" || cfg.Port != 8080 || cfg.Timeout != 5*time.Second || cfg.Ratio != 0.5 {
+					t.Errorf("defaults not applied: %+v", cfg)
+				}
+				if cfg.Endpoint != nil || cfg.Origins != nil || cfg.Seed != nil {
+					t.Errorf("unset optional fields should stay zero: %+v", cfg)
+				}
+			},
+		},
+		{
+			name: "parses every supported type",
+			env: map[string]string{
+				"TEST_CFG_SECRET":   "s3cret",
+				"TEST_CFG_PORT":     "9090",
+				"TEST_CFG_DEBUG":    "true",
+				"TEST_CFG_TIMEOUT":  "1m30s",
+				"TEST_CFG_ENDPOINT": "https://example.com/hook",
+				"TEST_CFG_ORIGINS":  "https://a.example, https://b.example,",
+				"TEST_CFG_SEED":     "42",
+				"TEST_CFG_RATIO":    "0.25",
+			},
+			check: func(t *testing.T, cfg *testConfig) {
+				if cfg.Port != 9090 || !cfg.Debug || cfg.Timeout != 90*time.Second || cfg.Ratio != 0.25 {
+					t.Errorf("scalars not parsed: %+v", cfg)
+				}
+				if cfg.Endpoint == nil || cfg.Endpoint.Host != "example.com" {
+					t.Errorf("Endpoint = %v; want host example.com", cfg.Endpoint)
+				}
+				if strings.Join(cfg.Origins, "|") != "https://a.example|https://b.example" {
+					t.Errorf("Origins = %q", cfg.Origins)
+				}
+				if cfg.Seed == nil || *cfg.Seed != 42 {
+					t.Errorf("Seed = %v; want 42", cfg.Seed)
+				}
+			},
+		},
+		{
+			name: "reads value from _FILE",
+			env:  map[string]string{"TEST_CFG_SECRET_FILE": secretFile},
+			check: func(t *testing.T, cfg *testConfig) {
+				if cfg.Secret != "from-file" {
+					t.Errorf("Secret = %q; want %q", cfg.Secret, "from-file")
+				}
+			},
+		},
+		{
+			name:    "reports missing required field",
+			env:     map[string]string{},
+			wantErr: "TEST_CFG_SECRET is required",
+		},
+		{
+			name:    "reports unreadable _FILE",
+			env:     map[string]string{"TEST_CFG_SECRET_FILE": filepath.Join(dir, "missing")},
+			wantErr: "TEST_CFG_SECRET_FILE",
+		},
+		{
+			name:    "reports invalid values",
+			env:     map[string]string{"TEST_CFG_SECRET": "s3cret", "TEST_CFG_PORT": "eighty", "TEST_CFG_ENDPOINT": "not a url"},
+			wantErr: `TEST_CFG_PORT: invalid value "eighty"`,
+		},
+		{
+			name:    "runs Validate",
+			env:     map[string]string{"TEST_CFG_SECRET": "s3cret", "TEST_CFG_PORT": "1"},
+			wantErr: "port 1 is reserved",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			var cfg testConfig
+			err := Load(&cfg)
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Load() error = %v; want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			tt.check(t, &cfg)
+		})
+	}
+}
+
+func TestLoadNeverLeaksSecrets(t *testing.T) {
+	type secretInt struct {
+		Key int `env:"TEST_CFG_SECRET_INT" secret:"true"`
+	}
+	t.Setenv("TEST_CFG_SECRET_INT", "hunter2")
+
+	var cfg secretInt
+	err := Load(&cfg)
+	if err == nil {
+		t.Fatal("Load() error = nil; want parse error")
+	}
+	if strings.Contains(err.Error(), "hunter2") {
+		t.Errorf("error %q leaks the secret value", err)
+	}
+}
+
+func TestLoadSourcePrecedence(t *testing.T) {
+	t.Setenv("TEST_CFG_SECRET", "from-env")
+	t.Setenv("TEST_CFG_PORT", "7000")
+
+	var cfg testConfig
+	err := Load(&cfg, Environ(), Map{"TEST_CFG_PORT": "7001"})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if cfg.Port != 7001 {
+		t.Errorf("Port = %d; want the later source to win with 7001", cfg.Port)
+	}
+	if cfg.Secret != "from-env" {
+		t.Errorf("Secret = %q; want earlier source used when later one lacks the key", cfg.Secret)
+	}
+}
+
+func TestLoadRejectsNonStruct(t *testing.T) {
+	var n int
+	if err := Load(&n); err == nil {
+		t.Error("Load(*int) error = nil; want error")
+	}
+}
@@ -1,7 +1,7 @@
 package env
 
 import (
-	"log"
+	"log/slog"
 	"os"
 	"strconv"
 )
@@ -9,14 +9,12 @@ import (
 func GetEnvInt(key string, defaultValue int) int {
 	envStr, ok := os.LookupEnv(key)
 	if !ok {
-		log.Printf("Environment variable %s not set, using default value: %d", key, defaultValue)
+		slog.Info("Environment variable not set, using default value", "key", key)
 		return defaultValue
 	}
 	envInt, err := strconv.Atoi(envStr)
 	if err != nil {
-		log.Printf(
-			"Error converting environment variable %s to int: %v, using default value: %d",
-			key, err, defaultValue)
+		slog.Warn("Environment variable is not an int, using default value", "key", key)
 		return defaultValue
 	}
 
@@ -26,7 +24,7 @@ func GetEnvInt(key string, defaultValue int) int {
 func GetEnvString(key, defaultValue string) string {
 	env, ok := os.LookupEnv(key)
 	if !ok {
-		log.Printf("Environment variable %s not set, using default value: %s", key, defaultValue)
+		slog.Info("Environment variable not set, using default value", "key", key)
 		return defaultValue
 	}
 
@@ -0,0 +1,93 @@
+package env
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/pelletier/go-toml/v2"
+)
+
+// File reads a YAML (.yaml, .yml) or TOML (.toml) configuration file.
+// Nested tables are flattened by joining their keys with underscores and
+// upper-casing them, so
+//
+//	database:
+//	  url: postgres://...
+//
+// and `database_url: postgres://...` both set DATABASE_URL. Lists become
+// comma-separated values.
+func File(path string) (Source, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read config file: %w", err)
+	}
+
+	var raw map[string]any
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &raw)
+	case ".toml":
+		err = toml.Unmarshal(b, &raw)
+	default:
+		return nil, fmt.Errorf("unsupported config file %q: use .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not parse config file %s: %w", path, err)
+	}
+
+	values := fileSource{path: path, values: map[string]string{}}
+	flatten("", raw, values.values)
+	return values, nil
+}
+
+type fileSource struct {
+	path   string
+	values map[string]string
+}
+
+func (fs fileSource) Lookup(key string) (string, bool, error) {
+	v, ok := fs.values[key]
+	return v, ok, nil
+}
+
+// Keys lets Load reject keys that match no configuration field, which are
+// almost always typos.
+func (fs fileSource) Keys() []string {
+	keys := make([]string, 0, len(fs.values))
+	for k := range fs.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (fs fileSource) String() string {
+	return fs.path
+}
+
+func flatten(prefix string, v any, out map[string]string) {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, child := range v {
+			key := strings.ToUpper(strings.ReplaceAll(k, "-", "_"))
+			if prefix != "" {
+				key = prefix + "_" + key
+			}
+			flatten(key, child, out)
+		}
+	case []any:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = fmt.Sprint(item)
+		}
+		out[prefix] = strings.Join(items, ",")
+	case nil:
+		out[prefix] = ""
+	default:
+		out[prefix] = fmt.Sprint(v)
+	}
+}
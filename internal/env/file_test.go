@@ -0,0 +1,142 @@
+package env
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{
+			name: "yaml",
+			file: "rollet.yaml",
+			content: `
+test_cfg_port: 9000
+test_cfg:
+  secret: from-file
+  origins: [https://a.example, https://b.example]
+`,
+		},
+		{
+			name: "toml",
+			file: "rollet.toml",
+			content: `
+test-cfg-port = 9000
+
+[test_cfg]
+secret = "from-file"
+origins = ["https://a.example", "https://b.example"]
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, err := File(writeFile(t, tt.file, tt.content))
+			if err != nil {
+				t.Fatalf("File() error = %v", err)
+			}
+
+			var cfg testConfig
+			if err := Load(&cfg, src); err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+
+			if cfg.Port != 9000 || cfg.Secret != "from-file" {
+				t.Errorf("got Port=%d Secret=%q; want 9000 and from-file", cfg.Port, cfg.Secret)
+			}
+			if strings.Join(cfg.Origins, ",") != "https://a.example,https://b.example" {
+				t.Errorf("Origins = %q", cfg.Origins)
+			}
+		})
+	}
+}
+
+func TestFileRejectsUnknownKeys(t *testing.T) {
+	src, err := File(writeFile(t, "rollet.yaml", "test_cfg_secret: x\ntest_cfg_prot: 80\n"))
+	if err != nil {
+		t.Fatalf("File() error = %v", err)
+	}
+
+	var cfg testConfig
+	err = Load(&cfg, src)
+	if err == nil || !strings.Contains(err.Error(), "TEST_CFG_PROT") {
+		t.Errorf("Load() error = %v; want unknown key TEST_CFG_PROT", err)
+	}
+}
+
+func TestFileRejectsUnsupportedExtension(t *testing.T) {
+	if _, err := File(writeFile(t, "rollet.ini", "port=1")); err == nil {
+		t.Error("File(.ini) error = nil; want error")
+	}
+}
+
+func TestLayerPrecedence(t *testing.T) {
+	file, err := File(writeFile(t, "rollet.yaml", "test_cfg_secret: file\ntest_cfg_port: 1000\ntest_cfg_name: file\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_CFG_PORT", "2000")
+	t.Setenv("TEST_CFG_NAME", "env")
+
+	var cfg testConfig
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags, err := Flags(fs, &cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Parse([]string{"-test-cfg-name", "flag"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Load(&cfg, file, Environ(), flags); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if cfg.Secret != "file" || cfg.Port != 2000 || cfg.Name != "flag" {
+		t.Errorf("got Secret=%q Port=%d Name=%q; want file, 2000, flag", cfg.Secret, cfg.Port, cfg.Name)
+	}
+}
+
+func TestPrintRedactsSecrets(t *testing.T) {
+	t.Setenv("TEST_CFG_SECRET", "hunter2")
+	t.Setenv("TEST_CFG_ORIGINS", "a,b")
+
+	var cfg testConfig
+	if err := Load(&cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := Print(&buf, &cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	if strings.Contains(out, "hunter2") {
+		t.Errorf("Print output leaks the secret:\n%s", out)
+	}
+	for _, want := range []string{"TEST_CFG_SECRET=[redacted]", "TEST_CFG_PORT=8080", "TEST_CFG_ORIGINS=a,b", "TEST_CFG_TIMEOUT=5s"} {
+		if !strings.Contains(out, want+"\n") {
+			t.Errorf("Print output missing %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+// Package export renders draw results as CSV, XLSX, Markdown and PDF
+// documents for printing and sharing.
+package export
+
+import (
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// Member is a person on a team.
+type Member struct {
+	Name       string
+	Role       string
+	Attributes map[string]string
+}
+
+// Team is one team of a draw.
+type Team struct {
+	Number  int
+	Members []Member
+}
+
+// Name is the display name of the team.
+func (t Team) Name() string {
+	return "Team " + strconv.Itoa(t.Number)
+}
+
+// Draw is one draw to export. ID and CreatedAt are zero for draws that
+// were not saved.
+type Draw struct {
+	ID        int
+	CreatedAt time.Time
+	Source    string
+	Teams     []Team
+}
+
+// Format is a document format draws can be exported to.
+type Format struct {
+	// Name is the value of the ?format= parameter.
+	Name        string
+	ContentType string
+	Extension   string
+	write       func(w io.Writer, draws []Draw) error
+}
+
+// Write renders draws to w.
+func (f Format) Write(w io.Writer, draws []Draw) error {
+	return f.write(w, draws)
+}
+
+var (
+	CSV      = Format{Name: "csv", ContentType: "text/csv; charset=utf-8", Extension: ".csv", write: writeCSV}
+	XLSX     = Format{Name: "xlsx", ContentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", Extension: ".xlsx", write: writeXLSX}
+	Markdown = Format{Name: "md", ContentType: "text/markdown; charset=utf-8", Extension: ".md", write: writeMarkdown}
+	PDF      = Format{Name: "pdf", ContentType: "application/pdf", Extension: ".pdf", write: writePDF}
+)
+
+// Formats lists every format, in order of preference for content
+// negotiation.
+var Formats = []Format{CSV, XLSX, Markdown, PDF}
+
+// Lookup returns the format with the given name.
+func Lookup(name string) (Format, bool) {
+	for _, f := range Formats {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return Format{}, false
+}
+
+// attributeKeys returns the attribute names used by any member, sorted, so
+// every format lays them out as the same columns.
+func attributeKeys(draws []Draw) []string {
+	keys := make(map[string]bool)
+	for _, d := range draws {
+		for _, t := range d.Teams {
+			for _, m := range t.Members {
+				for k := range m.Attributes {
+					keys[k] = true
+				}
+			}
+		}
+	}
+	return slices.Sorted(maps.Keys(keys))
+}
+
+// saved reports whether any draw was saved, in which case tables get draw
+// ID and creation time columns.
+func saved(draws []Draw) bool {
+	return slices.ContainsFunc(draws, func(d Draw) bool { return d.ID != 0 })
+}
+
+// table lays the draws out as one row per member. Draw IDs and team
+// numbers are ints, everything else strings.
+func table(draws []Draw) (header []string, rows [][]any) {
+	withDraw := saved(draws)
+	keys := attributeKeys(draws)
+
+	if withDraw {
+		header = append(header, "draw_id", "created_at")
+	}
+	header = append(header, "team", "name", "role")
+	header = append(header, keys...)
+
+	for _, d := range draws {
+		for _, t := range d.Teams {
+			for _, m := range t.Members {
+				var row []any
+				if withDraw {
+					row = append(row, d.ID, d.CreatedAt.UTC().Format(time.RFC3339))
+				}
+				row = append(row, t.Number, m.Name, m.Role)
+				for _, k := range keys {
+					row = append(row, m.Attributes[k])
+				}
+				rows = append(rows, row)
+			}
+		}
+	}
+
+	return header, rows
+}
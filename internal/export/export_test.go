@@ -0,0 +1,150 @@
+package export
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+var unsaved = []Draw{{
+	Source: "crypto",
+	Teams: []Team{
+		{Number: 1, Members: []Member{
+			{Name: "Ann", Role: "GK", Attributes: map[string]string{"shirt": "M"}},
+			{Name: "=cmd|x", Role: "DF"},
+		}},
+		{Number: 2, Members: []Member{
+			{Name: "Bob", Role: "MF"},
+		}},
+	},
+}}
+
+var history = []Draw{
+	{ID: 7, CreatedAt: time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC), Source: "seeded", Teams: []Team{
+		{Number: 1, Members: []Member{{Name: "Cid", Role: "FW"}}},
+	}},
+	{ID: 6, CreatedAt: time.Date(2025, 2, 1, 12, 0, 0, 0, time.UTC), Source: "crypto", Teams: []Team{
+		{Number: 1, Members: []Member{{Name: "Dee", Role: "GK"}}},
+		{Number: 2, Members: []Member{{Name: "Eve", Role: "DF"}}},
+	}},
+}
+
+func render(t *testing.T, f Format, draws []Draw) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := f.Write(&buf, draws); err != nil {
+		t.Fatalf("%s: %v", f.Name, err)
+	}
+	return buf.Bytes()
+}
+
+func TestCSV(t *testing.T) {
+	got := string(render(t, CSV, unsaved))
+	want := "team,name,role,shirt\n" +
+		"1,Ann,GK,M\n" +
+		"1,'=cmd|x,DF,\n" +
+		"2,Bob,MF,\n"
+	if got != want {
+		t.Errorf("CSV =\n%s\nwant\n%s", got, want)
+	}
+
+	got = string(render(t, CSV, history))
+	want = "draw_id,created_at,team,name,role\n" +
+		"7,2025-03-01T12:00:00Z,1,Cid,FW\n" +
+		"6,2025-02-01T12:00:00Z,1,Dee,GK\n" +
+		"6,2025-02-01T12:00:00Z,2,Eve,DF\n"
+	if got != want {
+		t.Errorf("history CSV =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	got := string(render(t, Markdown, unsaved))
+	want := "# Team 1\n\n" +
+		"| Name | Role | shirt |\n" +
+		"| --- | --- | --- |\n" +
+		"| Ann | GK | M |\n" +
+		"| =cmd\\|x | DF |  |\n" +
+		"\n# Team 2\n\n" +
+		"| Name | Role | shirt |\n" +
+		"| --- | --- | --- |\n" +
+		"| Bob | MF |  |\n"
+	if got != want {
+		t.Errorf("Markdown =\n%s\nwant\n%s", got, want)
+	}
+
+	got = string(render(t, Markdown, history))
+	if !regexp.MustCompile(`(?s)^# Draw 7\n\n.*## Team 1.*# Draw 6\n\n.*## Team 2`).MatchString(got) {
+		t.Errorf("history Markdown lacks draw and team headings:\n%s", got)
+	}
+}
+
+func TestXLSX(t *testing.T) {
+	f, err := excelize.OpenReader(bytes.NewReader(render(t, XLSX, unsaved)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	rows, err := f.GetRows(xlsxSheet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"team", "name", "role", "shirt"},
+		{"1", "Ann", "GK", "M"},
+		{"1", "=cmd|x", "DF"},
+		{"2", "Bob", "MF"},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("got %d rows; want %d: %v", len(rows), len(want), rows)
+	}
+	for i := range want {
+		if len(rows[i]) != len(want[i]) {
+			t.Errorf("row %d = %v; want %v", i, rows[i], want[i])
+			continue
+		}
+		for j := range want[i] {
+			if rows[i][j] != want[i][j] {
+				t.Errorf("row %d = %v; want %v", i, rows[i], want[i])
+				break
+			}
+		}
+	}
+}
+
+var pdfPage = regexp.MustCompile(`/Type /Page\b[^s]`)
+
+func TestPDFHasOnePagePerTeam(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		draws []Draw
+		pages int
+	}{
+		{"unsaved", unsaved, 2},
+		{"history", history, 3},
+		{"empty", nil, 1},
+	} {
+		out := render(t, PDF, tt.draws)
+		if !bytes.HasPrefix(out, []byte("%PDF-")) {
+			t.Errorf("%s: output is not a PDF", tt.name)
+		}
+		if got := len(pdfPage.FindAll(out, -1)); got != tt.pages {
+			t.Errorf("%s: %d pages; want %d", tt.name, got, tt.pages)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	for _, f := range Formats {
+		if got, ok := Lookup(f.Name); !ok || got.Name != f.Name {
+			t.Errorf("Lookup(%q) = %v, %t", f.Name, got.Name, ok)
+		}
+	}
+	if _, ok := Lookup("docx"); ok {
+		t.Error("Lookup accepted an unknown format")
+	}
+}
@@ -0,0 +1,102 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// Page layout in millimetres on A4 portrait.
+const (
+	pdfMargin    = 15.0
+	pdfWidth     = 210.0 - 2*pdfMargin
+	pdfRowHeight = 8.0
+)
+
+// writePDF writes one page per team, so each can be printed and posted
+// separately. The built-in fonts only cover Windows-1252; other characters
+// are replaced.
+func writePDF(w io.Writer, draws []Draw) error {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(pdfMargin, pdfMargin, pdfMargin)
+	pdf.SetAutoPageBreak(true, pdfMargin)
+	pdf.SetCreator("rollet", true)
+	pdf.SetTitle("Teams", true)
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	keys := attributeKeys(draws)
+	columns := append([]string{"Name", "Role"}, keys...)
+	colWidth := pdfWidth / float64(len(columns))
+
+	header := func() {
+		pdf.SetFont("Helvetica", "B", 11)
+		pdf.SetFillColor(230, 230, 230)
+		for _, c := range columns {
+			pdf.CellFormat(colWidth, pdfRowHeight, tr(c), "1", 0, "L", true, 0, "")
+		}
+		pdf.Ln(-1)
+		pdf.SetFont("Helvetica", "", 11)
+	}
+
+	for _, d := range draws {
+		for _, t := range d.Teams {
+			pdf.AddPage()
+
+			pdf.SetFont("Helvetica", "B", 24)
+			pdf.CellFormat(0, 14, tr(t.Name()), "", 1, "L", false, 0, "")
+			pdf.SetFont("Helvetica", "", 10)
+			pdf.SetTextColor(100, 100, 100)
+			pdf.CellFormat(0, 6, tr(pdfSubtitle(d, len(t.Members))), "", 1, "L", false, 0, "")
+			pdf.SetTextColor(0, 0, 0)
+			pdf.Ln(4)
+
+			header()
+			for _, m := range t.Members {
+				// Repeat the header when a long team runs onto a new page.
+				if pdf.GetY()+pdfRowHeight > 297-pdfMargin {
+					pdf.AddPage()
+					header()
+				}
+				cells := append([]string{m.Name, m.Role}, make([]string, len(keys))...)
+				for i, k := range keys {
+					cells[2+i] = m.Attributes[k]
+				}
+				for _, c := range cells {
+					pdf.CellFormat(colWidth, pdfRowHeight, fit(pdf, tr(c), colWidth-2), "1", 0, "L", false, 0, "")
+				}
+				pdf.Ln(-1)
+			}
+		}
+	}
+
+	if pdf.PageNo() == 0 {
+		pdf.AddPage()
+	}
+
+	return pdf.Output(w)
+}
+
+func pdfSubtitle(d Draw, members int) string {
+	s := fmt.Sprintf("%d members", members)
+	if members == 1 {
+		s = "1 member"
+	}
+	if d.ID != 0 {
+		s = fmt.Sprintf("Draw %d, %s, %s", d.ID, d.CreatedAt.UTC().Format(time.RFC1123), s)
+	}
+	return s
+}
+
+// fit shortens s, already translated to the single-byte font encoding,
+// with an ellipsis until it fits in width.
+func fit(pdf *fpdf.Fpdf, s string, width float64) string {
+	if pdf.GetStringWidth(s) <= width {
+		return s
+	}
+	for len(s) > 0 && pdf.GetStringWidth(s+"...") > width {
+		s = s[:len(s)-1]
+	}
+	return s + "..."
+}
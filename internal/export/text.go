@@ -0,0 +1,95 @@
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// writeCSV writes one row per member. Cells that a spreadsheet would run
+// as a formula are prefixed with a quote.
+func writeCSV(w io.Writer, draws []Draw) error {
+	header, rows := table(draws)
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	record := make([]string, len(header))
+	for _, row := range rows {
+		for i, v := range row {
+			record[i] = escapeFormula(fmt.Sprint(v))
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+
+	return cw.Error()
+}
+
+func escapeFormula(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// writeMarkdown writes a table per team, under a heading per saved draw.
+func writeMarkdown(w io.Writer, draws []Draw) error {
+	bw := bufio.NewWriter(w)
+	keys := attributeKeys(draws)
+
+	for i, d := range draws {
+		if i > 0 {
+			bw.WriteString("\n")
+		}
+		level := "#"
+		if d.ID != 0 {
+			fmt.Fprintf(bw, "# Draw %d\n\n%s · %s\n\n", d.ID, d.CreatedAt.UTC().Format(time.RFC1123), d.Source)
+			level = "##"
+		}
+
+		for j, t := range d.Teams {
+			if j > 0 {
+				bw.WriteString("\n")
+			}
+			fmt.Fprintf(bw, "%s %s\n\n", level, t.Name())
+
+			bw.WriteString("| Name | Role |")
+			for _, k := range keys {
+				fmt.Fprintf(bw, " %s |", escapeMarkdown(k))
+			}
+			bw.WriteString("\n| --- | --- |")
+			for range keys {
+				bw.WriteString(" --- |")
+			}
+			bw.WriteString("\n")
+
+			for _, m := range t.Members {
+				fmt.Fprintf(bw, "| %s | %s |", escapeMarkdown(m.Name), escapeMarkdown(m.Role))
+				for _, k := range keys {
+					fmt.Fprintf(bw, " %s |", escapeMarkdown(m.Attributes[k]))
+				}
+				bw.WriteString("\n")
+			}
+		}
+	}
+
+	return bw.Flush()
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "|", `\|`, "*", `\*`, "_", `\_`, "`", "\\`",
+	"[", `\[`, "]", `\]`, "<", `\<`, ">", `\>`, "#", `\#`,
+)
+
+// escapeMarkdown escapes the characters that would change how a table cell
+// renders.
+func escapeMarkdown(s string) string {
+	return markdownEscaper.Replace(s)
+}
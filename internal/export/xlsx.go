@@ -0,0 +1,64 @@
+package export
+
+import (
+	"io"
+
+	"github.com/xuri/excelize/v2"
+)
+
+const xlsxSheet = "Teams"
+
+// writeXLSX writes a workbook with one row per member on a single sheet,
+// with a bold, frozen, filterable header.
+func writeXLSX(w io.Writer, draws []Draw) error {
+	header, rows := table(draws)
+
+	f := excelize.NewFile()
+	defer f.Close()
+	if err := f.SetSheetName("Sheet1", xlsxSheet); err != nil {
+		return err
+	}
+
+	sw, err := f.NewStreamWriter(xlsxSheet)
+	if err != nil {
+		return err
+	}
+	bold, err := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		return err
+	}
+	if err := sw.SetPanes(&excelize.Panes{Freeze: true, YSplit: 1, TopLeftCell: "A2", ActivePane: "bottomLeft"}); err != nil {
+		return err
+	}
+
+	cells := make([]any, len(header))
+	for i, h := range header {
+		cells[i] = excelize.Cell{StyleID: bold, Value: h}
+	}
+	if err := sw.SetRow("A1", cells); err != nil {
+		return err
+	}
+	for i, row := range rows {
+		cell, err := excelize.CoordinatesToCellName(1, i+2)
+		if err != nil {
+			return err
+		}
+		if err := sw.SetRow(cell, row); err != nil {
+			return err
+		}
+	}
+
+	if err := sw.Flush(); err != nil {
+		return err
+	}
+
+	last, err := excelize.CoordinatesToCellName(len(header), len(rows)+1)
+	if err != nil {
+		return err
+	}
+	if err := f.AutoFilter(xlsxSheet, "A1:"+last, nil); err != nil {
+		return err
+	}
+
+	return f.Write(w)
+}
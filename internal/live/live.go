@@ -0,0 +1,317 @@
+// Package live runs draws that are revealed to an audience one person at a
+// time. A host creates a session from a finished assignment and advances
+// it; viewers follow its event log as it grows.
+package live
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Event types, in the order a session emits them. Done carries the final
+// State; end carries the State when the host or the TTL ends the session.
+const (
+	EventStart  = "start"
+	EventReveal = "reveal"
+	EventDone   = "done"
+	EventEnd    = "end"
+)
+
+var (
+	// ErrTooManySessions is returned by Create when the hub is full.
+	ErrTooManySessions = errors.New("live: too many sessions")
+	// ErrEnded is returned when advancing a session that has ended.
+	ErrEnded = errors.New("live: session ended")
+)
+
+// Person is one assigned person, in the order they are revealed.
+type Person struct {
+	Name       string            `json:"name"`
+	Role       string            `json:"role"`
+	Team       int               `json:"team"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// Event is one entry of a session's log. IDs count from 1 so a viewer can
+// resume after the last ID it saw.
+type Event struct {
+	ID   int
+	Type string
+	Data any
+}
+
+// StartData is the payload of the start event.
+type StartData struct {
+	TeamCount int `json:"team_count"`
+	Total     int `json:"total"`
+}
+
+// RevealData is the payload of a reveal event. Position counts from 1.
+type RevealData struct {
+	Position int    `json:"position"`
+	Person   Person `json:"person"`
+}
+
+// State summarises a session.
+type State struct {
+	ID        string    `json:"id"`
+	TeamCount int       `json:"team_count"`
+	Total     int       `json:"total"`
+	Revealed  int       `json:"revealed"`
+	Done      bool      `json:"done"`
+	Ended     bool      `json:"ended"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Session is one live draw.
+type Session struct {
+	id        string
+	hostToken string
+	teamCount int
+	people    []Person
+	createdAt time.Time
+	now       func() time.Time
+
+	mu       sync.Mutex
+	events   []Event
+	revealed int
+	ended    bool
+	changed  chan struct{}
+	active   time.Time
+}
+
+// ID is the public identifier viewers use to follow the session.
+func (s *Session) ID() string {
+	return s.id
+}
+
+// IsHost reports whether token is the session's host token.
+func (s *Session) IsHost(token string) bool {
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.hostToken)) == 1
+}
+
+// State returns a summary of the session.
+func (s *Session) State() State {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state()
+}
+
+func (s *Session) state() State {
+	return State{
+		ID:        s.id,
+		TeamCount: s.teamCount,
+		Total:     len(s.people),
+		Revealed:  s.revealed,
+		Done:      s.revealed == len(s.people),
+		Ended:     s.ended,
+		CreatedAt: s.createdAt,
+	}
+}
+
+// Reveal reveals the next n people, or as many as remain, and returns the
+// new state. Revealing the last person also emits the done event.
+func (s *Session) Reveal(n int) (State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ended {
+		return s.state(), ErrEnded
+	}
+
+	for ; n > 0 && s.revealed < len(s.people); n-- {
+		s.revealed++
+		s.emit(EventReveal, RevealData{Position: s.revealed, Person: s.people[s.revealed-1]})
+		if s.revealed == len(s.people) {
+			s.emit(EventDone, s.state())
+		}
+	}
+	s.active = s.now()
+
+	return s.state(), nil
+}
+
+// End closes the session. Viewers receive the end event and stop.
+func (s *Session) End() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.end()
+}
+
+func (s *Session) end() {
+	if s.ended {
+		return
+	}
+	s.ended = true
+	s.emit(EventEnd, s.state())
+}
+
+// Events returns the events after the one with ID after, and a channel that
+// is closed when more are added. The channel is nil once the session has
+// ended, when no more events will follow.
+func (s *Session) Events(after int) ([]Event, <-chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var events []Event
+	if after < len(s.events) {
+		events = append(events, s.events[max(after, 0):]...)
+	}
+	if s.ended {
+		return events, nil
+	}
+	return events, s.changed
+}
+
+// emit appends an event and wakes everyone waiting for one. The caller
+// holds s.mu.
+func (s *Session) emit(typ string, data any) {
+	s.events = append(s.events, Event{ID: len(s.events) + 1, Type: typ, Data: data})
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// Hub holds the sessions of this instance in memory.
+type Hub struct {
+	ttl         time.Duration
+	maxSessions int
+
+	mu       sync.Mutex
+	sessions map[string]*Session
+	now      func() time.Time
+}
+
+// NewHub returns a hub that ends sessions idle for longer than ttl and
+// holds at most maxSessions at once.
+func NewHub(ttl time.Duration, maxSessions int) *Hub {
+	return &Hub{
+		ttl:         ttl,
+		maxSessions: maxSessions,
+		sessions:    make(map[string]*Session),
+		now:         time.Now,
+	}
+}
+
+// Create starts a session revealing people in order, and returns it with
+// the host token that advances it.
+func (h *Hub) Create(people []Person, teamCount int) (*Session, string, error) {
+	id, err := randomToken(12)
+	if err != nil {
+		return nil, "", err
+	}
+	token, err := randomToken(24)
+	if err != nil {
+		return nil, "", err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := h.now()
+	h.sweep(now)
+	if len(h.sessions) >= h.maxSessions {
+		return nil, "", ErrTooManySessions
+	}
+
+	s := &Session{
+		id:        id,
+		hostToken: token,
+		teamCount: teamCount,
+		people:    people,
+		createdAt: now,
+		now:       h.now,
+		changed:   make(chan struct{}),
+		active:    now,
+	}
+	s.emit(EventStart, StartData{TeamCount: teamCount, Total: len(people)})
+	h.sessions[id] = s
+
+	return s, token, nil
+}
+
+// Get returns the session with the given ID, or nil if there is none or it
+// has expired.
+func (h *Hub) Get(id string) *Session {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.sweep(h.now())
+	return h.sessions[id]
+}
+
+// Len returns how many sessions are running.
+func (h *Hub) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.sweep(h.now())
+	return len(h.sessions)
+}
+
+// Remove ends the session and forgets it.
+func (h *Hub) Remove(s *Session) {
+	s.End()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.sessions, s.id)
+}
+
+// Close ends every session, so that open event streams finish.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for id, s := range h.sessions {
+		s.End()
+		delete(h.sessions, id)
+	}
+}
+
+// Run ends idle sessions every interval until ctx is cancelled, so viewers
+// of an abandoned session are disconnected even when nobody else uses the
+// hub.
+func (h *Hub) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.mu.Lock()
+			h.sweep(h.now())
+			h.mu.Unlock()
+		}
+	}
+}
+
+// sweep ends and drops sessions idle for longer than the TTL. The caller
+// holds h.mu.
+func (h *Hub) sweep(now time.Time) {
+	for id, s := range h.sessions {
+		s.mu.Lock()
+		idle := now.Sub(s.active) > h.ttl
+		if idle {
+			s.end()
+		}
+		s.mu.Unlock()
+		if idle {
+			delete(h.sessions, id)
+		}
+	}
+}
+
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,170 @@
+package live
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func testPeople() []Person {
+	return []Person{
+		{Name: "Ann", Role: "dev", Team: 1},
+		{Name: "Bob", Role: "qa", Team: 2},
+		{Name: "Cy", Role: "pm", Team: 1},
+	}
+}
+
+func eventTypes(events []Event) []string {
+	types := make([]string, len(events))
+	for i, e := range events {
+		types[i] = e.Type
+	}
+	return types
+}
+
+func TestRevealInOrder(t *testing.T) {
+	h := NewHub(time.Hour, 10)
+	s, token, err := h.Create(testPeople(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.IsHost(token) || s.IsHost("") || s.IsHost(token+"x") {
+		t.Fatal("IsHost does not match the host token only")
+	}
+
+	events, changed := s.Events(0)
+	if got := eventTypes(events); !slices.Equal(got, []string{EventStart}) {
+		t.Fatalf("events = %v; want start only", got)
+	}
+
+	state, err := s.Reveal(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Revealed != 1 || state.Done {
+		t.Errorf("state = %+v; want 1 revealed", state)
+	}
+	select {
+	case <-changed:
+	default:
+		t.Fatal("waiting viewers were not woken by a reveal")
+	}
+
+	// Revealing past the end stops at the last person.
+	state, err = s.Reveal(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Revealed != 3 || !state.Done {
+		t.Errorf("state = %+v; want all 3 revealed", state)
+	}
+
+	events, _ = s.Events(1)
+	want := []string{EventReveal, EventReveal, EventReveal, EventDone}
+	if got := eventTypes(events); !slices.Equal(got, want) {
+		t.Fatalf("events after 1 = %v; want %v", got, want)
+	}
+	for i, e := range events[:3] {
+		r := e.Data.(RevealData)
+		if r.Position != i+1 || r.Person.Name != testPeople()[i].Name || r.Person.Team != testPeople()[i].Team {
+			t.Errorf("reveal %d = %+v; want %+v", i+1, r, testPeople()[i])
+		}
+		if e.ID != i+2 {
+			t.Errorf("reveal %d has ID %d; want %d", i+1, e.ID, i+2)
+		}
+	}
+}
+
+func TestEndStopsViewers(t *testing.T) {
+	h := NewHub(time.Hour, 10)
+	s, _, err := h.Create(testPeople(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, changed := s.Events(0)
+
+	h.Remove(s)
+
+	select {
+	case <-changed:
+	default:
+		t.Fatal("waiting viewers were not woken by the end")
+	}
+	events, changed := s.Events(1)
+	if got := eventTypes(events); !slices.Equal(got, []string{EventEnd}) || changed != nil {
+		t.Errorf("events = %v, channel %v; want end and no channel", got, changed)
+	}
+	if _, err := s.Reveal(1); !errors.Is(err, ErrEnded) {
+		t.Errorf("Reveal after end: %v; want ErrEnded", err)
+	}
+	if h.Get(s.ID()) != nil {
+		t.Error("removed session is still returned")
+	}
+}
+
+func TestIdleSessionsExpire(t *testing.T) {
+	now := time.Now()
+	h := NewHub(time.Minute, 10)
+	h.now = func() time.Time { return now }
+
+	idle, _, _ := h.Create(testPeople(), 2)
+	busy, _, _ := h.Create(testPeople(), 2)
+
+	now = now.Add(50 * time.Second)
+	if _, err := busy.Reveal(1); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(20 * time.Second)
+
+	if h.Get(idle.ID()) != nil {
+		t.Error("idle session did not expire")
+	}
+	if !idle.State().Ended {
+		t.Error("expired session was not ended")
+	}
+	if h.Get(busy.ID()) != busy {
+		t.Error("session active within the TTL expired")
+	}
+	if n := h.Len(); n != 1 {
+		t.Errorf("Len = %d; want 1", n)
+	}
+}
+
+func TestRunEndsIdleSessions(t *testing.T) {
+	h := NewHub(10*time.Millisecond, 10)
+	s, _, err := h.Create(testPeople(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, changed := s.Events(0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go h.Run(ctx, 5*time.Millisecond)
+
+	select {
+	case <-changed:
+	case <-time.After(time.Second):
+		t.Fatal("viewers of an idle session were not woken")
+	}
+	if !s.State().Ended {
+		t.Error("idle session was not ended")
+	}
+}
+
+func TestMaxSessions(t *testing.T) {
+	h := NewHub(time.Hour, 1)
+	if _, _, err := h.Create(testPeople(), 2); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := h.Create(testPeople(), 2); !errors.Is(err, ErrTooManySessions) {
+		t.Errorf("Create on a full hub: %v; want ErrTooManySessions", err)
+	}
+
+	h.Close()
+	if _, _, err := h.Create(testPeople(), 2); err != nil {
+		t.Errorf("Create after Close: %v", err)
+	}
+}
@@ -0,0 +1,115 @@
+// Package metrics defines the Prometheus metrics exported by rollet.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "rollet"
+
+// Metrics holds the collectors of one server. Each instance has its own
+// registry so tests and multiple servers do not clash.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+
+	drawsCreated   *prometheus.CounterVec
+	peopleAssigned prometheus.Counter
+	loginFailures  *prometheus.CounterVec
+	rateLimited    *prometheus.CounterVec
+}
+
+// New creates the metrics and registers them together with Go runtime,
+// process and, when db is not nil, connection pool collectors.
+func New(db *sql.DB) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "HTTP requests handled, by method, route and status code.",
+		}, []string{"method", "route", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "HTTP request latency, by method and route.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		drawsCreated: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "draws_created_total",
+			Help:      "Draws made, by randomness source and whether they were saved.",
+		}, []string{"source", "saved"}),
+		peopleAssigned: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "people_assigned_total",
+			Help:      "People assigned to a team across all draws.",
+		}),
+		loginFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "login_failures_total",
+			Help:      "Failed logins, by method and reason.",
+		}, []string{"method", "reason"}),
+		rateLimited: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "rate_limited_total",
+			Help:      "Requests rejected by the rate limiter, by method and route.",
+		}, []string{"method", "route"}),
+	}
+
+	m.registry.MustRegister(
+		m.requests,
+		m.duration,
+		m.drawsCreated,
+		m.peopleAssigned,
+		m.loginFailures,
+		m.rateLimited,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	if db != nil {
+		m.registry.MustRegister(collectors.NewDBStatsCollector(db, "rollet"))
+	}
+
+	return m
+}
+
+// ObserveRequest records one handled HTTP request. route must be the route
+// pattern, not the raw path, to keep the number of series bounded.
+func (m *Metrics) ObserveRequest(method, route string, status int, d time.Duration) {
+	m.requests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	m.duration.WithLabelValues(method, route).Observe(d.Seconds())
+}
+
+// DrawCreated records a draw of people people made with source.
+func (m *Metrics) DrawCreated(source string, people int, saved bool) {
+	m.drawsCreated.WithLabelValues(source, strconv.FormatBool(saved)).Inc()
+	m.peopleAssigned.Add(float64(people))
+}
+
+// LoginFailed records a rejected login attempt.
+func (m *Metrics) LoginFailed(method, reason string) {
+	m.loginFailures.WithLabelValues(method, reason).Inc()
+}
+
+// RateLimited records a request rejected by the rate limiter.
+func (m *Metrics) RateLimited(method, route string) {
+	m.rateLimited.WithLabelValues(method, route).Inc()
+}
+
+// Handler serves the registry in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
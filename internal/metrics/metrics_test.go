@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// scrape returns the exposition text served by m.
+func scrape(t *testing.T, m *Metrics) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d", w.Code)
+	}
+	body, _ := io.ReadAll(w.Body)
+	return string(body)
+}
+
+func TestMetrics(t *testing.T) {
+	m := New(nil)
+	m.ObserveRequest(http.MethodPost, "/v1/random/default", http.StatusOK, 20*time.Millisecond)
+	m.ObserveRequest(http.MethodPost, "/v1/random/default", http.StatusOK, 30*time.Millisecond)
+	m.ObserveRequest(http.MethodGet, "/v1/share/:slug", http.StatusNotFound, time.Millisecond)
+	m.DrawCreated("crypto", 12, true)
+	m.DrawCreated("crypto", 3, false)
+	m.DrawCreated("deterministic", 5, true)
+	m.LoginFailed("password", "wrong_password")
+	m.RateLimited(http.MethodPost, "/v1/auth/login")
+
+	body := scrape(t, m)
+	for _, want := range []string{
+		`rollet_http_requests_total{method="POST",route="/v1/random/default",status="200"} 2`,
+		`rollet_http_requests_total{method="GET",route="/v1/share/:slug",status="404"} 1`,
+		`rollet_http_request_duration_seconds_count{method="POST",route="/v1/random/default"} 2`,
+		`rollet_http_request_duration_seconds_sum{method="POST",route="/v1/random/default"} 0.05`,
+		`rollet_draws_created_total{saved="true",source="crypto"} 1`,
+		`rollet_draws_created_total{saved="false",source="crypto"} 1`,
+		`rollet_draws_created_total{saved="true",source="deterministic"} 1`,
+		`rollet_people_assigned_total 20`,
+		`rollet_login_failures_total{method="password",reason="wrong_password"} 1`,
+		`rollet_http_rate_limited_total{method="POST",route="/v1/auth/login"} 1`,
+		`go_goroutines `,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics lack %q", want)
+		}
+	}
+	if strings.Contains(body, "go_sql_") {
+		t.Error("metrics include database pool stats without a database")
+	}
+}
+
+// nopConnector opens no connections; the pool stats need none.
+type nopConnector struct{}
+
+func (nopConnector) Connect(context.Context) (driver.Conn, error) {
+	return nil, errors.New("nop: no connections")
+}
+func (nopConnector) Driver() driver.Driver { return nil }
+
+func TestMetricsDatabasePool(t *testing.T) {
+	db := sql.OpenDB(nopConnector{})
+	defer db.Close()
+	db.SetMaxOpenConns(7)
+
+	body := scrape(t, New(db))
+	if want := `go_sql_max_open_connections{db_name="rollet"} 7`; !strings.Contains(body, want) {
+		t.Errorf("metrics lack %q", want)
+	}
+}
+
+func TestMetricsInstancesAreIndependent(t *testing.T) {
+	a, b := New(nil), New(nil)
+	a.DrawCreated("crypto", 4, false)
+
+	if body := scrape(t, b); strings.Contains(body, "rollet_people_assigned_total 4") {
+		t.Error("a draw recorded on one instance shows on another")
+	}
+}
@@ -0,0 +1,121 @@
+// Package randomizer assigns people to teams.
+//
+// The assignment is independent of HTTP and storage so it can be shared by
+// the API handlers and the offline CLI, and tested on its own.
+package randomizer
+
+import (
+	"errors"
+)
+
+var (
+	ErrNoPeople         = errors.New("randomizer: no people to assign")
+	ErrInvalidTeamCount = errors.New("randomizer: team count must be at least 1")
+)
+
+type Person struct {
+	Name string
+	Role string
+}
+
+type Options struct {
+	// TeamCount is the number of teams to split people into.
+	TeamCount int
+}
+
+// Assignment places the person at Index of the input into Team, numbered
+// from 1.
+type Assignment struct {
+	Person Person
+	Index  int
+	Team   int
+}
+
+type Team struct {
+	Number  int
+	Members []Assignment
+}
+
+type Result struct {
+	// Assignments lists every person once, in the order they were dealt.
+	Assignments []Assignment
+	// Teams holds teams 1..TeamCount in order; a team is empty when there
+	// are fewer people than teams.
+	Teams []Team
+}
+
+// Assigner splits people into teams.
+type Assigner interface {
+	Assign(people []Person, opts Options) (*Result, error)
+}
+
+// RoleBalanced shuffles people within each role and deals them round-robin
+// across the teams, so every role is spread as evenly as possible and team
+// sizes differ by at most one.
+type RoleBalanced struct {
+	Source Source
+}
+
+var _ Assigner = (*RoleBalanced)(nil)
+
+func NewRoleBalanced(src Source) *RoleBalanced {
+	return &RoleBalanced{Source: src}
+}
+
+func (rb *RoleBalanced) Assign(people []Person, opts Options) (*Result, error) {
+	if len(people) == 0 {
+		return nil, ErrNoPeople
+	}
+	if opts.TeamCount < 1 {
+		return nil, ErrInvalidTeamCount
+	}
+
+	// Group by role, keeping roles in order of first appearance so a seeded
+	// source reproduces the same draw.
+	var roles []string
+	byRole := make(map[string][]int)
+	for i, p := range people {
+		if _, ok := byRole[p.Role]; !ok {
+			roles = append(roles, p.Role)
+		}
+		byRole[p.Role] = append(byRole[p.Role], i)
+	}
+
+	res := &Result{
+		Assignments: make([]Assignment, 0, len(people)),
+		Teams:       make([]Team, opts.TeamCount),
+	}
+	for i := range res.Teams {
+		res.Teams[i].Number = i + 1
+	}
+
+	teamIdx := 0
+	for _, role := range roles {
+		indexes := byRole[role]
+		Shuffle(rb.Source, len(indexes), func(i, j int) {
+			indexes[i], indexes[j] = indexes[j], indexes[i]
+		})
+
+		// round-robin assignment to teams
+		for _, idx := range indexes {
+			a := Assignment{
+				Person: people[idx],
+				Index:  idx,
+				Team:   (teamIdx % opts.TeamCount) + 1,
+			}
+			res.Assignments = append(res.Assignments, a)
+			res.Teams[a.Team-1].Members = append(res.Teams[a.Team-1].Members, a)
+			teamIdx++
+		}
+	}
+
+	return res, nil
+}
+
+// Shuffle permutes n elements uniformly with a Fisher-Yates shuffle driven
+// by src.
+func Shuffle(src Source, n int, swap func(i, j int)) {
+	for i := n - 1; i > 0; i-- {
+		swap(i, src.IntN(i+1))
+	}
+}
@@ -0,0 +1,184 @@
+package randomizer
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+)
+
+// drawInput is a random assignment problem generated by testing/quick.
+type drawInput struct {
+	People    []Person
+	TeamCount int
+	Seed      uint64
+}
+
+func (drawInput) Generate(r *rand.Rand, size int) reflect.Value {
+	roles := []string{"attack", "defense", "keeper", "midfield", "coach"}
+	n := 1 + r.Intn(size*3+1)
+	roleCount := 1 + r.Intn(len(roles))
+
+	people := make([]Person, n)
+	for i := range people {
+		people[i] = Person{
+			Name: fmt.Sprintf("person-%d", i),
+			Role: roles[r.Intn(roleCount)],
+		}
+	}
+
+	return reflect.ValueOf(drawInput{
+		People:    people,
+		TeamCount: 1 + r.Intn(n+3),
+		Seed:      r.Uint64(),
+	})
+}
+
+func assign(t *testing.T, in drawInput) *Result {
+	t.Helper()
+
+	res, err := NewRoleBalanced(NewSource(in.Seed)).Assign(in.People, Options{TeamCount: in.TeamCount})
+	if err != nil {
+		t.Fatalf("Assign returned error: %v", err)
+	}
+	return res
+}
+
+func checkProperty(t *testing.T, f func(in drawInput) bool) {
+	t.Helper()
+
+	cfg := &quick.Config{MaxCount: 500, Rand: rand.New(rand.NewSource(1))}
+	if err := quick.Check(f, cfg); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestEveryPersonAssignedExactlyOnce(t *testing.T) {
+	checkProperty(t, func(in drawInput) bool {
+		res := assign(t, in)
+		if len(res.Assignments) != len(in.People) {
+			return false
+		}
+
+		seen := make([]int, len(in.People))
+		for _, team := range res.Teams {
+			for _, m := range team.Members {
+				seen[m.Index]++
+				if m.Person != in.People[m.Index] {
+					return false
+				}
+			}
+		}
+		for _, count := range seen {
+			if count != 1 {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func TestTeamNumbersWithinBounds(t *testing.T) {
+	checkProperty(t, func(in drawInput) bool {
+		res := assign(t, in)
+		if len(res.Teams) != in.TeamCount {
+			return false
+		}
+
+		for i, team := range res.Teams {
+			if team.Number != i+1 {
+				return false
+			}
+			for _, m := range team.Members {
+				if m.Team != team.Number {
+					return false
+				}
+			}
+		}
+		for _, a := range res.Assignments {
+			if a.Team < 1 || a.Team > in.TeamCount {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func TestTeamSizesDifferByAtMostOne(t *testing.T) {
+	checkProperty(t, func(in drawInput) bool {
+		res := assign(t, in)
+
+		smallest, largest := len(in.People), 0
+		for _, team := range res.Teams {
+			smallest = min(smallest, len(team.Members))
+			largest = max(largest, len(team.Members))
+		}
+		return largest-smallest <= 1
+	})
+}
+
+func TestRolesSpreadEvenly(t *testing.T) {
+	checkProperty(t, func(in drawInput) bool {
+		res := assign(t, in)
+
+		perTeam := make(map[string][]int)
+		for _, team := range res.Teams {
+			for _, m := range team.Members {
+				if perTeam[m.Person.Role] == nil {
+					perTeam[m.Person.Role] = make([]int, in.TeamCount)
+				}
+				perTeam[m.Person.Role][team.Number-1]++
+			}
+		}
+
+		for _, counts := range perTeam {
+			smallest, largest := len(in.People), 0
+			for _, c := range counts {
+				smallest = min(smallest, c)
+				largest = max(largest, c)
+			}
+			if largest-smallest > 1 {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func TestSameSeedSameResult(t *testing.T) {
+	checkProperty(t, func(in drawInput) bool {
+		return reflect.DeepEqual(assign(t, in), assign(t, in))
+	})
+}
+
+func TestAssignRejectsInvalidInput(t *testing.T) {
+	rb := NewRoleBalanced(NewSource(1))
+
+	if _, err := rb.Assign(nil, Options{TeamCount: 2}); err != ErrNoPeople {
+		t.Errorf("Assign(nil) error = %v; want %v", err, ErrNoPeople)
+	}
+
+	people := []Person{{Name: "a", Role: "dev"}}
+	if _, err := rb.Assign(people, Options{TeamCount: 0}); err != ErrInvalidTeamCount {
+		t.Errorf("Assign(TeamCount: 0) error = %v; want %v", err, ErrInvalidTeamCount)
+	}
+}
+
+func BenchmarkAssign(b *testing.B) {
+	for _, n := range []int{10, 100, 10000} {
+		people := make([]Person, n)
+		for i := range people {
+			people[i] = Person{Name: fmt.Sprintf("person-%d", i), Role: fmt.Sprintf("role-%d", i%5)}
+		}
+
+		b.Run(fmt.Sprintf("people=%d", n), func(b *testing.B) {
+			rb := NewRoleBalanced(NewSource(1))
+			for b.Loop() {
+				if _, err := rb.Assign(people, Options{TeamCount: 4}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package randomizer
+
+import (
+	"fmt"
+	"testing"
+)
+
+// chiSquareCritical23 is the chi-square critical value for 23 degrees of
+// freedom at p = 0.001, so a fair shuffle fails this check about once in a
+// thousand runs.
+const chiSquareCritical23 = 49.728
+
+// TestShuffleUniform shuffles four elements many times and checks with a
+// chi-square test that all 24 permutations come up equally often. It uses
+// fixed seeds so the outcome is the same on every run; the crypto source
+// goes through the same Shuffle.
+func TestShuffleUniform(t *testing.T) {
+	const trials = 240000
+	for _, seed := range []uint64{1, 42, 20240601} {
+		src := NewSource(seed)
+		t.Run(fmt.Sprintf("seed %d", seed), func(t *testing.T) {
+			counts := make(map[string]int)
+			for range trials {
+				perm := []int{0, 1, 2, 3}
+				Shuffle(src, len(perm), func(i, j int) {
+					perm[i], perm[j] = perm[j], perm[i]
+				})
+				counts[fmt.Sprint(perm)]++
+			}
+
+			if len(counts) != 24 {
+				t.Fatalf("saw %d distinct permutations; want 24", len(counts))
+			}
+
+			expected := float64(trials) / 24
+			var chi2 float64
+			for _, observed := range counts {
+				d := float64(observed) - expected
+				chi2 += d * d / expected
+			}
+			if chi2 > chiSquareCritical23 {
+				t.Errorf("chi-square = %.2f exceeds %.2f; shuffle looks biased", chi2, chiSquareCritical23)
+			}
+		})
+	}
+}
+
+func TestNewNamedSource(t *testing.T) {
+	for _, name := range []string{SourceCrypto, SourceSeeded, SourceDeterministic} {
+		if _, err := NewNamedSource(name, 1); err != nil {
+			t.Errorf("NewNamedSource(%q) error = %v", name, err)
+		}
+	}
+
+	if _, err := NewNamedSource("math", 1); err == nil {
+		t.Error("NewNamedSource(\"math\") error = nil; want error")
+	}
+}
+
+func TestDeterministicSourceIsStable(t *testing.T) {
+	people := []Person{{"a", "dev"}, {"b", "dev"}, {"c", "qa"}, {"d", "dev"}}
+
+	first, err := NewRoleBalanced(NewDeterministicSource()).Assign(people, Options{TeamCount: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 10 {
+		again, _ := NewRoleBalanced(NewDeterministicSource()).Assign(people, Options{TeamCount: 2})
+		for i := range first.Assignments {
+			if first.Assignments[i] != again.Assignments[i] {
+				t.Fatalf("assignment %d differs between runs", i)
+			}
+		}
+	}
+}
@@ -0,0 +1,142 @@
+// Package ratelimit implements token-bucket rate limiting with pluggable
+// stores for the bucket state.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit allows Requests per Period, with bursts of up to Requests. The zero
+// Limit means unlimited.
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// Unlimited reports whether l places no limit.
+func (l Limit) Unlimited() bool {
+	return l.Requests == 0
+}
+
+// String formats l the way ParseLimit accepts it.
+func (l Limit) String() string {
+	if l.Unlimited() {
+		return "none"
+	}
+	switch l.Period {
+	case time.Second:
+		return fmt.Sprintf("%d/s", l.Requests)
+	case time.Minute:
+		return fmt.Sprintf("%d/m", l.Requests)
+	case time.Hour:
+		return fmt.Sprintf("%d/h", l.Requests)
+	}
+	return fmt.Sprintf("%d/%s", l.Requests, l.Period)
+}
+
+// rate is the refill rate in tokens per second.
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+// ParseLimit parses "none" or N/PERIOD, where PERIOD is s, m, h or a Go
+// duration such as 10s, e.g. "30/m" or "5/10s".
+func ParseLimit(s string) (Limit, error) {
+	s = strings.TrimSpace(s)
+	if s == "none" {
+		return Limit{}, nil
+	}
+
+	n, period, ok := strings.Cut(s, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: use N/s, N/m, N/h, N/<duration> or none", s)
+	}
+	requests, err := strconv.Atoi(n)
+	if err != nil || requests < 1 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: the request count must be a positive integer", s)
+	}
+
+	var d time.Duration
+	switch period {
+	case "s":
+		d = time.Second
+	case "m":
+		d = time.Minute
+	case "h":
+		d = time.Hour
+	default:
+		d, err = time.ParseDuration(period)
+		if err != nil || d <= 0 {
+			return Limit{}, fmt.Errorf("invalid rate limit %q: the period must be s, m, h or a positive duration", s)
+		}
+	}
+
+	return Limit{Requests: requests, Period: d}, nil
+}
+
+// Result is the outcome of taking a token.
+type Result struct {
+	Allowed bool
+	// Limit is the bucket capacity.
+	Limit int
+	// Remaining is the number of whole tokens left after this request.
+	Remaining int
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is how long until the next token is available when the
+	// request was not allowed.
+	RetryAfter time.Duration
+}
+
+// Store keeps bucket state. Implementations must be safe for concurrent
+// use; a shared store lets several instances enforce one limit.
+type Store interface {
+	// Take removes one token from the bucket for key, refilled at limit.
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// ErrUnlimited is returned by Take when called with an unlimited Limit.
+var ErrUnlimited = errors.New("ratelimit: limit is unlimited")
+
+// bucket is the state of one token bucket.
+type bucket struct {
+	tokens float64
+	last   time.Time
+	limit  Limit
+}
+
+// refill adds the tokens earned since the last update, up to capacity.
+func (b *bucket) refill(now time.Time) {
+	capacity := float64(b.limit.Requests)
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(capacity, b.tokens+elapsed*b.limit.rate())
+	}
+	b.last = now
+}
+
+// take refills b and removes one token if there is one.
+func (b *bucket) take(now time.Time) Result {
+	b.refill(now)
+
+	res := Result{Limit: b.limit.Requests}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = seconds((1 - b.tokens) / b.limit.rate())
+	}
+	res.Remaining = int(b.tokens)
+	res.Reset = seconds((float64(b.limit.Requests) - b.tokens) / b.limit.rate())
+
+	return res
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,157 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Limit
+		wantErr bool
+	}{
+		{"30/m", Limit{30, time.Minute}, false},
+		{"1/s", Limit{1, time.Second}, false},
+		{"100/h", Limit{100, time.Hour}, false},
+		{"5/10s", Limit{5, 10 * time.Second}, false},
+		{" none ", Limit{}, false},
+		{"30", Limit{}, true},
+		{"0/m", Limit{}, true},
+		{"-1/m", Limit{}, true},
+		{"x/m", Limit{}, true},
+		{"5/week", Limit{}, true},
+		{"5/-1s", Limit{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseLimit(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseLimit(%q) = %v, %v; want %v, error %t", tt.in, got, err, tt.want, tt.wantErr)
+		}
+		if err == nil {
+			if again, _ := ParseLimit(got.String()); again != got {
+				t.Errorf("ParseLimit(%q.String()) = %v; want round trip", got, again)
+			}
+		}
+	}
+}
+
+// fakeClock is a settable time source for MemoryStore.
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestStore() (*MemoryStore, *fakeClock) {
+	clock := &fakeClock{t: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	s := NewMemoryStore()
+	s.now = clock.now
+	return s, clock
+}
+
+func TestMemoryStoreAllowsBurstThenRefills(t *testing.T) {
+	s, clock := newTestStore()
+	limit := Limit{Requests: 3, Period: 3 * time.Second}
+	ctx := context.Background()
+
+	for i := range 3 {
+		res, err := s.Take(ctx, "k", limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !res.Allowed || res.Remaining != 2-i || res.Limit != 3 {
+			t.Fatalf("take %d = %+v; want allowed with %d remaining", i, res, 2-i)
+		}
+	}
+
+	res, _ := s.Take(ctx, "k", limit)
+	if res.Allowed {
+		t.Fatalf("take beyond burst = %+v; want denied", res)
+	}
+	if res.RetryAfter != time.Second {
+		t.Errorf("RetryAfter = %v; want 1s", res.RetryAfter)
+	}
+	if res.Reset != 3*time.Second {
+		t.Errorf("Reset = %v; want 3s", res.Reset)
+	}
+
+	clock.advance(time.Second)
+	if res, _ := s.Take(ctx, "k", limit); !res.Allowed || res.Remaining != 0 {
+		t.Errorf("take after refill = %+v; want allowed with 0 remaining", res)
+	}
+
+	clock.advance(time.Hour)
+	if res, _ := s.Take(ctx, "k", limit); res.Remaining != 2 {
+		t.Errorf("take after long idle = %+v; want capacity capped at 3", res)
+	}
+}
+
+func TestMemoryStoreKeysAreIndependent(t *testing.T) {
+	s, _ := newTestStore()
+	limit := Limit{Requests: 1, Period: time.Minute}
+	ctx := context.Background()
+
+	if res, _ := s.Take(ctx, "a", limit); !res.Allowed {
+		t.Fatal("first take for a denied")
+	}
+	if res, _ := s.Take(ctx, "a", limit); res.Allowed {
+		t.Error("second take for a allowed")
+	}
+	if res, _ := s.Take(ctx, "b", limit); !res.Allowed {
+		t.Error("first take for b denied")
+	}
+}
+
+func TestMemoryStoreSweepsFullBuckets(t *testing.T) {
+	s, clock := newTestStore()
+	ctx := context.Background()
+
+	s.Take(ctx, "short", Limit{Requests: 1, Period: time.Second})
+	s.Take(ctx, "long", Limit{Requests: 1, Period: time.Hour})
+
+	clock.advance(sweepInterval)
+	s.Take(ctx, "new", Limit{Requests: 1, Period: time.Second})
+
+	if _, ok := s.buckets["short"]; ok {
+		t.Error("refilled bucket was not swept")
+	}
+	if _, ok := s.buckets["long"]; !ok {
+		t.Error("bucket still refilling was swept")
+	}
+}
+
+func TestMemoryStoreRejectsUnlimited(t *testing.T) {
+	s, _ := newTestStore()
+	if _, err := s.Take(context.Background(), "k", Limit{}); err != ErrUnlimited {
+		t.Errorf("Take with unlimited limit = %v; want ErrUnlimited", err)
+	}
+}
+
+func TestMemoryStoreConcurrentTakes(t *testing.T) {
+	s, _ := newTestStore()
+	limit := Limit{Requests: 50, Period: time.Hour}
+
+	var mu sync.Mutex
+	allowed := 0
+	var wg sync.WaitGroup
+	for range 200 {
+		wg.Go(func() {
+			res, _ := s.Take(context.Background(), "k", limit)
+			if res.Allowed {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		})
+	}
+	wg.Wait()
+
+	if allowed != 50 {
+		t.Errorf("allowed %d of 200 concurrent requests; want 50", allowed)
+	}
+}
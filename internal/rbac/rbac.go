@@ -0,0 +1,74 @@
+// Package rbac maps the system-wide roles of users to the permissions they
+// grant. Organisation roles are separate and only apply inside an
+// organisation.
+package rbac
+
+import "slices"
+
+// Roles, from least to most privileged. Every user has exactly one.
+const (
+	RoleUser    = "user"
+	RoleSupport = "support"
+	RoleAdmin   = "admin"
+)
+
+// Roles lists the valid roles, from least to most privileged.
+var Roles = []string{RoleUser, RoleSupport, RoleAdmin}
+
+// Permission names an operation that needs more than a plain user account.
+type Permission string
+
+const (
+	// UsersRead allows listing, searching and viewing users.
+	UsersRead Permission = "users:read"
+	// UsersWrite allows disabling and enabling users.
+	UsersWrite Permission = "users:write"
+	// PasswordsReset allows replacing a user's password with a temporary
+	// one, which the caller sees.
+	PasswordsReset Permission = "passwords:reset"
+	// UsersDelete allows deleting users with everything they saved.
+	UsersDelete Permission = "users:delete"
+	// RolesWrite allows changing the role of users.
+	RolesWrite Permission = "roles:write"
+	// StatsRead allows viewing system statistics.
+	StatsRead Permission = "stats:read"
+	// AuditRead allows reading the whole audit log.
+	AuditRead Permission = "audit:read"
+)
+
+var grants = map[string][]Permission{
+	RoleUser:    nil,
+	RoleSupport: {UsersRead, UsersWrite, StatsRead},
+	RoleAdmin:   {UsersRead, UsersWrite, PasswordsReset, UsersDelete, RolesWrite, StatsRead, AuditRead},
+}
+
+// Valid reports whether role is a known role.
+func Valid(role string) bool {
+	_, ok := grants[role]
+	return ok
+}
+
+// Can reports whether role grants the permission. Unknown roles grant
+// nothing.
+func Can(role string, p Permission) bool {
+	return slices.Contains(grants[role], p)
+}
+
+// Permissions returns the permissions role grants.
+func Permissions(role string) []Permission {
+	return slices.Clone(grants[role])
+}
+
+// CanManage reports whether a user with role actor may act on a user with
+// role target, e.g. disable them. Admins manage everyone; other roles only
+// manage users with a lesser role, so support cannot lock out an admin.
+func CanManage(actor, target string) bool {
+	if actor == RoleAdmin {
+		return true
+	}
+	return Valid(actor) && rank(target) < rank(actor)
+}
+
+func rank(role string) int {
+	return slices.Index(Roles, role)
+}
@@ -0,0 +1,60 @@
+package rbac
+
+import "testing"
+
+func TestCan(t *testing.T) {
+	tests := []struct {
+		role string
+		perm Permission
+		want bool
+	}{
+		{RoleUser, UsersRead, false},
+		{RoleSupport, UsersRead, true},
+		{RoleSupport, UsersWrite, true},
+		{RoleSupport, UsersDelete, false},
+		{RoleSupport, PasswordsReset, false},
+		{RoleAdmin, PasswordsReset, true},
+		{RoleSupport, RolesWrite, false},
+		{RoleAdmin, UsersDelete, true},
+		{RoleAdmin, RolesWrite, true},
+		{RoleAdmin, AuditRead, true},
+		{RoleSupport, AuditRead, false},
+		{"root", UsersRead, false},
+		{"", StatsRead, false},
+	}
+	for _, tt := range tests {
+		if got := Can(tt.role, tt.perm); got != tt.want {
+			t.Errorf("Can(%q, %s) = %v; want %v", tt.role, tt.perm, got, tt.want)
+		}
+	}
+}
+
+func TestAdminHasEveryPermission(t *testing.T) {
+	for _, role := range Roles {
+		for _, p := range Permissions(role) {
+			if !Can(RoleAdmin, p) {
+				t.Errorf("admin lacks %s, which %s has", p, role)
+			}
+		}
+	}
+}
+
+func TestCanManage(t *testing.T) {
+	tests := []struct {
+		actor, target string
+		want          bool
+	}{
+		{RoleAdmin, RoleAdmin, true},
+		{RoleAdmin, RoleUser, true},
+		{RoleSupport, RoleUser, true},
+		{RoleSupport, RoleSupport, false},
+		{RoleSupport, RoleAdmin, false},
+		{RoleUser, RoleUser, false},
+		{"root", RoleUser, false},
+	}
+	for _, tt := range tests {
+		if got := CanManage(tt.actor, tt.target); got != tt.want {
+			t.Errorf("CanManage(%q, %q) = %v; want %v", tt.actor, tt.target, got, tt.want)
+		}
+	}
+}
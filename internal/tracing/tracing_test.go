@@ -0,0 +1,49 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestSetupPropagatesTraceContext(t *testing.T) {
+	shutdown, err := Setup(context.Background(), Options{Exporter: ExporterNone})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer shutdown(context.Background())
+
+	const parent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	h := http.Header{}
+	h.Set("traceparent", parent)
+
+	ctx := otel.GetTextMapPropagator().Extract(context.Background(), propagation.HeaderCarrier(h))
+	sc := trace.SpanContextFromContext(ctx)
+	if got := sc.TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("trace ID = %s; want the one from traceparent", got)
+	}
+	if !sc.IsRemote() || !sc.IsSampled() {
+		t.Errorf("span context = %+v; want a sampled remote parent", sc)
+	}
+}
+
+func TestSetupExporters(t *testing.T) {
+	for _, name := range []string{ExporterNone, ExporterStdout, ExporterOTLP} {
+		shutdown, err := Setup(context.Background(), Options{Exporter: name, SampleRatio: 1, ServiceName: "rollet"})
+		if err != nil {
+			t.Errorf("Setup(%s): %v", name, err)
+			continue
+		}
+		if err := shutdown(context.Background()); err != nil {
+			t.Errorf("shutdown(%s): %v", name, err)
+		}
+	}
+
+	if _, err := Setup(context.Background(), Options{Exporter: "zipkin"}); err == nil {
+		t.Error("Setup accepted an unknown exporter")
+	}
+}
@@ -0,0 +1,310 @@
+// Package webhook delivers signed event payloads to subscriber URLs. A
+// Worker polls a Store for due deliveries, posts them, and records each
+// attempt, retrying failures with exponential backoff.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Event types a subscription can ask for.
+const (
+	EventDrawSaved = "draw.saved"
+)
+
+// Events lists every event type.
+var Events = []string{EventDrawSaved}
+
+// Delivery statuses.
+const (
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// Headers sent with every delivery.
+const (
+	HeaderEvent     = "Rollet-Event"
+	HeaderDelivery  = "Rollet-Delivery"
+	HeaderSignature = "Rollet-Signature"
+)
+
+// Delivery is one payload due to be posted to a subscriber.
+type Delivery struct {
+	ID      int
+	URL     string
+	Secret  string
+	Event   string
+	Payload []byte
+	// Attempts is the number of attempts made before this one.
+	Attempts int
+}
+
+// Attempt is the outcome of posting a delivery once.
+type Attempt struct {
+	StatusCode int
+	Error      string
+	Duration   time.Duration
+}
+
+// OK reports whether the subscriber accepted the delivery with a 2xx.
+func (a Attempt) OK() bool {
+	return a.Error == "" && a.StatusCode >= 200 && a.StatusCode < 300
+}
+
+// Store holds the delivery queue.
+type Store interface {
+	// Claim returns up to limit due deliveries and postpones them by lease,
+	// so other workers skip them while they are being sent.
+	Claim(ctx context.Context, limit int, lease time.Duration) ([]Delivery, error)
+	// Finish records an attempt and the delivery's new status. For
+	// StatusPending the delivery is due again after retryIn.
+	Finish(ctx context.Context, id int, a Attempt, status string, retryIn time.Duration) error
+}
+
+// Sign returns the signature header value for a payload sent at t: the
+// Unix time and the hex HMAC-SHA256 of "<time>.<payload>" under secret.
+func Sign(secret string, t time.Time, payload []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac(secret, ts, payload))
+}
+
+// Verify checks a signature header made by Sign and rejects it if it is
+// older than tolerance, so subscribers can guard against replays.
+func Verify(secret, header string, payload []byte, now time.Time, tolerance time.Duration) error {
+	var ts, sig string
+	for _, part := range strings.Split(header, ",") {
+		k, v, _ := strings.Cut(part, "=")
+		switch k {
+		case "t":
+			ts = v
+		case "v1":
+			sig = v
+		}
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || sig == "" {
+		return errors.New("webhook: malformed signature")
+	}
+	if now.Sub(time.Unix(unix, 0)).Abs() > tolerance {
+		return errors.New("webhook: signature timestamp out of tolerance")
+	}
+
+	got, err := hex.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, mac(secret, ts, payload)) {
+		return errors.New("webhook: signature mismatch")
+	}
+	return nil
+}
+
+func mac(secret, ts string, payload []byte) []byte {
+	m := hmac.New(sha256.New, []byte(secret))
+	m.Write([]byte(ts))
+	m.Write([]byte("."))
+	m.Write(payload)
+	return m.Sum(nil)
+}
+
+// Backoff returns the wait before retrying after the given number of
+// failed attempts: 30s, doubling up to an hour.
+func Backoff(failed int) time.Duration {
+	d := 30 * time.Second
+	for i := 1; i < failed && d < time.Hour; i++ {
+		d *= 2
+	}
+	return min(d, time.Hour)
+}
+
+// ErrPrivateAddress is returned when a delivery would connect to a
+// loopback, private or link-local address.
+var ErrPrivateAddress = errors.New("webhook: destination address is not public")
+
+// NewClient returns the HTTP client for deliveries. Unless allowPrivate is
+// set it refuses to connect to non-public addresses, so subscribers cannot
+// point deliveries at internal services. Redirects are not followed.
+func NewClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivate {
+		dialer.Control = func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || !publicIP(ip) {
+				return ErrPrivateAddress
+			}
+			return nil
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	transport.Proxy = nil
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func publicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() && !ip.IsUnspecified() && !ip.IsMulticast()
+}
+
+// CheckURL reports whether u is an acceptable subscription URL: absolute
+// http or https without credentials.
+func CheckURL(u string) error {
+	req, err := http.NewRequest(http.MethodPost, u, nil)
+	if err != nil {
+		return errors.New("must be an absolute URL")
+	}
+	switch {
+	case req.URL.Scheme != "http" && req.URL.Scheme != "https":
+		return errors.New("must use http or https")
+	case req.URL.Host == "":
+		return errors.New("must have a host")
+	case req.URL.User != nil:
+		return errors.New("must not contain credentials")
+	}
+	return nil
+}
+
+// Worker sends due deliveries.
+type Worker struct {
+	Store  Store
+	Client *http.Client
+	// Interval is how often the store is polled when idle.
+	Interval time.Duration
+	// MaxAttempts is how many times a delivery is tried before it fails.
+	MaxAttempts int
+	// Batch is how many deliveries are claimed at once.
+	Batch int
+
+	wake chan struct{}
+	now  func() time.Time
+}
+
+// NewWorker returns a worker with the given store, client and limits.
+func NewWorker(store Store, client *http.Client, interval time.Duration, maxAttempts int) *Worker {
+	return &Worker{
+		Store:       store,
+		Client:      client,
+		Interval:    interval,
+		MaxAttempts: maxAttempts,
+		Batch:       20,
+		wake:        make(chan struct{}, 1),
+		now:         time.Now,
+	}
+}
+
+// Notify wakes the worker to send new deliveries without waiting for the
+// next poll.
+func (w *Worker) Notify() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run sends deliveries until ctx is cancelled.
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	for {
+		// A full batch means more may be waiting.
+		for w.RunOnce(ctx) == w.Batch && ctx.Err() == nil {
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-w.wake:
+		}
+	}
+}
+
+// RunOnce claims and sends one batch and returns how many were claimed.
+func (w *Worker) RunOnce(ctx context.Context) int {
+	// Hold claimed deliveries long enough for the whole batch to be sent.
+	lease := time.Duration(w.Batch)*w.Client.Timeout + time.Minute
+	deliveries, err := w.Store.Claim(ctx, w.Batch, lease)
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.ErrorContext(ctx, "failed to claim webhook deliveries", "error", err)
+		}
+		return 0
+	}
+
+	for _, d := range deliveries {
+		a := w.Send(ctx, d)
+
+		status, retryIn := StatusSucceeded, time.Duration(0)
+		if !a.OK() {
+			status = StatusFailed
+			if d.Attempts+1 < w.MaxAttempts {
+				status, retryIn = StatusPending, Backoff(d.Attempts+1)
+			}
+			slog.WarnContext(ctx, "webhook delivery failed",
+				"delivery_id", d.ID, "attempt", d.Attempts+1, "status_code", a.StatusCode, "error", a.Error, "retry_in", retryIn.String())
+		}
+
+		// Record the attempt even when shutting down.
+		if err := w.Store.Finish(context.WithoutCancel(ctx), d.ID, a, status, retryIn); err != nil {
+			slog.ErrorContext(ctx, "failed to record webhook delivery", "delivery_id", d.ID, "error", err)
+		}
+	}
+
+	return len(deliveries)
+}
+
+// Send posts one delivery and reports the outcome.
+func (w *Worker) Send(ctx context.Context, d Delivery) Attempt {
+	start := w.now()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return Attempt{Error: err.Error()}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "rollet-webhooks")
+	req.Header.Set(HeaderEvent, d.Event)
+	req.Header.Set(HeaderDelivery, strconv.Itoa(d.ID))
+	req.Header.Set(HeaderSignature, Sign(d.Secret, start, d.Payload))
+
+	res, err := w.Client.Do(req)
+	a := Attempt{Duration: w.now().Sub(start)}
+	if err != nil {
+		a.Error = err.Error()
+		return a
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+
+	a.StatusCode = res.StatusCode
+	if !a.OK() {
+		a.Error = fmt.Sprintf("unexpected status %d", res.StatusCode)
+	}
+	return a
+}
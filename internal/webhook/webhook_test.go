@@ -0,0 +1,194 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memStore is a Store over a slice, recording every finished attempt.
+type memStore struct {
+	mu         sync.Mutex
+	deliveries []Delivery
+	due        map[int]bool
+	finished   []finished
+}
+
+type finished struct {
+	id      int
+	attempt Attempt
+	status  string
+	retryIn time.Duration
+}
+
+func newMemStore(ds ...Delivery) *memStore {
+	s := &memStore{deliveries: ds, due: make(map[int]bool)}
+	for _, d := range ds {
+		s.due[d.ID] = true
+	}
+	return s
+}
+
+func (s *memStore) Claim(_ context.Context, limit int, _ time.Duration) ([]Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var claimed []Delivery
+	for _, d := range s.deliveries {
+		if s.due[d.ID] && len(claimed) < limit {
+			s.due[d.ID] = false
+			claimed = append(claimed, d)
+		}
+	}
+	return claimed, nil
+}
+
+func (s *memStore) Finish(_ context.Context, id int, a Attempt, status string, retryIn time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.finished = append(s.finished, finished{id, a, status, retryIn})
+	for i := range s.deliveries {
+		if s.deliveries[i].ID == id {
+			s.deliveries[i].Attempts++
+			s.due[id] = status == StatusPending
+		}
+	}
+	return nil
+}
+
+func TestSignAndVerify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	payload := []byte(`{"event":"draw.saved"}`)
+	sig := Sign("s3cret", now, payload)
+
+	if err := Verify("s3cret", sig, payload, now.Add(time.Minute), 5*time.Minute); err != nil {
+		t.Errorf("Verify(valid) = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		secret  string
+		header  string
+		payload []byte
+		now     time.Time
+	}{
+		{"wrong secret", "other", sig, payload, now},
+		{"tampered payload", "s3cret", sig, []byte(`{}`), now},
+		{"too old", "s3cret", sig, payload, now.Add(10 * time.Minute)},
+		{"malformed", "s3cret", "v1=abc", payload, now},
+	}
+	for _, tt := range tests {
+		if err := Verify(tt.secret, tt.header, tt.payload, tt.now, 5*time.Minute); err == nil {
+			t.Errorf("Verify(%s) succeeded", tt.name)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	want := []time.Duration{30 * time.Second, time.Minute, 2 * time.Minute, 4 * time.Minute}
+	for i, w := range want {
+		if got := Backoff(i + 1); got != w {
+			t.Errorf("Backoff(%d) = %v; want %v", i+1, got, w)
+		}
+	}
+	if got := Backoff(50); got != time.Hour {
+		t.Errorf("Backoff(50) = %v; want 1h cap", got)
+	}
+}
+
+func TestWorkerDeliversSignedPayload(t *testing.T) {
+	payload := []byte(`{"event":"draw.saved","data":{"draw_id":7}}`)
+
+	var got *http.Request
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	store := newMemStore(Delivery{ID: 1, URL: srv.URL, Secret: "s3cret", Event: EventDrawSaved, Payload: payload})
+	w := NewWorker(store, NewClient(5*time.Second, true), time.Minute, 3)
+
+	if n := w.RunOnce(context.Background()); n != 1 {
+		t.Fatalf("RunOnce sent %d deliveries; want 1", n)
+	}
+
+	if got == nil {
+		t.Fatal("stand-in received nothing")
+	}
+	if got.Header.Get(HeaderEvent) != EventDrawSaved || got.Header.Get(HeaderDelivery) != "1" {
+		t.Errorf("headers = %v", got.Header)
+	}
+	if err := Verify("s3cret", got.Header.Get(HeaderSignature), body, time.Now(), time.Minute); err != nil {
+		t.Errorf("stand-in could not verify the signature: %v", err)
+	}
+	if len(store.finished) != 1 || store.finished[0].status != StatusSucceeded || store.finished[0].attempt.StatusCode != 204 {
+		t.Errorf("finished = %+v; want one success", store.finished)
+	}
+}
+
+func TestWorkerRetriesThenFails(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	store := newMemStore(Delivery{ID: 1, URL: srv.URL, Secret: "s", Event: EventDrawSaved, Payload: []byte(`{}`)})
+	w := NewWorker(store, NewClient(5*time.Second, true), time.Minute, 3)
+
+	for range 5 {
+		w.RunOnce(context.Background())
+	}
+
+	if calls != 3 {
+		t.Errorf("stand-in called %d times; want 3", calls)
+	}
+	want := []finished{
+		{status: StatusPending, retryIn: 30 * time.Second},
+		{status: StatusPending, retryIn: time.Minute},
+		{status: StatusFailed},
+	}
+	if len(store.finished) != len(want) {
+		t.Fatalf("finished = %+v; want %d attempts", store.finished, len(want))
+	}
+	for i, f := range store.finished {
+		if f.status != want[i].status || f.retryIn != want[i].retryIn || f.attempt.StatusCode != 503 || f.attempt.Error == "" {
+			t.Errorf("attempt %d = %+v; want %s retrying in %v", i+1, f, want[i].status, want[i].retryIn)
+		}
+	}
+}
+
+func TestClientRefusesPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request reached a loopback server")
+	}))
+	defer srv.Close()
+
+	_, err := NewClient(time.Second, false).Post(srv.URL, "application/json", nil)
+	if !errors.Is(err, ErrPrivateAddress) {
+		t.Errorf("Post to loopback: %v; want ErrPrivateAddress", err)
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	for _, u := range []string{"https://example.com/hook", "http://example.com:8080/x?y=1"} {
+		if err := CheckURL(u); err != nil {
+			t.Errorf("CheckURL(%q) = %v", u, err)
+		}
+	}
+	for _, u := range []string{"", "example.com/hook", "ftp://example.com", "https://user:pw@example.com", "https:///path"} {
+		if err := CheckURL(u); err == nil {
+			t.Errorf("CheckURL(%q) succeeded", u)
+		}
+	}
+}